@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dragosboca/haws/pkg/haws"
+)
+
+var (
+	applyCmd = &cobra.Command{
+		Use:   "apply <plan file>",
+		Short: "Execute the change sets of a plan",
+		Long:  "Execute exactly the change sets saved by haws plan, refusing the stacks that changed since the plan was made",
+		Args:  cobra.ExactArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			plan, err := haws.ReadPlan(args[0])
+			if err != nil {
+				fail(err)
+			}
+
+			h := newHaws(ctx, false)
+			applyConfig(&h)
+			h.SetAllowReplace(allowReplace)
+
+			if err := h.Apply(ctx, plan); err != nil {
+				fail(err)
+			}
+		},
+	}
+)
+
+func init() {
+	applyCmd.Flags().BoolVar(&allowReplace, "allow-replace", false, "Allow the change sets to replace or delete the content bucket and the distribution")
+
+	rootCmd.AddCommand(applyCmd)
+}
@@ -2,34 +2,36 @@ package cmd
 
 import (
 	"context"
-	"fmt"
 	"os"
+	"os/signal"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
-	"github.com/dragosboca/haws/pkg/haws"
+	"github.com/dragosboca/haws/pkg/stack"
 )
 
 var (
+	autoApprove  bool
+	allowReplace bool
+
 	deployCmd = &cobra.Command{
 		Use:   "deploy",
 		Short: "Deploy the cloudformation stacks",
 		Long:  "Deploy all stacks",
 
 		Run: func(cmd *cobra.Command, args []string) {
-			ctx := context.Background()
-			h := haws.New(dryRun,
-				viper.GetString("prefix"),
-				viper.GetString("region"),
-				viper.GetString("zone_id"),
-				viper.GetString("bucket_path"),
-				viper.GetString("record"),
-			)
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			h := newHaws(ctx, dryRun)
+			applyConfig(&h)
+			h.SetAllowReplace(allowReplace)
+
+			if !autoApprove {
+				h.SetConfirm(stack.Prompt(os.Stdin, os.Stdout))
+			}
 
 			if err := h.Deploy(ctx); err != nil {
-				fmt.Printf("%v\n", err)
-				os.Exit(1)
+				fail(err)
 			}
 		},
 	}
@@ -37,6 +39,8 @@ var (
 
 func init() {
 	deployCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Simulate the actions")
+	deployCmd.Flags().BoolVar(&autoApprove, "auto-approve", false, "Execute the change sets without asking for confirmation")
+	deployCmd.Flags().BoolVar(&allowReplace, "allow-replace", false, "Allow the change sets to replace or delete the content bucket and the distribution")
 
 	rootCmd.AddCommand(deployCmd)
 }
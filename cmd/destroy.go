@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dragosboca/haws/pkg/stack"
+)
+
+var (
+	emptyBuckets bool
+
+	destroyCmd = &cobra.Command{
+		Use:   "destroy",
+		Short: "Delete the cloudformation stacks",
+		Long:  "Delete all stacks of the site in reverse dependency order, keeping the ones still used by other sites",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			h := newHaws(ctx, dryRun)
+			applyConfig(&h)
+
+			if !dryRun && !autoApprove {
+				ok, err := stack.Prompt(os.Stdin, os.Stdout)(ctx, "Delete all the stacks of the site?")
+				if err != nil {
+					fail(err)
+				}
+				if !ok {
+					return
+				}
+			}
+
+			if err := h.Destroy(ctx, emptyBuckets); err != nil {
+				fail(err)
+			}
+		},
+	}
+)
+
+func init() {
+	destroyCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be deleted")
+	destroyCmd.Flags().BoolVar(&autoApprove, "auto-approve", false, "Delete the stacks without asking for confirmation")
+	destroyCmd.Flags().BoolVar(&emptyBuckets, "empty-buckets", false, "Delete all objects from the content and log buckets before deleting their stacks")
+
+	rootCmd.AddCommand(destroyCmd)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	driftCmd = &cobra.Command{
+		Use:   "drift",
+		Short: "Detect drift on the cloudformation stacks",
+		Long:  "Detect the resources changed outside CloudFormation. Exits with 2 when drift is found",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			h := newHaws(ctx, false)
+			applyConfig(&h)
+
+			drifted, err := h.Drift(ctx)
+			if err != nil {
+				fail(err)
+			}
+			if drifted {
+				os.Exit(exitDrift)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+}
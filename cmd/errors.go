@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dragosboca/haws/pkg/stack"
+)
+
+// Exit codes of the commands
+const (
+	exitError           = 1
+	exitDrift           = 2
+	exitStackFailed     = 3
+	exitChangeSetFailed = 4
+	exitTimeout         = 5
+	exitAccessDenied    = 6
+	exitThrottled       = 7
+	exitInterrupted     = 130
+)
+
+// fail prints the error with a diagnosis to stderr and exits with the exit code of its kind
+// param: err - the error
+func fail(err error) {
+	code, diagnosis := diagnose(err)
+	fmt.Fprintf(os.Stderr, "%v\n", err)
+	if diagnosis != "" {
+		fmt.Fprintf(os.Stderr, "\n%s\n", diagnosis)
+	}
+	os.Exit(code)
+}
+
+// diagnose explains an error and chooses the exit code
+// param: err - the error
+// return: int - the exit code
+// return: string - the diagnosis (empty if there is nothing to add)
+func diagnose(err error) (int, string) {
+	var (
+		stackFailed     *stack.StackFailedError
+		changeSetFailed *stack.ChangeSetFailedError
+		timeout         *stack.TimeoutError
+	)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted, "Interrupted: the running CloudFormation operations continue, the next run waits for them."
+
+	case errors.As(err, &stackFailed):
+		var b strings.Builder
+		fmt.Fprintf(&b, "Stack %s ended in %s.", stackFailed.Stack, stackFailed.Status)
+		for _, resource := range stackFailed.Resources {
+			fmt.Fprintf(&b, "\n  %s (%s) %s: %s", resource.LogicalId, resource.ResourceType, resource.Status, resource.Reason)
+			if hint := resourceHint(resource.Reason); hint != "" {
+				fmt.Fprintf(&b, "\n    -> %s", hint)
+			}
+		}
+		if len(stackFailed.Resources) == 0 {
+			b.WriteString("\nThe failed resources are listed in the events of the stack (CloudFormation console).")
+		}
+		return exitStackFailed, b.String()
+
+	case errors.As(err, &changeSetFailed):
+		return exitChangeSetFailed, fmt.Sprintf("CloudFormation rejected change set %s of stack %s: the template or the parameters are not valid. "+
+			"Run haws synth to inspect the template.", changeSetFailed.ChangeSet, changeSetFailed.Stack)
+
+	case errors.As(err, &timeout):
+		return exitTimeout, "The operation keeps running in CloudFormation. Follow it with haws status --watch, " +
+			"and raise the timeout of the stack in the [timeouts] table of the config file if it is expected to take that long."
+
+	case stack.IsAccessDenied(err):
+		return exitAccessDenied, "AWS denied the request: check the credentials (profile, environment) and the permissions of the caller " +
+			"or of the role set with role-arn."
+
+	case stack.IsThrottle(err):
+		return exitThrottled, "AWS throttled the requests: retry later, or deploy fewer sites at the same time."
+	}
+	return exitError, ""
+}
+
+// resourceHint suggests a fix for the failure reason of a resource
+// param: reason - the status reason of the resource
+// return: string - the suggestion (empty if none)
+func resourceHint(reason string) string {
+	switch {
+	case strings.Contains(reason, "already exists"):
+		return "a resource with the same name exists outside the stack: delete it, or adopt it with haws import"
+	case strings.Contains(reason, "not authorized") || strings.Contains(reason, "AccessDenied"):
+		return "the caller (or the role set with role-arn) lacks a permission"
+	case strings.Contains(reason, "CNAMEAlreadyExists"):
+		return "the record is an alternate domain name of another distribution: remove it there first"
+	case strings.Contains(reason, "Rate exceeded") || strings.Contains(reason, "Throttling"):
+		return "AWS throttled CloudFormation: retry later"
+	}
+	return ""
+}
@@ -2,10 +2,6 @@ package cmd
 
 import (
 	"context"
-	"fmt"
-	"os"
-
-	"github.com/dragosboca/haws/pkg/haws"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -20,21 +16,11 @@ var (
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx := context.Background()
 
-			h := haws.New(dryRun,
-				viper.GetString("prefix"),
-				viper.GetString("region"),
-				viper.GetString("zone_id"),
-				viper.GetString("bucket_path"),
-				viper.GetString("record"),
-			)
-
-			stacks := []string{"certificate", "bucket", "cloudfront", "user"}
-			for _, stack := range stacks {
-				if err := h.GetStackOutput(ctx, stack); err != nil {
-					fmt.Printf("%v\n", err)
-					os.Exit(1)
-				}
+			h := newHaws(ctx, dryRun)
+			applyConfig(&h)
 
+			if err := h.GetOutputs(ctx); err != nil {
+				fail(err)
 			}
 			h.GenerateHugoConfig(viper.GetString("region"), viper.GetString("bucket_path"))
 		},
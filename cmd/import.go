@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dragosboca/haws/pkg/stack"
+)
+
+var (
+	importCmd = &cobra.Command{
+		Use:   "import",
+		Short: "Import an existing bucket and distribution into the stacks",
+		Long:  "Adopt the existing resources declared in the [import] table of the config file with IMPORT change sets, then deploy the rest of the site",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			h := newHaws(ctx, false)
+			applyConfig(&h)
+
+			if !autoApprove {
+				h.SetConfirm(stack.Prompt(os.Stdin, os.Stdout))
+			}
+
+			if err := h.Import(ctx); err != nil {
+				fail(err)
+			}
+		},
+	}
+)
+
+func init() {
+	importCmd.Flags().BoolVar(&autoApprove, "auto-approve", false, "Execute the change sets without asking for confirmation")
+
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dragosboca/haws/pkg/haws"
+)
+
+var (
+	outputsFormat  string
+	includeSecrets bool
+
+	outputsCmd = &cobra.Command{
+		Use:   "outputs",
+		Short: "Print the outputs of the cloudformation stacks",
+		Long:  "Print the outputs of every stack under stable variable names (HAWS_BUCKET_NAME, HAWS_DISTRIBUTION_ID, HAWS_REGION...) as json, shell variables or dotenv, or write them to $GITHUB_OUTPUT",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			h := newHaws(ctx, false)
+			applyConfig(&h)
+
+			values, err := h.CollectOutputs(ctx, includeSecrets)
+			if err != nil {
+				fail(err)
+			}
+
+			if outputsFormat == haws.OutputsGithub {
+				err = haws.WriteGithubOutputs(values)
+			} else {
+				var rendered string
+				if rendered, err = haws.FormatOutputs(values, outputsFormat); err == nil {
+					fmt.Print(rendered)
+				}
+			}
+			if err != nil {
+				fail(err)
+			}
+		},
+	}
+)
+
+func init() {
+	outputsCmd.Flags().StringVar(&outputsFormat, "format", haws.OutputsJSON, "Output format: json, env, dotenv or github")
+	outputsCmd.Flags().BoolVar(&includeSecrets, "include-secrets", false, "Include the secret access key of the user")
+
+	rootCmd.AddCommand(outputsCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dragosboca/haws/pkg/haws"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+var (
+	planFile string
+
+	planCmd = &cobra.Command{
+		Use:   "plan",
+		Short: "Create the change sets without executing them",
+		Long:  "Create the change sets of all stacks and save them in a plan file that can be executed with haws apply",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			h := newHaws(ctx, false)
+			applyConfig(&h)
+
+			plan, err := h.Plan(ctx)
+			if err != nil {
+				fail(err)
+			}
+			if err := haws.WritePlan(planFile, plan); err != nil {
+				fail(err)
+			}
+			logger.Info("Plan saved to %s, run \"haws apply %s\" to execute it", planFile, planFile)
+		},
+	}
+)
+
+func init() {
+	planCmd.Flags().StringVarP(&planFile, "out", "o", "haws.plan", "The file where the plan is saved")
+
+	rootCmd.AddCommand(planCmd)
+}
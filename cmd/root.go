@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/dragosboca/haws/pkg/components"
+	"github.com/dragosboca/haws/pkg/haws"
 	"github.com/dragosboca/haws/pkg/logger"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -17,9 +22,18 @@ var (
 	dryRun bool
 	logLevel string
 
-	record string
-	zoneId string
-	path   string
+	record  string
+	zoneId  string
+	domain  string
+	path    string
+	roleArn string
+
+	profile         string
+	assumeRoleArn   string
+	externalId      string
+	mfaSerial       string
+	sessionDuration time.Duration
+	endpointUrl     string
 
 	rootCmd = &cobra.Command{
 		Use:   "haws",
@@ -60,6 +74,14 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&record, "record", "", "Record name to be added to R53 zone")
 	rootCmd.PersistentFlags().StringVar(&zoneId, "zone-id", "", "AWS Id of the zone used for SSL certificate validation and where the record should be added")
+	rootCmd.PersistentFlags().StringVar(&domain, "domain", "", "Domain of the zone (default: looked up in Route53 from the zone id)")
+	rootCmd.PersistentFlags().StringVar(&roleArn, "role-arn", "", "ARN of the service role CloudFormation assumes to operate on the stacks (default: the caller's credentials)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "AWS shared config profile (default: the default credential chain)")
+	rootCmd.PersistentFlags().StringVar(&assumeRoleArn, "assume-role-arn", "", "ARN of a role assumed with the credentials of the profile to run haws")
+	rootCmd.PersistentFlags().StringVar(&externalId, "external-id", "", "External ID passed when assuming the role")
+	rootCmd.PersistentFlags().StringVar(&mfaSerial, "mfa-serial", "", "MFA device required to assume the role (the code is asked on the terminal)")
+	rootCmd.PersistentFlags().DurationVar(&sessionDuration, "session-duration", haws.DefaultSessionDuration, "Duration of the session of the assumed role, at most the maximum session duration of the role")
+	rootCmd.PersistentFlags().StringVar(&endpointUrl, "endpoint-url", "", "Endpoint of all the AWS services, e.g. a local AWS emulator")
 	rootCmd.PersistentFlags().StringVar(&path, "bucket-path", "", "Path prefix that will be appended by cloudfront to all requests (it should correspond to a sub-folder in the bucket)")
 
 	if err := viper.BindPFlag("prefix", rootCmd.PersistentFlags().Lookup("prefix")); err != nil {
@@ -78,10 +100,24 @@ func init() {
 		logger.Fatal("Failed to bind zone_id flag: %v", err)
 	}
 
+	if err := viper.BindPFlag("domain", rootCmd.PersistentFlags().Lookup("domain")); err != nil {
+		logger.Fatal("Failed to bind domain flag: %v", err)
+	}
+
 	if err := viper.BindPFlag("bucket_path", rootCmd.PersistentFlags().Lookup("bucket-path")); err != nil {
 		logger.Fatal("Failed to bind bucket_path flag: %v", err)
 	}
 
+	if err := viper.BindPFlag("role-arn", rootCmd.PersistentFlags().Lookup("role-arn")); err != nil {
+		logger.Fatal("Failed to bind role-arn flag: %v", err)
+	}
+
+	for _, name := range []string{"profile", "assume-role-arn", "external-id", "mfa-serial", "session-duration", "endpoint-url"} {
+		if err := viper.BindPFlag(name, rootCmd.PersistentFlags().Lookup(name)); err != nil {
+			logger.Fatal("Failed to bind %s flag: %v", name, err)
+		}
+	}
+
 	if err := viper.BindPFlag("log_level", rootCmd.PersistentFlags().Lookup("log-level")); err != nil {
 		logger.Fatal("Failed to bind log_level flag: %v", err)
 	}
@@ -109,3 +145,289 @@ func initConfig() {
 		}
 	}
 }
+
+// newHaws builds the site from the flags and the config file
+// The domain of the zone is looked up in Route53 unless it is set with --domain
+// param: dryRun - simulate the actions
+// return: haws.Haws - the site
+func newHaws(ctx context.Context, dryRun bool) haws.Haws {
+	clients, err := clientProvider()
+	if err != nil {
+		fail(err)
+	}
+
+	var zones haws.ZoneResolver
+	if domain := viper.GetString("domain"); domain != "" {
+		zones = haws.StaticZone(domain)
+	} else {
+		client, err := clients.Route53(ctx)
+		if err != nil {
+			fail(err)
+		}
+		zones = &haws.Route53Zones{Client: client}
+	}
+
+	h, err := haws.New(ctx, zones, dryRun,
+		viper.GetString("prefix"),
+		viper.GetString("region"),
+		viper.GetString("zone_id"),
+		viper.GetString("bucket_path"),
+		viper.GetString("record"),
+	)
+	if err != nil {
+		fail(err)
+	}
+	if err := h.SetClientProvider(ctx, clients); err != nil {
+		fail(err)
+	}
+	return h
+}
+
+// clientProvider creates the provider of the AWS clients from the flags and the config file
+// The [endpoints] table of the config file overrides the endpoint of single services, e.g. s3 = "http://localhost:4566"
+// return: *haws.ClientProvider - the provider
+// return: error - the error if an endpoint is set for an unknown service
+func clientProvider() (*haws.ClientProvider, error) {
+	endpoints := viper.GetStringMapString("endpoints")
+	for service := range endpoints {
+		switch service {
+		case haws.ServiceCloudFormation, haws.ServiceRoute53, haws.ServiceS3, haws.ServiceCloudFront, haws.ServiceACM, haws.ServiceSTS:
+		default:
+			return nil, fmt.Errorf("unknown service %s in [endpoints]", service)
+		}
+	}
+
+	return haws.NewClientProvider(haws.ClientOptions{
+		Profile:         viper.GetString("profile"),
+		AssumeRoleArn:   viper.GetString("assume-role-arn"),
+		ExternalId:      viper.GetString("external-id"),
+		MfaSerial:       viper.GetString("mfa-serial"),
+		SessionDuration: viper.GetDuration("session-duration"),
+		EndpointURL:     viper.GetString("endpoint-url"),
+		Endpoints:       endpoints,
+	}), nil
+}
+
+// configTimeouts reads the [timeouts] table of the config file
+// Each key is a stack name and each value a duration, e.g. cloudfront = "1h"
+// return: map[string]time.Duration - the timeouts indexed by stack name
+// return: error - the error if a duration is not valid
+func configTimeouts() (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for name, value := range viper.GetStringMapString("timeouts") {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for stack %s: %w", name, err)
+		}
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
+
+// configErrorPages reads the [errors] table of the config file
+// e.g. page = "/404.html", response-code = 404, caching-ttl = "1m" or spa = true
+// return: components.ErrorPages - the error pages
+// return: error - the error if the caching TTL is not a valid duration
+func configErrorPages() (components.ErrorPages, error) {
+	pages := components.ErrorPages{
+		Page:         viper.GetString("errors.page"),
+		ResponseCode: viper.GetInt("errors.response-code"),
+		SPA:          viper.GetBool("errors.spa"),
+	}
+	if value := viper.GetString("errors.caching-ttl"); value != "" {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return pages, fmt.Errorf("invalid caching TTL of the errors: %w", err)
+		}
+		pages.CachingTTL = ttl
+	}
+	return pages, nil
+}
+
+// cachePolicyConfig is a cache policy in the [cache] table of the config file
+type cachePolicyConfig struct {
+	Policy     string `mapstructure:"policy"`
+	MinTTL     string `mapstructure:"min-ttl"`
+	DefaultTTL string `mapstructure:"default-ttl"`
+	MaxTTL     string `mapstructure:"max-ttl"`
+}
+
+// cacheConfig is the [cache] table of the config file, with its [[cache.behaviors]]
+type cacheConfig struct {
+	cachePolicyConfig    `mapstructure:",squash"`
+	OriginRequestPolicy  string   `mapstructure:"origin-request-policy"`
+	OriginRequestHeaders []string `mapstructure:"origin-request-headers"`
+	Behaviors            []struct {
+		Path              string `mapstructure:"path"`
+		cachePolicyConfig `mapstructure:",squash"`
+	} `mapstructure:"behaviors"`
+}
+
+// policy converts the policy, defaulting the TTLs of a custom policy to 0, 1h and max(24h, default-ttl)
+// return: components.CachePolicy - the policy
+// return: error - the error if a TTL is not a valid duration
+func (p cachePolicyConfig) policy() (components.CachePolicy, error) {
+	policy := components.CachePolicy{Managed: p.Policy}
+	if p.Policy != "" {
+		if p.MinTTL != "" || p.DefaultTTL != "" || p.MaxTTL != "" {
+			return policy, fmt.Errorf("the TTLs cannot be set with the managed cache policy %s", p.Policy)
+		}
+		return policy, nil
+	}
+
+	policy.DefaultTTL = time.Hour
+	for _, ttl := range []struct {
+		value  string
+		target *time.Duration
+	}{{p.MinTTL, &policy.MinTTL}, {p.DefaultTTL, &policy.DefaultTTL}, {p.MaxTTL, &policy.MaxTTL}} {
+		if ttl.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(ttl.value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid TTL: %w", err)
+		}
+		*ttl.target = duration
+	}
+	if p.MaxTTL == "" {
+		policy.MaxTTL = max(24*time.Hour, policy.DefaultTTL)
+	}
+	return policy, nil
+}
+
+// configCache reads the [cache] table of the config file
+// return: components.Cache - the cache model (the default one without [cache] table)
+// return: error - the error if the table is not valid
+func configCache() (components.Cache, error) {
+	if !viper.IsSet("cache") {
+		return components.DefaultCache(), nil
+	}
+
+	var config cacheConfig
+	if err := viper.UnmarshalKey("cache", &config); err != nil {
+		return components.Cache{}, err
+	}
+	policy, err := config.policy()
+	if err != nil {
+		return components.Cache{}, err
+	}
+	cache := components.Cache{
+		Policy:               policy,
+		OriginRequestPolicy:  config.OriginRequestPolicy,
+		OriginRequestHeaders: config.OriginRequestHeaders,
+	}
+	for _, behavior := range config.Behaviors {
+		policy, err := behavior.policy()
+		if err != nil {
+			return components.Cache{}, fmt.Errorf("cache behavior %s: %w", behavior.Path, err)
+		}
+		cache.Behaviors = append(cache.Behaviors, components.CacheBehavior{PathPattern: behavior.Path, Policy: policy})
+	}
+	return cache, nil
+}
+
+// configDuration reads a duration of the config file
+// param: key - the key of the duration
+// param: target - the duration, left unchanged if the key is not set
+// return: error - the error if the value is not a valid duration
+func configDuration(key string, target *time.Duration) error {
+	if !viper.IsSet(key) {
+		return nil
+	}
+	duration, err := time.ParseDuration(viper.GetString(key))
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = duration
+	return nil
+}
+
+// configResponseHeaders reads the [headers] table of the config file, with its [headers.custom] and [headers.cors] tables
+// The keys that are not set keep the secure defaults
+// return: components.ResponseHeaders - the headers
+// return: error - the error if a duration is not valid
+func configResponseHeaders() (components.ResponseHeaders, error) {
+	headers := components.DefaultResponseHeaders()
+	if err := configDuration("headers.hsts-max-age", &headers.HSTSMaxAge); err != nil {
+		return headers, err
+	}
+	for key, target := range map[string]*bool{
+		"headers.hsts-include-subdomains": &headers.HSTSIncludeSubdomains,
+		"headers.hsts-preload":            &headers.HSTSPreload,
+		"headers.no-sniff":                &headers.NoSniff,
+	} {
+		if viper.IsSet(key) {
+			*target = viper.GetBool(key)
+		}
+	}
+	for key, target := range map[string]*string{
+		"headers.content-security-policy": &headers.ContentSecurityPolicy,
+		"headers.frame-options":           &headers.FrameOptions,
+		"headers.referrer-policy":         &headers.ReferrerPolicy,
+	} {
+		if viper.IsSet(key) {
+			*target = viper.GetString(key)
+		}
+	}
+	headers.Custom = viper.GetStringMapString("headers.custom")
+
+	if viper.IsSet("headers.cors") {
+		headers.Cors = &components.Cors{
+			AllowOrigins:  viper.GetStringSlice("headers.cors.allow-origins"),
+			AllowMethods:  viper.GetStringSlice("headers.cors.allow-methods"),
+			AllowHeaders:  viper.GetStringSlice("headers.cors.allow-headers"),
+			ExposeHeaders: viper.GetStringSlice("headers.cors.expose-headers"),
+		}
+		if err := configDuration("headers.cors.max-age", &headers.Cors.MaxAge); err != nil {
+			return headers, err
+		}
+	}
+	return headers, nil
+}
+
+// applyConfig sets the timeouts, the tags, the bucket readers, the hostnames, the cache, the response headers, the error pages and the imported resources from the config file on the stacks of the site
+func applyConfig(h *haws.Haws) {
+	timeouts, err := configTimeouts()
+	if err == nil {
+		err = h.SetTimeouts(timeouts)
+	}
+	if err != nil {
+		logger.Fatal("Failed to set the timeouts: %v", err)
+	}
+
+	h.SetTags(viper.GetStringMapString("tags"))
+	h.SetRoleArn(viper.GetString("role-arn"))
+	h.AllowAccountDistributions(viper.GetBool("allow-account-distributions"))
+
+	if err := h.SetHostnames(viper.GetStringSlice("aliases"), viper.GetBool("wildcard-certificate")); err != nil {
+		logger.Fatal("Failed to set the hostnames: %v", err)
+	}
+
+	cache, err := configCache()
+	if err == nil {
+		err = h.SetCache(cache)
+	}
+	if err != nil {
+		logger.Fatal("Failed to set the cache: %v", err)
+	}
+
+	headers, err := configResponseHeaders()
+	if err == nil {
+		err = h.SetResponseHeaders(headers)
+	}
+	if err != nil {
+		logger.Fatal("Failed to set the response headers: %v", err)
+	}
+
+	pages, err := configErrorPages()
+	if err == nil {
+		err = h.SetErrorPages(pages)
+	}
+	if err != nil {
+		logger.Fatal("Failed to set the error pages: %v", err)
+	}
+
+	if err := h.SetImports(viper.GetStringMapString("import")); err != nil {
+		logger.Fatal("Failed to set the imported resources: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watch         bool
+	watchInterval time.Duration
+
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show the status of the cloudformation stacks",
+		Long:  "Show the status, drift, termination protection and outputs of every stack, the certificate validation and the distribution deployment",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			h := newHaws(ctx, false)
+			applyConfig(&h)
+
+			for {
+				status, err := h.Status(ctx)
+				if err != nil {
+					fail(err)
+				}
+				if watch {
+					fmt.Printf("%s\n\n", time.Now().Format(time.RFC3339))
+				}
+				status.Print(os.Stdout)
+				if !watch || status.Stable() {
+					return
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(watchInterval):
+					fmt.Println()
+				}
+			}
+		},
+	}
+)
+
+func init() {
+	statusCmd.Flags().BoolVar(&watch, "watch", false, "Refresh the status until no operation is in progress")
+	statusCmd.Flags().DurationVar(&watchInterval, "interval", 15*time.Second, "Refresh interval with --watch")
+
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	synthOut     string
+	synthFormat  string
+	synthResolve bool
+
+	synthCmd = &cobra.Command{
+		Use:   "synth",
+		Short: "Write the cloudformation templates to disk",
+		Long:  "Write the template of every stack and a file with the values of their parameters, with sorted keys so they can be committed",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			// the templates are deterministic unless the outputs of the deployed stacks are asked for
+			h := newHaws(ctx, !synthResolve)
+			applyConfig(&h)
+
+			if err := h.Synth(ctx, synthOut, synthFormat); err != nil {
+				fail(err)
+			}
+		},
+	}
+)
+
+func init() {
+	synthCmd.Flags().StringVar(&synthOut, "out", "cdk.out", "The directory where the templates are written")
+	synthCmd.Flags().StringVar(&synthFormat, "format", "json", "The format of the templates (json or yaml)")
+
+	synthCmd.Flags().BoolVar(&synthResolve, "resolve", false, "Read the outputs of the other stacks from the deployed stacks instead of using placeholders")
+
+	rootCmd.AddCommand(synthCmd)
+}
@@ -3,8 +3,10 @@ package components
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dragosboca/haws/pkg/components/resources/bucketpolicy"
+	"github.com/dragosboca/haws/pkg/components/resources/cloudfrontext"
 	"github.com/dragosboca/haws/pkg/components/resources/customtags"
 	"github.com/dragosboca/haws/pkg/stack"
 
@@ -16,6 +18,9 @@ import (
 type Bucket struct {
 	stack.TemplateComponent
 	Prefix string
+	policy *bucketpolicy.Document
+
+	distributions []string
 }
 
 type BucketInput struct {
@@ -28,20 +33,17 @@ func NewBucket(b *BucketInput) *Bucket {
 	bucket := &Bucket{
 		Prefix:            b.Prefix,
 		TemplateComponent: stack.NewTemplate(b.Region),
+		policy:            bucketpolicy.New("PolicyForCloudfrontPrivateContent"),
 	}
+	bucket.Timeout = 15 * time.Minute
 
-	doc := bucketpolicy.New("PolicyForCloudfrontPrivateContent")
-	doc.AddStatement("haws", bucketpolicy.Statement{
-		Effect: "Allow",
-		Principal: bucketpolicy.Principal{
-			"AWS": cloudformation.Sub("arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity ${oai}"),
-		},
-		Action: []string{"s3:GetObject"},
-		Resource: []string{
-			cloudformation.Join("/", []string{cloudformation.GetAtt("bucket", "Arn"), "*"}),
-			cloudformation.GetAtt("bucket", "Arn"),
-		},
-	})
+	// the bucket is shared by the sites with the same prefix and deployed before their distributions,
+	// so the distributions allowed to read it are a parameter, set again once they are deployed
+	bucket.AddParameter("Distributions", cloudformation.Parameter{
+		Type:        "CommaDelimitedList",
+		Description: "The ARNs of the distributions allowed to read the bucket",
+	}, "")
+	bucket.AllowAccountDistributions(false)
 
 	bucket.AddParameter("BucketName",
 		cloudformation.Parameter{
@@ -51,10 +53,7 @@ func NewBucket(b *BucketInput) *Bucket {
 		strings.ToLower(fmt.Sprintf("haws-%s-%s-bucket", b.Prefix, strings.ReplaceAll(b.Domain, ".", "-"))),
 	)
 
-	bucket.AddResource("oai", &cloudfront.CloudFrontOriginAccessIdentity{
-		CloudFrontOriginAccessIdentityConfig: &cloudfront.CloudFrontOriginAccessIdentity_CloudFrontOriginAccessIdentityConfig{
-			Comment: cloudformation.Sub("haws oai for ${BucketName}"),
-		}})
+	bucket.AddResource("oac", cloudfrontext.NewOriginAccessControl(cloudformation.Ref("BucketName"), cloudformation.Sub("haws oac for ${BucketName}")))
 
 	bucket.AddResource("bucket", &s3.Bucket{
 		AccessControl:     "Private",
@@ -62,10 +61,12 @@ func NewBucket(b *BucketInput) *Bucket {
 		CorsConfiguration: nil,
 		Tags:              customtags.New(),
 	})
+	bucket.MarkCritical("bucket")
+	bucket.AddImportable("bucket", "BucketName")
 
 	bucket.AddResource("policy", &s3.BucketPolicy{
 		Bucket:         cloudformation.Ref("bucket"),
-		PolicyDocument: doc,
+		PolicyDocument: bucket.policy,
 	})
 
 	bucket.AddOutput("Domain", cloudformation.Output{
@@ -92,15 +93,112 @@ func NewBucket(b *BucketInput) *Bucket {
 		},
 	}, "mockBucket")
 
-	bucket.AddOutput("OAI", cloudformation.Output{
+	bucket.AddOutput("OAC", cloudformation.Output{
+		Value:       cloudformation.GetAtt("oac", "Id"),
+		Description: "Origin Access Control for Cloudfront",
+		Export: &cloudformation.Export{
+			Name: bucket.GetExportName("Oac"),
+		},
+	}, "MockOac")
+
+	return bucket
+}
+
+// SetDistributions sets the distributions allowed to read the bucket with the origin access control
+// param: arns - the ARNs of the distributions of the sites sharing the bucket
+// return: error - the error if any
+func (b *Bucket) SetDistributions(arns []string) error {
+	b.distributions = arns
+	return b.SetParameterValue("Distributions", strings.Join(arns, ","))
+}
+
+// Distributions returns the ARNs of the distributions allowed to read the bucket
+func (b *Bucket) Distributions() []string {
+	return b.distributions
+}
+
+// AllowAccountDistributions lets every distribution of the account signing with an origin access control read the bucket,
+// instead of the distributions of the sites sharing it
+// param: allow - true to allow all the distributions of the account
+func (b *Bucket) AllowAccountDistributions(allow bool) {
+	condition := bucketpolicy.Condition{
+		"StringEquals": {
+			"AWS:SourceArn": cloudformation.Ref("Distributions"),
+		},
+	}
+	if allow {
+		condition = bucketpolicy.Condition{
+			"StringLike": {
+				"AWS:SourceArn": cloudformation.Sub("arn:aws:cloudfront::${AWS::AccountId}:distribution/*"),
+			},
+		}
+	}
+
+	statements := make([]bucketpolicy.Statement, 0, len(b.policy.Statement))
+	for _, s := range b.policy.Statement {
+		if s.Sid != "haws" {
+			statements = append(statements, s)
+		}
+	}
+	b.policy.Statement = statements
+	b.policy.AddStatement("haws", bucketpolicy.Statement{
+		Effect: "Allow",
+		Principal: bucketpolicy.Principal{
+			"Service": "cloudfront.amazonaws.com",
+		},
+		Action:    []string{"s3:GetObject"},
+		Resource:  b.contentArns(),
+		Condition: condition,
+	})
+}
+
+// contentArns returns the ARNs of the bucket and of its objects
+func (b *Bucket) contentArns() []string {
+	return []string{
+		cloudformation.Join("/", []string{cloudformation.GetAtt("bucket", "Arn"), "*"}),
+		cloudformation.GetAtt("bucket", "Arn"),
+	}
+}
+
+// KeepOAI keeps or removes the legacy Origin Access Identity of the sites deployed before the Origin Access Control
+// The identity, its export and its statement in the bucket policy are kept while a distribution still uses them
+// param: keep - true to keep the identity
+func (b *Bucket) KeepOAI(keep bool) {
+	delete(b.Resources, "oai")
+	delete(b.Outputs, "OAI")
+	delete(b.DryRunOutputs, "OAI")
+	statements := make([]bucketpolicy.Statement, 0, len(b.policy.Statement))
+	for _, s := range b.policy.Statement {
+		if s.Sid != "legacyoai" {
+			statements = append(statements, s)
+		}
+	}
+	b.policy.Statement = statements
+	if !keep {
+		return
+	}
+
+	b.AddResource("oai", &cloudfront.CloudFrontOriginAccessIdentity{
+		CloudFrontOriginAccessIdentityConfig: &cloudfront.CloudFrontOriginAccessIdentity_CloudFrontOriginAccessIdentityConfig{
+			Comment: cloudformation.Sub("haws oai for ${BucketName}"),
+		}})
+
+	b.policy.AddStatement("legacyoai", bucketpolicy.Statement{
+		Effect: "Allow",
+		Principal: bucketpolicy.Principal{
+			"AWS": cloudformation.Sub("arn:aws:iam::cloudfront:user/CloudFront Origin Access Identity ${oai}"),
+		},
+		Action:   []string{"s3:GetObject"},
+		Resource: b.contentArns(),
+	})
+
+	b.AddOutput("OAI", cloudformation.Output{
 		Value:       cloudformation.Ref("oai"),
 		Description: "Origin Access Identity for Cloudfront",
 		Export: &cloudformation.Export{
-			Name: bucket.GetExportName("Oai"),
+			Name: b.GetExportName("Oai"),
 		},
 	}, "MockOai")
-
-	return bucket
 }
 
 func (b *Bucket) GetExportName(output string) string {
@@ -0,0 +1,205 @@
+package components
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/cloudfront"
+)
+
+// managedCachePolicies are the ids of the cache policies managed by AWS, indexed by name
+var managedCachePolicies = map[string]string{
+	"CachingOptimized":                       "658327ea-f89d-4fab-a63d-7e88639e58f6",
+	"CachingOptimizedForUncompressedObjects": "b2884449-e4de-46a7-ac36-70bc7f1ddd6d",
+	"CachingDisabled":                        "4135ea2d-6df8-44a3-9df3-4b5a84be39ad",
+}
+
+// managedOriginRequestPolicies are the ids of the origin request policies managed by AWS that suit an S3 origin, indexed by name
+var managedOriginRequestPolicies = map[string]string{
+	"CORS-S3Origin":           "88a5eaf4-2fd4-4709-b370-b4c650ea3fcf",
+	"UserAgentRefererHeaders": "acba4595-bd28-49b8-b9fe-13317c0390fa",
+}
+
+// maxCacheBehaviors is the number of cache behaviors of a distribution allowed by the default CloudFront quota
+const maxCacheBehaviors = 25
+
+// CachePolicy is the cache model of the paths of a cache behavior
+// The custom policies compress the objects with gzip and brotli and keep cookies, headers and query strings out of the cache key
+type CachePolicy struct {
+	// Managed is the name of a managed cache policy (CachingOptimized, CachingOptimizedForUncompressedObjects or CachingDisabled), empty for a custom policy
+	Managed string
+	// MinTTL, DefaultTTL and MaxTTL are the TTLs of a custom policy
+	MinTTL     time.Duration
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+// CacheBehavior caches the paths matching a pattern with their own policy
+type CacheBehavior struct {
+	// PathPattern selects the paths, e.g. /css/* or *.html
+	PathPattern string
+	Policy      CachePolicy
+}
+
+// Cache is the cache model of the distribution
+type Cache struct {
+	// Policy is the cache policy of the paths not matched by the behaviors
+	Policy CachePolicy
+	// OriginRequestPolicy is the name of a managed origin request policy (CORS-S3Origin or UserAgentRefererHeaders), empty for none
+	OriginRequestPolicy string
+	// OriginRequestHeaders are the headers forwarded to the origin by a custom origin request policy
+	OriginRequestHeaders []string
+	// Behaviors are the extra cache behaviors, matched in order
+	Behaviors []CacheBehavior
+}
+
+// DefaultCache returns the cache model of the sites without cache configuration: a custom policy caching 1 hour, 24 hours at most
+// return: Cache - the cache model
+func DefaultCache() Cache {
+	return Cache{
+		Policy: CachePolicy{DefaultTTL: time.Hour, MaxTTL: 24 * time.Hour},
+	}
+}
+
+// validate checks the policy
+func (p CachePolicy) validate() error {
+	if p.Managed != "" {
+		if _, ok := managedCachePolicies[p.Managed]; !ok {
+			return fmt.Errorf("unknown managed cache policy %s", p.Managed)
+		}
+		if p.MinTTL != 0 || p.DefaultTTL != 0 || p.MaxTTL != 0 {
+			return fmt.Errorf("the TTLs cannot be set with the managed cache policy %s", p.Managed)
+		}
+		return nil
+	}
+	if p.MinTTL < 0 || p.MinTTL > p.DefaultTTL || p.DefaultTTL > p.MaxTTL {
+		return fmt.Errorf("the TTLs must be 0 <= min-ttl (%s) <= default-ttl (%s) <= max-ttl (%s)", p.MinTTL, p.DefaultTTL, p.MaxTTL)
+	}
+	return nil
+}
+
+// validate checks the cache model
+func (c Cache) validate() error {
+	if err := c.Policy.validate(); err != nil {
+		return err
+	}
+	if c.OriginRequestPolicy != "" {
+		if _, ok := managedOriginRequestPolicies[c.OriginRequestPolicy]; !ok {
+			return fmt.Errorf("unknown managed origin request policy %s", c.OriginRequestPolicy)
+		}
+		if len(c.OriginRequestHeaders) > 0 {
+			return fmt.Errorf("the origin request headers cannot be set with the managed origin request policy %s", c.OriginRequestPolicy)
+		}
+	}
+	if len(c.Behaviors) > maxCacheBehaviors {
+		return fmt.Errorf("a distribution has at most %d cache behaviors, got %d", maxCacheBehaviors, len(c.Behaviors))
+	}
+
+	patterns := make(map[string]bool)
+	for _, behavior := range c.Behaviors {
+		if behavior.PathPattern == "" {
+			return fmt.Errorf("a cache behavior has no path pattern")
+		}
+		if patterns[behavior.PathPattern] {
+			return fmt.Errorf("duplicated cache behavior for %s", behavior.PathPattern)
+		}
+		patterns[behavior.PathPattern] = true
+		if err := behavior.Policy.validate(); err != nil {
+			return fmt.Errorf("cache behavior %s: %w", behavior.PathPattern, err)
+		}
+	}
+	return nil
+}
+
+// SetCache sets the cache policies of the default behavior and the extra cache behaviors of the distribution
+// The custom cache policies with the same TTLs are shared by the behaviors
+// param: cache - the cache model
+// return: error - the error if the cache model is not valid
+func (c *Cdn) SetCache(cache Cache) error {
+	if err := cache.validate(); err != nil {
+		return err
+	}
+
+	for _, name := range c.cacheResources {
+		delete(c.Resources, name)
+	}
+	c.cacheResources = nil
+
+	config := c.distribution.DistributionConfig
+	config.DefaultCacheBehavior.CachePolicyId = c.cachePolicy(cache.Policy)
+	config.DefaultCacheBehavior.OriginRequestPolicyId = c.originRequestPolicy(cache)
+
+	config.CacheBehaviors = nil
+	for _, behavior := range cache.Behaviors {
+		config.CacheBehaviors = append(config.CacheBehaviors, cloudfront.Distribution_CacheBehavior{
+			PathPattern:           behavior.PathPattern,
+			TargetOriginId:        config.DefaultCacheBehavior.TargetOriginId,
+			ViewerProtocolPolicy:  config.DefaultCacheBehavior.ViewerProtocolPolicy,
+			AllowedMethods:        config.DefaultCacheBehavior.AllowedMethods,
+			CachedMethods:         config.DefaultCacheBehavior.CachedMethods,
+			Compress:              true,
+			CachePolicyId:         c.cachePolicy(behavior.Policy),
+			OriginRequestPolicyId: config.DefaultCacheBehavior.OriginRequestPolicyId,
+			FunctionAssociations:  config.DefaultCacheBehavior.FunctionAssociations,
+		})
+	}
+	return nil
+}
+
+// cachePolicy returns the id of a cache policy, adding the custom policies to the template
+func (c *Cdn) cachePolicy(policy CachePolicy) string {
+	if policy.Managed != "" {
+		return managedCachePolicies[policy.Managed]
+	}
+
+	name := fmt.Sprintf("cache%dx%dx%d", int64(policy.MinTTL.Seconds()), int64(policy.DefaultTTL.Seconds()), int64(policy.MaxTTL.Seconds()))
+	if _, ok := c.Resources[name]; !ok {
+		c.AddResource(name, &cloudfront.CachePolicy{
+			CachePolicyConfig: &cloudfront.CachePolicy_CachePolicyConfig{
+				Name:       c.resourceName(name),
+				Comment:    "Cache policy of a haws site",
+				MinTTL:     policy.MinTTL.Seconds(),
+				DefaultTTL: policy.DefaultTTL.Seconds(),
+				MaxTTL:     policy.MaxTTL.Seconds(),
+				ParametersInCacheKeyAndForwardedToOrigin: &cloudfront.CachePolicy_ParametersInCacheKeyAndForwardedToOrigin{
+					EnableAcceptEncodingGzip:   true,
+					EnableAcceptEncodingBrotli: true,
+					CookiesConfig:              &cloudfront.CachePolicy_CookiesConfig{CookieBehavior: "none"},
+					HeadersConfig:              &cloudfront.CachePolicy_HeadersConfig{HeaderBehavior: "none"},
+					QueryStringsConfig:         &cloudfront.CachePolicy_QueryStringsConfig{QueryStringBehavior: "none"},
+				},
+			},
+		})
+		c.cacheResources = append(c.cacheResources, name)
+	}
+	return cloudformation.Ref(name)
+}
+
+// originRequestPolicy returns the id of the origin request policy, adding a custom policy to the template
+func (c *Cdn) originRequestPolicy(cache Cache) string {
+	if cache.OriginRequestPolicy != "" {
+		return managedOriginRequestPolicies[cache.OriginRequestPolicy]
+	}
+	if len(cache.OriginRequestHeaders) == 0 {
+		return ""
+	}
+
+	headers := append([]string(nil), cache.OriginRequestHeaders...)
+	sort.Strings(headers)
+	c.AddResource("originrequest", &cloudfront.OriginRequestPolicy{
+		OriginRequestPolicyConfig: &cloudfront.OriginRequestPolicy_OriginRequestPolicyConfig{
+			Name:    c.resourceName("origin-request"),
+			Comment: "Origin request policy of a haws site",
+			HeadersConfig: &cloudfront.OriginRequestPolicy_HeadersConfig{
+				HeaderBehavior: "whitelist",
+				Headers:        headers,
+			},
+			CookiesConfig:      &cloudfront.OriginRequestPolicy_CookiesConfig{CookieBehavior: "none"},
+			QueryStringsConfig: &cloudfront.OriginRequestPolicy_QueryStringsConfig{QueryStringBehavior: "none"},
+		},
+	})
+	c.cacheResources = append(c.cacheResources, "originrequest")
+	return cloudformation.Ref("originrequest")
+}
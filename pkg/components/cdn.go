@@ -3,7 +3,10 @@ package components
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/dragosboca/haws/pkg/components/resources/cloudfrontext"
+	"github.com/dragosboca/haws/pkg/components/resources/viewerfunction"
 	"github.com/dragosboca/haws/pkg/stack"
 
 	"github.com/awslabs/goformation/v4/cloudformation"
@@ -18,21 +21,30 @@ type Cdn struct {
 	Prefix     string
 	Domain     string
 	Path       string
+
+	distribution   *cloudfrontext.Distribution
+	cacheResources []string
+	aliases        []string
 }
 
+// indexDocument is the document served for the root and the directories of the site
+const indexDocument = "index.html"
+
 type CdnInput struct {
-	Prefix         string
-	Path           string
-	Region         string
-	Domain         string
-	Record         string
-	CertificateArn string
-	BucketDomain   string
-	BucketOAI      string
-	ZoneId         string
+	Prefix       string
+	Path         string
+	Region       string
+	Domain       string
+	Record       string
+	BucketDomain string
+	BucketOAC    string
+	ZoneId       string
 }
 
-func NewCdn(c *CdnInput) *Cdn {
+// NewCdn builds the template of the distribution of a site, with the default cache and response headers
+// return: *Cdn - the distribution
+// return: error - the error if any
+func NewCdn(c *CdnInput) (*Cdn, error) {
 
 	// format path for cloudformation
 	path := fmt.Sprintf("/%s", strings.Trim(c.Path, "/"))
@@ -50,16 +62,21 @@ func NewCdn(c *CdnInput) *Cdn {
 		TemplateComponent: stack.NewTemplate(c.Region),
 		recordName:        recordName,
 	}
+	// creating or updating a distribution can take a long time
+	cdn.Timeout = 45 * time.Minute
+	cdn.AddDependency("bucket")
 
 	cdn.AddParameter("RecordName", cloudformation.Parameter{
 		Type:        "String",
 		Description: "Record name for Route53 domain",
 	}, recordName)
 
+	// the certificate is created in us-east-1 and its export cannot be imported from another region
 	cdn.AddParameter("CertificateArn", cloudformation.Parameter{
 		Type:        "String",
-		Description: "The ARN of the certificate generated in us-east-1 for cloudfront distribution", // FIXME: this is not working because one cannot reference a resource from another region
-	}, c.CertificateArn)
+		Description: "The ARN of the certificate generated in us-east-1 for cloudfront distribution",
+	}, "")
+	cdn.AddReference("CertificateArn", "certificate", "Arn")
 
 	cdn.AddParameter("ZoneId", cloudformation.Parameter{
 		Type:        "String",
@@ -76,25 +93,41 @@ func NewCdn(c *CdnInput) *Cdn {
 		AccessControl: "private",
 	})
 
-	cdn.AddResource("distribution", &cloudfront.Distribution{
-		DistributionConfig: &cloudfront.Distribution_DistributionConfig{
+	// the private S3 origin does not map the directories to their index.html
+	code, err := viewerfunction.PrettyUrls{IndexDocument: indexDocument}.Code()
+	if err != nil {
+		return nil, err
+	}
+	cdn.AddResource("prettyurls", &cloudfront.Function{
+		Name:         cdn.resourceName("pretty-urls"),
+		AutoPublish:  true,
+		FunctionCode: code,
+		FunctionConfig: &cloudfront.Function_FunctionConfig{
+			Comment: "Serve the index.html of the directories",
+			Runtime: viewerfunction.Runtime,
+		},
+	})
+
+	cdn.distribution = &cloudfrontext.Distribution{
+		Distribution: cloudfront.Distribution{DistributionConfig: &cloudfront.Distribution_DistributionConfig{
 			Aliases: []string{
 				cloudformation.Ref("RecordName"),
 			},
 			DefaultCacheBehavior: &cloudfront.Distribution_DefaultCacheBehavior{
 				AllowedMethods: []string{"HEAD", "GET", "OPTIONS"},
-				ForwardedValues: &cloudfront.Distribution_ForwardedValues{
-					Cookies: &cloudfront.Distribution_Cookies{
-						Forward: "none",
+				CachedMethods:  []string{"HEAD", "GET"},
+				Compress:       true,
+				FunctionAssociations: []cloudfront.Distribution_FunctionAssociation{
+					{
+						EventType:   "viewer-request",
+						FunctionARN: cloudformation.GetAtt("prettyurls", "FunctionARN"),
 					},
 				},
-				MaxTTL:               86400,
-				DefaultTTL:           3600,
 				ViewerProtocolPolicy: "redirect-to-https",
 				TargetOriginId:       "cloudfront-hugo",
 			},
 			Comment:           "Cloudfront for hugo website",
-			DefaultRootObject: "index.html",
+			DefaultRootObject: indexDocument,
 			Enabled:           true,
 			HttpVersion:       "http2",
 			IPV6Enabled:       true,
@@ -108,21 +141,30 @@ func NewCdn(c *CdnInput) *Cdn {
 					DomainName: cloudformation.ImportValue(c.BucketDomain),
 					Id:         "cloudfront-hugo",
 					OriginPath: c.Path,
-					S3OriginConfig: &cloudfront.Distribution_S3OriginConfig{
-						OriginAccessIdentity: cloudformation.Join("/", []string{
-							"origin-access-identity/cloudfront",
-							cloudformation.ImportValue(c.BucketOAI),
-						}),
-					},
+					// the requests are signed with the origin access control, not an origin access identity
+					S3OriginConfig: &cloudfront.Distribution_S3OriginConfig{},
 				},
 			},
 			ViewerCertificate: &cloudfront.Distribution_ViewerCertificate{
-				AcmCertificateArn:      cloudformation.Ref("CertificateArn"), // FIXME: here!
+				AcmCertificateArn:      cloudformation.Ref("CertificateArn"),
 				MinimumProtocolVersion: "TLSv1.2_2019",
 				SslSupportMethod:       "sni-only",
 			},
+		}},
+		OriginAccessControls: map[string]string{
+			"cloudfront-hugo": cloudformation.ImportValue(c.BucketOAC),
 		},
-	})
+	}
+	cdn.AddResource("distribution", cdn.distribution)
+	if err := cdn.SetCache(DefaultCache()); err != nil {
+		return nil, err
+	}
+	if err := cdn.SetResponseHeaders(DefaultResponseHeaders()); err != nil {
+		return nil, err
+	}
+
+	cdn.MarkCritical("distribution")
+	cdn.AddImportable("distribution", "")
 
 	cdn.AddResource("recordset", &route53.RecordSet{
 		AliasTarget: &route53.RecordSet_AliasTarget{
@@ -151,7 +193,166 @@ func NewCdn(c *CdnInput) *Cdn {
 		},
 	}, "arn:aws:cloudfront::123456789012:distribution/EDFDVBD632BHDS5")
 
-	return cdn
+	return cdn, nil
+}
+
+// resourceName returns the name of a CloudFront resource of the site, unique in the account
+// param: suffix - the suffix identifying the resource in the site
+// return: string - the name
+func (c *Cdn) resourceName(suffix string) string {
+	return viewerfunction.Name(c.Prefix, c.recordName, suffix)
+}
+
+// SetAliases sets the other hostnames of the site, served by the distribution with a record in the zone
+// param: aliases - the hostnames, in the domain of the zone (none removes the aliases)
+// return: error - the error if an alias is not in the zone
+func (c *Cdn) SetAliases(aliases []string) error {
+	names := make([]string, 0, len(aliases))
+	seen := map[string]bool{c.recordName: true}
+	for _, alias := range aliases {
+		alias = strings.TrimSuffix(strings.ToLower(alias), ".")
+		if alias != c.Domain && !strings.HasSuffix(alias, "."+c.Domain) {
+			return fmt.Errorf("the alias %s is not in the zone %s", alias, c.Domain)
+		}
+		if strings.HasPrefix(alias, "*") {
+			return fmt.Errorf("the alias %s is a wildcard, each hostname needs its record", alias)
+		}
+		if !seen[alias] {
+			seen[alias] = true
+			names = append(names, alias)
+		}
+	}
+
+	for _, alias := range c.aliases {
+		delete(c.Resources, aliasResource(alias))
+	}
+	c.aliases = names
+
+	c.distribution.DistributionConfig.Aliases = append([]string{cloudformation.Ref("RecordName")}, names...)
+	for _, alias := range names {
+		c.AddResource(aliasResource(alias), &route53.RecordSet{
+			AliasTarget: &route53.RecordSet_AliasTarget{
+				DNSName:      cloudformation.GetAtt("distribution", "DomainName"),
+				HostedZoneId: "Z2FDTNDATAQYW2",
+			},
+			Comment:      "alias record for hugo website",
+			HostedZoneId: cloudformation.Ref("ZoneId"),
+			Name:         alias,
+			Type:         "A",
+		})
+	}
+	return nil
+}
+
+// aliasResource returns the logical id of the record of an alias
+// param: alias - the hostname
+// return: string - the logical id, e.g. aliasblogexamplecom
+func aliasResource(alias string) string {
+	return "alias" + strings.NewReplacer(".", "", "-", "").Replace(alias)
+}
+
+// Hostnames returns the hostnames served by the distribution: the record of the site, then the aliases
+func (c *Cdn) Hostnames() []string {
+	return append([]string{c.recordName}, c.aliases...)
+}
+
+// DistributionSettings are the settings of a distribution compared with the live one before it is imported
+type DistributionSettings struct {
+	OriginDomain           string
+	OriginPath             string
+	CertificateArn         string
+	ViewerProtocolPolicy   string
+	MinimumProtocolVersion string
+	SslSupportMethod       string
+}
+
+// ImportSettings returns the origin path and the viewer protocol settings of the distribution
+// The origin domain and the certificate come from other stacks and are left empty
+// return: DistributionSettings - the settings of the template
+func (c *Cdn) ImportSettings() DistributionSettings {
+	config := c.distribution.DistributionConfig
+	return DistributionSettings{
+		OriginPath:             config.Origins[0].OriginPath,
+		ViewerProtocolPolicy:   config.DefaultCacheBehavior.ViewerProtocolPolicy,
+		MinimumProtocolVersion: config.ViewerCertificate.MinimumProtocolVersion,
+		SslSupportMethod:       config.ViewerCertificate.SslSupportMethod,
+	}
+}
+
+// ErrorPages configures the pages served instead of the errors of the origin
+// The private S3 origin answers 403 (not 404) for the missing objects, so both are mapped
+type ErrorPages struct {
+	// Page is the path of the page served for the missing pages, relative to the site (e.g. /404.html)
+	Page string
+	// ResponseCode is the status code returned with the page (default 404)
+	ResponseCode int
+	// CachingTTL is how long CloudFront caches the error (0 for the default of CloudFront, 10 seconds)
+	CachingTTL time.Duration
+	// SPA serves the index.html of the site with status 200 for the unknown routes of a single page application
+	SPA bool
+}
+
+// errorResponseCodes are the status codes CloudFront can return with a custom error page
+var errorResponseCodes = map[int]bool{
+	200: true, 400: true, 403: true, 404: true, 405: true, 414: true, 416: true,
+	500: true, 501: true, 502: true, 503: true, 504: true,
+}
+
+// SetErrorPages sets the pages served for the 403 and 404 errors of the origin
+// param: pages - the error pages (an empty page and no SPA mode serve the errors of the origin)
+// return: error - the error if the configuration is not valid
+func (c *Cdn) SetErrorPages(pages ErrorPages) error {
+	if pages.CachingTTL < 0 {
+		return fmt.Errorf("the caching TTL of the errors cannot be negative")
+	}
+
+	page, code := pages.Page, pages.ResponseCode
+	switch {
+	case pages.SPA:
+		if page != "" || code != 0 {
+			return fmt.Errorf("the SPA mode serves /%s with status 200, the error page cannot be set", indexDocument)
+		}
+		page, code = "/"+indexDocument, 200
+	case page == "":
+		if code != 0 {
+			return fmt.Errorf("the response code %d is set without an error page", code)
+		}
+		c.distribution.DistributionConfig.CustomErrorResponses = nil
+		return nil
+	default:
+		page = c.sitePath(page)
+		if code == 0 {
+			code = 404
+		}
+		if !errorResponseCodes[code] {
+			return fmt.Errorf("CloudFront cannot return the error page with status %d", code)
+		}
+	}
+
+	responses := make([]cloudfront.Distribution_CustomErrorResponse, 0, 2)
+	for _, errorCode := range []int{403, 404} {
+		responses = append(responses, cloudfront.Distribution_CustomErrorResponse{
+			ErrorCode:          errorCode,
+			ErrorCachingMinTTL: pages.CachingTTL.Seconds(),
+			ResponseCode:       code,
+			ResponsePagePath:   page,
+		})
+	}
+	c.distribution.DistributionConfig.CustomErrorResponses = responses
+	return nil
+}
+
+// sitePath returns the path of a page as requested by the viewers
+// CloudFront adds the bucket path (the origin path) when it fetches the page, so a page given with it is made relative to the site
+// param: page - the path of the page
+// return: string - the path, starting with a slash
+func (c *Cdn) sitePath(page string) string {
+	page = "/" + strings.TrimLeft(page, "/")
+	prefix := "/" + strings.Trim(c.Path, "/")
+	if prefix != "/" && strings.HasPrefix(page, prefix+"/") {
+		page = strings.TrimPrefix(page, prefix)
+	}
+	return page
 }
 
 func (c *Cdn) GetExportName(output string) string {
@@ -3,6 +3,7 @@ package components
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dragosboca/haws/pkg/components/resources/customtags"
 	"github.com/dragosboca/haws/pkg/stack"
@@ -14,6 +15,10 @@ import (
 type Certificate struct {
 	stack.TemplateComponent
 	Prefix string
+	Domain string
+
+	// site is the first hostname of the site owning the certificate, empty for the wildcard certificate shared by the prefix
+	site string
 }
 
 type CertificateInput struct {
@@ -21,54 +26,119 @@ type CertificateInput struct {
 	Region string
 	Domain string
 	ZoneId string
+	// Names are the hostnames the certificate covers (default: the domain)
+	Names []string
+	// Wildcard covers the domain and *.domain instead of the names, so the sites with the same prefix share the certificate
+	Wildcard bool
 }
 
-func NewCertificate(c *CertificateInput) *Certificate {
+// NewCertificate builds the template of the certificate of the hostnames of a site
+// return: *Certificate - the certificate
+// return: error - the error if a name is not valid (see SetNames)
+func NewCertificate(c *CertificateInput) (*Certificate, error) {
 	certificate := &Certificate{
 		Prefix:            c.Prefix,
+		Domain:            strings.TrimSuffix(strings.ToLower(c.Domain), "."),
 		TemplateComponent: stack.NewTemplate("us-east-1"),
 	}
+	// the certificate validation waits for the DNS propagation
+	certificate.Timeout = 60 * time.Minute
 
 	certificate.AddParameter("Domain", cloudformation.Parameter{
 		Type:        "String",
 		Description: "Domain for which we generate the certificate",
-	}, c.Domain)
+	}, certificate.Domain)
 
 	certificate.AddParameter("ZoneId", cloudformation.Parameter{
 		Type:        "String",
 		Description: "The Route53 zone used for domain validation",
 	}, c.ZoneId)
 
-	certificate.AddResource("HugoSslCertificate", &certificatemanager.Certificate{
-		DomainName: cloudformation.Ref("Domain"),
-		DomainValidationOptions: []certificatemanager.Certificate_DomainValidationOption{{
-			DomainName:   cloudformation.Ref("Domain"),
+	if err := certificate.SetNames(c.Names, c.Wildcard); err != nil {
+		return nil, err
+	}
+	return certificate, nil
+}
+
+// SetNames sets the hostnames covered by the certificate, each validated with its own record in the zone
+// param: names - the hostnames of the site, in the domain of the zone (none for the domain itself)
+// param: wildcard - cover the domain and *.domain instead, sharing the certificate with the sites of the prefix
+// return: error - the error if a name is not in the zone, or not covered by the wildcard
+func (c *Certificate) SetNames(names []string, wildcard bool) error {
+	hostnames := make([]string, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSuffix(strings.ToLower(name), ".")
+		if strings.HasPrefix(name, "*") {
+			return fmt.Errorf("the hostname %s is a wildcard, use the wildcard certificate of %s instead", name, c.Domain)
+		}
+		if name != c.Domain && !strings.HasSuffix(name, "."+c.Domain) {
+			return fmt.Errorf("the hostname %s is not in the zone %s", name, c.Domain)
+		}
+		if wildcard && name != c.Domain && strings.Contains(strings.TrimSuffix(name, "."+c.Domain), ".") {
+			return fmt.Errorf("the hostname %s is not covered by the wildcard certificate of %s", name, c.Domain)
+		}
+		if !seen[name] {
+			seen[name] = true
+			hostnames = append(hostnames, name)
+		}
+	}
+	if len(hostnames) == 0 {
+		hostnames = append(hostnames, c.Domain)
+	}
+
+	c.site = hostnames[0]
+	if wildcard {
+		c.site = ""
+		hostnames = []string{c.Domain, "*." + c.Domain}
+	}
+	if err := c.SetParameterValue("Domain", hostnames[0]); err != nil {
+		return err
+	}
+
+	// the first name is the subject of the certificate, the others its alternative names
+	options := []certificatemanager.Certificate_DomainValidationOption{{
+		DomainName:   cloudformation.Ref("Domain"),
+		HostedZoneId: cloudformation.Ref("ZoneId"),
+	}}
+	var alternatives []string
+	for _, name := range hostnames[1:] {
+		alternatives = append(alternatives, name)
+		options = append(options, certificatemanager.Certificate_DomainValidationOption{
+			DomainName:   name,
 			HostedZoneId: cloudformation.Ref("ZoneId"),
-		}},
-		SubjectAlternativeNames: []string{
-			cloudformation.Ref("Domain"),
-		},
-		ValidationMethod: "DNS",
+		})
+	}
+
+	c.AddResource("HugoSslCertificate", &certificatemanager.Certificate{
+		DomainName:              cloudformation.Ref("Domain"),
+		DomainValidationOptions: options,
+		SubjectAlternativeNames: alternatives,
+		ValidationMethod:        "DNS",
 
 		Tags: customtags.New(),
 	})
 
-	certificate.AddOutput("Arn", cloudformation.Output{
+	c.AddOutput("Arn", cloudformation.Output{
 		Value:       cloudformation.Ref("HugoSslCertificate"),
 		Description: "ARN of certificate created in us-east-1 for the cloudfront distribution",
 		Export: &cloudformation.Export{
-			Name: certificate.GetExportName("Arn"),
+			Name: c.GetExportName("Arn"),
 		},
 	}, "arn:aws:acm:us-east-1:123456789012:certificate/123456789012-1234-1234-1234-12345678")
-
-	return certificate
+	return nil
 }
 
 func (c *Certificate) GetExportName(output string) string {
-	return fmt.Sprintf("HawsCertificate%s%s", output, strings.Title(c.Prefix))
+	// the labels of the hostname, e.g. WwwExampleCom
+	site := strings.ReplaceAll(strings.Title(strings.ReplaceAll(c.site, ".", " ")), " ", "")
+	return fmt.Sprintf("HawsCertificate%s%s%s", output, strings.Title(c.Prefix), site)
 }
 
 func (c *Certificate) GetStackName() *string {
 	stackName := fmt.Sprintf("%s-certificate", c.Prefix)
+	if c.site != "" {
+		stackName = fmt.Sprintf("%s-%s-certificate", c.Prefix, strings.ReplaceAll(c.site, ".", "-"))
+	}
 	return &stackName
 }
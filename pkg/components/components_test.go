@@ -1,6 +1,15 @@
 package components
 
-import "testing"
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragosboca/haws/pkg/components/resources/cloudfrontext"
+
+	"github.com/awslabs/goformation/v4/cloudformation/certificatemanager"
+)
 
 func TestNewBucketAndExports(t *testing.T) {
 	b := NewBucket(&BucketInput{Prefix: "test", Region: "us-east-1", Domain: "example.com"})
@@ -16,7 +25,10 @@ func TestNewBucketAndExports(t *testing.T) {
 }
 
 func TestNewCertificateAndExports(t *testing.T) {
-	c := NewCertificate(&CertificateInput{Prefix: "test", Region: "us-east-1", Domain: "example.com", ZoneId: "zone"})
+	c, err := NewCertificate(&CertificateInput{Prefix: "test", Region: "us-east-1", Domain: "example.com", ZoneId: "zone"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if c == nil {
 		t.Fatal("NewCertificate returned nil")
 	}
@@ -26,10 +38,92 @@ func TestNewCertificateAndExports(t *testing.T) {
 	if c.GetStackName() == nil || *c.GetStackName() == "" {
 		t.Error("GetStackName should not return nil or empty string")
 	}
+
+	if _, err := NewCertificate(&CertificateInput{Prefix: "test", Domain: "example.com", ZoneId: "zone", Names: []string{"*.example.com"}}); err == nil {
+		t.Error("Expected an error for an invalid name")
+	}
+}
+
+func TestCertificateNames(t *testing.T) {
+	c, err := NewCertificate(&CertificateInput{Prefix: "test", Domain: "example.com", ZoneId: "zone", Names: []string{"www.example.com", "Example.com.", "www.example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := *c.GetStackName(); name != "test-www-example-com-certificate" {
+		t.Errorf("Expected the certificate of the site, got %s", name)
+	}
+	if name := c.GetExportName("Arn"); name != "HawsCertificateArnTestWwwExampleCom" {
+		t.Errorf("Expected the export of the site, got %s", name)
+	}
+	if value := *c.GetParameters()[0].ParameterValue; value != "www.example.com" {
+		t.Errorf("Expected the record as the subject, got %s", value)
+	}
+	cert := (*c.Resources["HugoSslCertificate"]).(*certificatemanager.Certificate)
+	if !reflect.DeepEqual(cert.SubjectAlternativeNames, []string{"example.com"}) || len(cert.DomainValidationOptions) != 2 {
+		t.Errorf("Expected one alternative name and a validation option per name, got %v %v", cert.SubjectAlternativeNames, cert.DomainValidationOptions)
+	}
+
+	if err := c.SetNames([]string{"www.example.com", "blog.example.com"}, true); err != nil {
+		t.Fatal(err)
+	}
+	if name := *c.GetStackName(); name != "test-certificate" {
+		t.Errorf("Expected the wildcard certificate shared by the prefix, got %s", name)
+	}
+	cert = (*c.Resources["HugoSslCertificate"]).(*certificatemanager.Certificate)
+	if !reflect.DeepEqual(cert.SubjectAlternativeNames, []string{"*.example.com"}) || cert.DomainValidationOptions[1].DomainName != "*.example.com" {
+		t.Errorf("Expected the wildcard as the alternative name, got %v", cert.SubjectAlternativeNames)
+	}
+
+	for _, invalid := range []struct {
+		names    []string
+		wildcard bool
+	}{
+		{[]string{"www.example.org"}, false},
+		{[]string{"*.example.com"}, false},
+		{[]string{"a.b.example.com"}, true},
+	} {
+		if err := c.SetNames(invalid.names, invalid.wildcard); err == nil {
+			t.Errorf("Expected an error for %v", invalid.names)
+		}
+	}
+}
+
+func TestCdnAliases(t *testing.T) {
+	cdn, err := NewCdn(&CdnInput{Prefix: "test", Path: "/", Region: "us-east-1", Domain: "example.com", Record: "www"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cdn.SetAliases([]string{"example.com", "www.example.com", "Blog.Example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if hosts := cdn.Hostnames(); !reflect.DeepEqual(hosts, []string{"www.example.com", "example.com", "blog.example.com"}) {
+		t.Errorf("Unexpected hostnames %v", hosts)
+	}
+	if aliases := cdn.distribution.DistributionConfig.Aliases; len(aliases) != 3 {
+		t.Errorf("Expected the record and two aliases, got %v", aliases)
+	}
+	for _, name := range []string{"aliasexamplecom", "aliasblogexamplecom"} {
+		if _, ok := cdn.Resources[name]; !ok {
+			t.Errorf("Expected the record %s", name)
+		}
+	}
+
+	if err := cdn.SetAliases(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cdn.Resources["aliasexamplecom"]; ok || len(cdn.distribution.DistributionConfig.Aliases) != 1 {
+		t.Error("Expected the aliases to be removed")
+	}
+	if err := cdn.SetAliases([]string{"www.example.org"}); err == nil {
+		t.Error("Expected an error for an alias outside the zone")
+	}
 }
 
 func TestNewCdnAndExports(t *testing.T) {
-	cdn := NewCdn(&CdnInput{Prefix: "test", Path: "/", Region: "us-east-1", Domain: "example.com", Record: "www"})
+	cdn, err := NewCdn(&CdnInput{Prefix: "test", Path: "/", Region: "us-east-1", Domain: "example.com", Record: "www"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if cdn == nil {
 		t.Fatal("NewCdn returned nil")
 	}
@@ -65,14 +159,20 @@ func TestBucketExportNameVariants(t *testing.T) {
 }
 
 func TestCertificateExportNameVariants(t *testing.T) {
-	c := NewCertificate(&CertificateInput{Prefix: "x", Region: "us-east-1", Domain: "d.com", ZoneId: "z"})
+	c, err := NewCertificate(&CertificateInput{Prefix: "x", Region: "us-east-1", Domain: "d.com", ZoneId: "z"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if c.GetExportName("") == "" {
 		t.Error("GetExportName with empty string should not return empty string")
 	}
 }
 
 func TestCdnExportNameVariants(t *testing.T) {
-	cdn := NewCdn(&CdnInput{Prefix: "x", Path: "/", Region: "us-east-1", Domain: "d.com", Record: "r"})
+	cdn, err := NewCdn(&CdnInput{Prefix: "x", Path: "/", Region: "us-east-1", Domain: "d.com", Record: "r"})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if cdn.GetExportName("") == "" {
 		t.Error("GetExportName with empty string should not return empty string")
 	}
@@ -84,3 +184,280 @@ func TestUserExportNameVariants(t *testing.T) {
 		t.Error("GetExportName with empty string should not return empty string")
 	}
 }
+
+func TestIamUserCapabilities(t *testing.T) {
+	u := NewIamUser(&UserInput{Prefix: "test", Path: "/", Region: "us-east-1", Domain: "example.com", Record: "www", BucketName: "bucket", CloudfrontArn: "arn"})
+	caps := u.GetCapabilities()
+	if len(caps) != 1 || caps[0] != "CAPABILITY_NAMED_IAM" {
+		t.Errorf("Expected the user stack to require CAPABILITY_NAMED_IAM, got %v", caps)
+	}
+}
+
+func TestBucketOriginAccess(t *testing.T) {
+	b := NewBucket(&BucketInput{Prefix: "test", Region: "us-east-1", Domain: "example.com"})
+	body, err := b.Build().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"AWS::CloudFront::OriginAccessControl"`, `"Service": "cloudfront.amazonaws.com"`, `"AWS:SourceArn"`} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected %s in the bucket template", expected)
+		}
+	}
+	if _, ok := b.Resources["oai"]; ok {
+		t.Error("Expected no origin access identity")
+	}
+
+	b.KeepOAI(true)
+	if _, ok := b.Resources["oai"]; !ok || len(b.policy.Statement) != 2 || b.Outputs["OAI"].Export == nil {
+		t.Error("Expected the origin access identity, its statement and its export to be kept")
+	}
+	b.KeepOAI(false)
+	if _, ok := b.Resources["oai"]; ok || len(b.policy.Statement) != 1 || len(b.Outputs) != 4 {
+		t.Error("Expected the origin access identity, its statement and its export to be removed")
+	}
+}
+
+func TestBucketDistributions(t *testing.T) {
+	b := NewBucket(&BucketInput{Prefix: "test", Region: "us-east-1", Domain: "example.com"})
+	arns := []string{"arn:aws:cloudfront::123456789012:distribution/E1", "arn:aws:cloudfront::123456789012:distribution/E2"}
+	if err := b.SetDistributions(arns); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(b.Distributions(), arns) {
+		t.Errorf("Expected the distributions %v, got %v", arns, b.Distributions())
+	}
+	for _, param := range b.GetParameters() {
+		if *param.ParameterKey == "Distributions" && *param.ParameterValue != strings.Join(arns, ",") {
+			t.Errorf("Expected the Distributions parameter to list %v, got %s", arns, *param.ParameterValue)
+		}
+	}
+	body, err := b.Build().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"StringEquals"`) || strings.Contains(string(body), "distribution/*") {
+		t.Error("Expected only the distributions of the parameter to read the bucket")
+	}
+
+	b.AllowAccountDistributions(true)
+	body, err = b.Build().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.policy.Statement) != 1 || !strings.Contains(string(body), "distribution/*") {
+		t.Error("Expected the distributions of the account to read the bucket")
+	}
+}
+
+func TestCdnOriginAccessControl(t *testing.T) {
+	cdn, err := NewCdn(&CdnInput{Prefix: "test", Path: "/", Region: "us-east-1", Domain: "example.com", Record: "www", BucketDomain: "domain", BucketOAC: "HawsBucketOacTest", ZoneId: "Z123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := cdn.Build().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), `"OriginAccessControlId": {`) || !strings.Contains(string(body), `"Fn::ImportValue": "HawsBucketOacTest"`) {
+		t.Errorf("Expected the origin to be signed with the imported origin access control, got %s", body)
+	}
+	if strings.Contains(string(body), "origin-access-identity") {
+		t.Error("Expected no origin access identity")
+	}
+}
+
+func TestCdnPrettyUrls(t *testing.T) {
+	cdn, err := NewCdn(&CdnInput{Prefix: "test", Path: "/", Region: "us-east-1", Domain: "example.com", Record: "www", BucketDomain: "domain", BucketOAC: "oac", ZoneId: "Z123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := cdn.Build().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"Type": "AWS::CloudFront::Function"`, `"Name": "test-www-example-com-pretty-urls"`, `"EventType": "viewer-request"`, `"prettyurls",`} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected %s in the cdn template", expected)
+		}
+	}
+}
+
+func TestCdnErrorPages(t *testing.T) {
+	cdn, err := NewCdn(&CdnInput{Prefix: "test", Path: "/my-site", Region: "us-east-1", Domain: "example.com", Record: "www", BucketDomain: "domain", BucketOAC: "oac", ZoneId: "Z123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cdn.SetErrorPages(ErrorPages{Page: "/my-site/404.html", CachingTTL: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	responses := cdn.distribution.DistributionConfig.CustomErrorResponses
+	if len(responses) != 2 || responses[0].ErrorCode != 403 || responses[1].ErrorCode != 404 {
+		t.Fatalf("Expected the 403 and 404 errors to be mapped, got %+v", responses)
+	}
+	if r := responses[1]; r.ResponsePagePath != "/404.html" || r.ResponseCode != 404 || r.ErrorCachingMinTTL != 60 {
+		t.Errorf("Expected /404.html with status 404 cached 60s (the origin path adds the bucket path), got %+v", r)
+	}
+
+	if err := cdn.SetErrorPages(ErrorPages{SPA: true}); err != nil {
+		t.Fatal(err)
+	}
+	if r := cdn.distribution.DistributionConfig.CustomErrorResponses[0]; r.ResponsePagePath != "/index.html" || r.ResponseCode != 200 {
+		t.Errorf("Expected /index.html with status 200 in SPA mode, got %+v", r)
+	}
+
+	if err := cdn.SetErrorPages(ErrorPages{}); err != nil || cdn.distribution.DistributionConfig.CustomErrorResponses != nil {
+		t.Errorf("Expected no error pages, got %v", err)
+	}
+
+	for _, invalid := range []ErrorPages{
+		{Page: "/404.html", ResponseCode: 302},
+		{Page: "/404.html", SPA: true},
+		{ResponseCode: 404},
+		{Page: "/404.html", CachingTTL: -time.Second},
+	} {
+		if err := cdn.SetErrorPages(invalid); err == nil {
+			t.Errorf("Expected an error for %+v", invalid)
+		}
+	}
+}
+
+func TestCdnCache(t *testing.T) {
+	cdn, err := NewCdn(&CdnInput{Prefix: "test", Path: "/", Region: "us-east-1", Domain: "example.com", Record: "www", BucketDomain: "domain", BucketOAC: "oac", ZoneId: "Z123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := cdn.distribution.DistributionConfig
+	if _, ok := cdn.Resources["cache0x3600x86400"]; !ok || !config.DefaultCacheBehavior.Compress || config.DefaultCacheBehavior.ForwardedValues != nil {
+		t.Errorf("Expected a compressing custom cache policy of 1h and 24h by default, got %v", cdn.cacheResources)
+	}
+
+	year := 365 * 24 * time.Hour
+	err = cdn.SetCache(Cache{
+		Policy:               CachePolicy{Managed: "CachingOptimized"},
+		OriginRequestHeaders: []string{"Origin"},
+		Behaviors: []CacheBehavior{
+			{PathPattern: "/css/*", Policy: CachePolicy{DefaultTTL: year, MaxTTL: year}},
+			{PathPattern: "/js/*", Policy: CachePolicy{DefaultTTL: year, MaxTTL: year}},
+			{PathPattern: "*.html", Policy: CachePolicy{DefaultTTL: time.Minute, MaxTTL: time.Hour}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := config.DefaultCacheBehavior.CachePolicyId; id != managedCachePolicies["CachingOptimized"] {
+		t.Errorf("Expected the managed policy, got %s", id)
+	}
+	if _, ok := cdn.Resources["cache0x3600x86400"]; ok {
+		t.Error("Expected the previous cache policy to be removed")
+	}
+	if len(config.CacheBehaviors) != 3 || len(cdn.cacheResources) != 3 {
+		t.Fatalf("Expected 3 behaviors sharing 2 cache policies and an origin request policy, got %d and %v", len(config.CacheBehaviors), cdn.cacheResources)
+	}
+	css, js := config.CacheBehaviors[0], config.CacheBehaviors[1]
+	if css.PathPattern != "/css/*" || css.CachePolicyId != js.CachePolicyId || !css.Compress || css.OriginRequestPolicyId == "" || len(css.FunctionAssociations) != 1 {
+		t.Errorf("Unexpected behavior %+v", css)
+	}
+
+	for _, invalid := range []Cache{
+		{Policy: CachePolicy{Managed: "Unknown"}},
+		{Policy: CachePolicy{Managed: "CachingDisabled", MaxTTL: time.Hour}},
+		{Policy: CachePolicy{DefaultTTL: time.Hour, MaxTTL: time.Minute}},
+		{Policy: CachePolicy{Managed: "CachingOptimized"}, OriginRequestPolicy: "AllViewer"},
+		{Policy: CachePolicy{Managed: "CachingOptimized"}, Behaviors: []CacheBehavior{{Policy: CachePolicy{Managed: "CachingOptimized"}}}},
+		{Policy: CachePolicy{Managed: "CachingOptimized"}, Behaviors: []CacheBehavior{
+			{PathPattern: "/a/*", Policy: CachePolicy{Managed: "CachingOptimized"}},
+			{PathPattern: "/a/*", Policy: CachePolicy{Managed: "CachingDisabled"}},
+		}},
+	} {
+		if err := cdn.SetCache(invalid); err == nil {
+			t.Errorf("Expected an error for %+v", invalid)
+		}
+	}
+}
+
+func TestCdnResourceNames(t *testing.T) {
+	cdn, err := NewCdn(&CdnInput{Prefix: "prod", Path: "/", Region: "us-east-1", Domain: "example-company.com", Record: "documentation.engineering-blog", BucketDomain: "domain", BucketOAC: "oac", ZoneId: "Z123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cdn.SetCache(Cache{
+		Policy:               CachePolicy{DefaultTTL: time.Hour, MaxTTL: 24 * time.Hour},
+		OriginRequestHeaders: []string{"Origin"},
+		Behaviors: []CacheBehavior{
+			{PathPattern: "/assets/*", Policy: CachePolicy{DefaultTTL: time.Hour, MaxTTL: 365 * 24 * time.Hour}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{cdn.resourceName("pretty-urls"), cdn.resourceName("origin-request")}
+	for _, name := range cdn.cacheResources {
+		names = append(names, cdn.resourceName(name))
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if len(name) > 64 || seen[name] {
+			t.Errorf("Expected unique names of at most 64 characters, got %v", names)
+		}
+		seen[name] = true
+	}
+}
+
+func TestCdnResponseHeaders(t *testing.T) {
+	cdn, err := NewCdn(&CdnInput{Prefix: "test", Path: "/", Region: "us-east-1", Domain: "example.com", Record: "www", BucketDomain: "domain", BucketOAC: "oac", ZoneId: "Z123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cdn.SetCache(Cache{Policy: CachePolicy{Managed: "CachingOptimized"}, Behaviors: []CacheBehavior{{PathPattern: "/fonts/*", Policy: CachePolicy{Managed: "CachingOptimized"}}}}); err != nil {
+		t.Fatal(err)
+	}
+	body, err := cdn.Build().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"AWS::CloudFront::ResponseHeadersPolicy"`, `"StrictTransportSecurity"`, `"FrameOption": "DENY"`, `"ReferrerPolicy": "strict-origin-when-cross-origin"`} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected %s in the cdn template", expected)
+		}
+	}
+	if count := strings.Count(string(body), `"ResponseHeadersPolicyId"`); count != 2 {
+		t.Errorf("Expected the policy on the default and the fonts behaviors, got %d", count)
+	}
+
+	headers := DefaultResponseHeaders()
+	headers.Custom = map[string]string{"permissions-policy": "camera=()"}
+	headers.Cors = &Cors{AllowOrigins: []string{"https://blog.example.com"}}
+	if err := cdn.SetResponseHeaders(headers); err != nil {
+		t.Fatal(err)
+	}
+	policy := (*cdn.Resources["headers"]).(*cloudfrontext.ResponseHeadersPolicy).ResponseHeadersPolicyConfig
+	if h := policy.CustomHeadersConfig.Items[0]; h.Header != "Permissions-Policy" || h.Value != "camera=()" {
+		t.Errorf("Expected the canonical custom header, got %+v", h)
+	}
+	if cors := policy.CorsConfig; cors.AccessControlAllowMethods.Items[0] != "GET" || cors.AccessControlAllowHeaders.Items[0] != "*" || !cors.OriginOverride {
+		t.Errorf("Expected the default CORS methods and headers, got %+v", cors)
+	}
+
+	if err := cdn.SetResponseHeaders(ResponseHeaders{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cdn.Resources["headers"]; ok || cdn.distribution.ResponseHeadersPolicyId != "" {
+		t.Error("Expected no response headers policy without headers")
+	}
+
+	for _, invalid := range []ResponseHeaders{
+		{FrameOptions: "ALLOW"},
+		{ReferrerPolicy: "everything"},
+		{HSTSPreload: true},
+		{Custom: map[string]string{"Strict-Transport-Security": "max-age=1"}},
+		{Cors: &Cors{}},
+		{Cors: &Cors{AllowOrigins: []string{"*"}, AllowMethods: []string{"CONNECT"}}},
+	} {
+		if err := cdn.SetResponseHeaders(invalid); err == nil {
+			t.Errorf("Expected an error for %+v", invalid)
+		}
+	}
+}
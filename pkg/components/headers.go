@@ -0,0 +1,208 @@
+package components
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awslabs/goformation/v4/cloudformation"
+
+	"github.com/dragosboca/haws/pkg/components/resources/cloudfrontext"
+)
+
+// referrerPolicies are the values of the Referrer-Policy header supported by CloudFront
+var referrerPolicies = map[string]bool{
+	"no-referrer": true, "no-referrer-when-downgrade": true, "origin": true, "origin-when-cross-origin": true,
+	"same-origin": true, "strict-origin": true, "strict-origin-when-cross-origin": true, "unsafe-url": true,
+}
+
+// corsMethods are the methods of the CORS headers supported by CloudFront
+var corsMethods = map[string]bool{
+	"GET": true, "HEAD": true, "OPTIONS": true, "PUT": true, "POST": true, "PATCH": true, "DELETE": true, "ALL": true,
+}
+
+// securityHeaders are the headers set by the security settings, which cannot be custom headers
+var securityHeaders = map[string]bool{
+	"content-security-policy": true, "x-content-type-options": true, "x-frame-options": true,
+	"referrer-policy": true, "strict-transport-security": true, "x-xss-protection": true,
+}
+
+// ResponseHeaders are the headers CloudFront adds to the responses of the site, replacing those of the origin
+type ResponseHeaders struct {
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header (0 to omit it)
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// ContentSecurityPolicy is the Content-Security-Policy header (empty to omit it)
+	ContentSecurityPolicy string
+	// NoSniff adds the header X-Content-Type-Options: nosniff
+	NoSniff bool
+	// FrameOptions is the X-Frame-Options header: DENY, SAMEORIGIN or empty to omit it
+	FrameOptions string
+	// ReferrerPolicy is the Referrer-Policy header (empty to omit it)
+	ReferrerPolicy string
+	// Custom are the other headers, indexed by name
+	Custom map[string]string
+	// Cors adds the CORS headers, e.g. for the web fonts used by other sites (nil for none)
+	Cors *Cors
+}
+
+// Cors are the CORS headers added to the responses to the allowed origins
+type Cors struct {
+	// AllowOrigins are the allowed origins, e.g. https://example.com, or * for all
+	AllowOrigins []string
+	// AllowMethods are the allowed methods (default GET and HEAD)
+	AllowMethods []string
+	// AllowHeaders are the allowed request headers (default *)
+	AllowHeaders []string
+	// ExposeHeaders are the response headers exposed to the scripts
+	ExposeHeaders []string
+	// MaxAge is how long the browsers cache the preflight responses (0 to omit it)
+	MaxAge time.Duration
+}
+
+// DefaultResponseHeaders returns the security headers of the sites without headers configuration
+// The Content-Security-Policy only restricts what does not break the pages of a static site: plugins and base URLs
+// return: ResponseHeaders - the headers
+func DefaultResponseHeaders() ResponseHeaders {
+	return ResponseHeaders{
+		HSTSMaxAge:            2 * 365 * 24 * time.Hour,
+		ContentSecurityPolicy: "upgrade-insecure-requests; object-src 'none'; base-uri 'self'",
+		NoSniff:               true,
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+	}
+}
+
+// validate checks the headers
+func (h ResponseHeaders) validate() error {
+	if h.HSTSMaxAge < 0 {
+		return fmt.Errorf("the max-age of Strict-Transport-Security cannot be negative")
+	}
+	if h.HSTSMaxAge == 0 && (h.HSTSIncludeSubdomains || h.HSTSPreload) {
+		return fmt.Errorf("includeSubDomains and preload need the max-age of Strict-Transport-Security")
+	}
+	if h.FrameOptions != "" && h.FrameOptions != "DENY" && h.FrameOptions != "SAMEORIGIN" {
+		return fmt.Errorf("X-Frame-Options must be DENY or SAMEORIGIN, got %s", h.FrameOptions)
+	}
+	if h.ReferrerPolicy != "" && !referrerPolicies[h.ReferrerPolicy] {
+		return fmt.Errorf("unsupported Referrer-Policy %s", h.ReferrerPolicy)
+	}
+	for name := range h.Custom {
+		if securityHeaders[strings.ToLower(name)] {
+			return fmt.Errorf("the security header %s cannot be a custom header", name)
+		}
+		if strings.HasPrefix(strings.ToLower(name), "access-control-") {
+			return fmt.Errorf("the CORS header %s cannot be a custom header", name)
+		}
+	}
+
+	if h.Cors == nil {
+		return nil
+	}
+	if len(h.Cors.AllowOrigins) == 0 {
+		return fmt.Errorf("the CORS headers need the allowed origins")
+	}
+	for _, method := range h.Cors.AllowMethods {
+		if !corsMethods[method] {
+			return fmt.Errorf("unsupported CORS method %s", method)
+		}
+	}
+	if h.Cors.MaxAge < 0 {
+		return fmt.Errorf("the max-age of the CORS headers cannot be negative")
+	}
+	return nil
+}
+
+// empty returns true if no header is added
+func (h ResponseHeaders) empty() bool {
+	return h.HSTSMaxAge == 0 && h.ContentSecurityPolicy == "" && !h.NoSniff && h.FrameOptions == "" &&
+		h.ReferrerPolicy == "" && len(h.Custom) == 0 && h.Cors == nil
+}
+
+// SetResponseHeaders sets the response headers policy of the default and of all the cache behaviors of the distribution
+// param: headers - the headers (no header removes the policy)
+// return: error - the error if the headers are not valid
+func (c *Cdn) SetResponseHeaders(headers ResponseHeaders) error {
+	if err := headers.validate(); err != nil {
+		return err
+	}
+
+	delete(c.Resources, "headers")
+	c.distribution.ResponseHeadersPolicyId = ""
+	if headers.empty() {
+		return nil
+	}
+
+	security := &cloudfrontext.SecurityHeadersConfig{}
+	if headers.HSTSMaxAge > 0 {
+		security.StrictTransportSecurity = &cloudfrontext.StrictTransportSecurity{
+			AccessControlMaxAgeSec: int(headers.HSTSMaxAge.Seconds()),
+			IncludeSubdomains:      headers.HSTSIncludeSubdomains,
+			Preload:                headers.HSTSPreload,
+			Override:               true,
+		}
+	}
+	if headers.ContentSecurityPolicy != "" {
+		security.ContentSecurityPolicy = &cloudfrontext.ContentSecurityPolicy{ContentSecurityPolicy: headers.ContentSecurityPolicy, Override: true}
+	}
+	if headers.NoSniff {
+		security.ContentTypeOptions = &cloudfrontext.ContentTypeOptions{Override: true}
+	}
+	if headers.FrameOptions != "" {
+		security.FrameOptions = &cloudfrontext.FrameOptions{FrameOption: headers.FrameOptions, Override: true}
+	}
+	if headers.ReferrerPolicy != "" {
+		security.ReferrerPolicy = &cloudfrontext.ReferrerPolicy{ReferrerPolicy: headers.ReferrerPolicy, Override: true}
+	}
+
+	config := &cloudfrontext.ResponseHeadersPolicyConfig{
+		Name:    c.resourceName("headers"),
+		Comment: "Response headers policy of a haws site",
+	}
+	if *security != (cloudfrontext.SecurityHeadersConfig{}) {
+		config.SecurityHeadersConfig = security
+	}
+
+	if len(headers.Custom) > 0 {
+		names := make([]string, 0, len(headers.Custom))
+		for name := range headers.Custom {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		config.CustomHeadersConfig = &cloudfrontext.CustomHeadersConfig{}
+		for _, name := range names {
+			config.CustomHeadersConfig.Items = append(config.CustomHeadersConfig.Items, cloudfrontext.CustomHeader{
+				Header:   http.CanonicalHeaderKey(name),
+				Value:    headers.Custom[name],
+				Override: true,
+			})
+		}
+	}
+
+	if cors := headers.Cors; cors != nil {
+		methods, allowed := cors.AllowMethods, cors.AllowHeaders
+		if len(methods) == 0 {
+			methods = []string{"GET", "HEAD"}
+		}
+		if len(allowed) == 0 {
+			allowed = []string{"*"}
+		}
+		config.CorsConfig = &cloudfrontext.CorsConfig{
+			AccessControlAllowHeaders: cloudfrontext.Items{Items: allowed},
+			AccessControlAllowMethods: cloudfrontext.Items{Items: methods},
+			AccessControlAllowOrigins: cloudfrontext.Items{Items: cors.AllowOrigins},
+			AccessControlMaxAgeSec:    int(cors.MaxAge.Seconds()),
+			OriginOverride:            true,
+		}
+		if len(cors.ExposeHeaders) > 0 {
+			config.CorsConfig.AccessControlExposeHeaders = &cloudfrontext.Items{Items: cors.ExposeHeaders}
+		}
+	}
+
+	c.AddResource("headers", &cloudfrontext.ResponseHeadersPolicy{ResponseHeadersPolicyConfig: config})
+	c.distribution.ResponseHeadersPolicyId = cloudformation.Ref("headers")
+	return nil
+}
@@ -8,12 +8,16 @@ type Document struct {
 
 type Principal map[string]string
 
+// Condition maps the condition operators (e.g. StringLike) to the condition keys and their values
+type Condition map[string]map[string]string
+
 type Statement struct {
 	Sid       string
 	Effect    string
 	Action    []string
 	Principal Principal
 	Resource  []string
+	Condition Condition `json:",omitempty"`
 }
 
 func New(id string) *Document {
@@ -1,6 +1,10 @@
 package bucketpolicy
 
-import "testing"
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
 
 func TestNewAndAddStatement(t *testing.T) {
 	d := New("id1")
@@ -31,3 +35,23 @@ func TestNewAndAddStatement(t *testing.T) {
 		t.Errorf("Expected Sid 'sid1', got '%s'", d.Statement[0].Sid)
 	}
 }
+
+func TestCondition(t *testing.T) {
+	d := New("id1")
+	d.AddStatement("plain", Statement{Effect: "Allow"})
+	d.AddStatement("conditional", Statement{
+		Effect:    "Allow",
+		Condition: Condition{"StringLike": {"AWS:SourceArn": "arn:aws:cloudfront::123456789012:distribution/*"}},
+	})
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), `"Condition"`) != 1 {
+		t.Errorf("Expected only the conditional statement to have a condition, got %s", data)
+	}
+	if !strings.Contains(string(data), `"Condition":{"StringLike":{"AWS:SourceArn":"arn:aws:cloudfront::123456789012:distribution/*"}}`) {
+		t.Errorf("Expected the condition on the source ARN, got %s", data)
+	}
+}
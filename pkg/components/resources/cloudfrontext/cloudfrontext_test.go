@@ -0,0 +1,71 @@
+package cloudfrontext
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/awslabs/goformation/v4/cloudformation/cloudfront"
+)
+
+func TestOriginAccessControl(t *testing.T) {
+	data, err := json.Marshal(NewOriginAccessControl("site", "haws site"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"Type":"AWS::CloudFront::OriginAccessControl"`, `"OriginAccessControlOriginType":"s3"`, `"SigningBehavior":"always"`, `"SigningProtocol":"sigv4"`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected %s in %s", expected, data)
+		}
+	}
+}
+
+func TestDistribution(t *testing.T) {
+	d := &Distribution{
+		Distribution: cloudfront.Distribution{
+			DistributionConfig: &cloudfront.Distribution_DistributionConfig{
+				Origins: []cloudfront.Distribution_Origin{
+					{Id: "s3", DomainName: "bucket.s3.amazonaws.com", S3OriginConfig: &cloudfront.Distribution_S3OriginConfig{}},
+					{Id: "api", DomainName: "api.example.com"},
+				},
+				DefaultCacheBehavior: &cloudfront.Distribution_DefaultCacheBehavior{TargetOriginId: "s3"},
+				CacheBehaviors: []cloudfront.Distribution_CacheBehavior{
+					{PathPattern: "/css/*", TargetOriginId: "s3"},
+				},
+			},
+		},
+		OriginAccessControls:    map[string]string{"s3": "E123"},
+		ResponseHeadersPolicyId: "headers",
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resource struct {
+		Type       string
+		Properties struct {
+			DistributionConfig struct {
+				Origins              []map[string]interface{}
+				DefaultCacheBehavior map[string]interface{}
+				CacheBehaviors       []map[string]interface{}
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &resource); err != nil {
+		t.Fatal(err)
+	}
+	if resource.Type != "AWS::CloudFront::Distribution" {
+		t.Errorf("Expected a distribution, got %s", resource.Type)
+	}
+	config := resource.Properties.DistributionConfig
+	if config.Origins[0]["OriginAccessControlId"] != "E123" {
+		t.Errorf("Expected the origin access control of the s3 origin, got %v", config.Origins[0])
+	}
+	if _, ok := config.Origins[1]["OriginAccessControlId"]; ok {
+		t.Errorf("Expected no origin access control on the api origin, got %v", config.Origins[1])
+	}
+	if config.DefaultCacheBehavior["ResponseHeadersPolicyId"] != "headers" || config.CacheBehaviors[0]["ResponseHeadersPolicyId"] != "headers" {
+		t.Errorf("Expected the response headers policy on all the behaviors, got %v and %v", config.DefaultCacheBehavior, config.CacheBehaviors)
+	}
+}
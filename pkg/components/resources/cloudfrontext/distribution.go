@@ -0,0 +1,56 @@
+package cloudfrontext
+
+import (
+	"encoding/json"
+
+	"github.com/awslabs/goformation/v4/cloudformation/cloudfront"
+)
+
+// Distribution is a CloudFront distribution with the properties missing in goformation v4,
+// added when the distribution is marshalled
+type Distribution struct {
+	cloudfront.Distribution
+
+	// OriginAccessControls are the ids of the origin access controls signing the requests to the origins, indexed by origin id
+	OriginAccessControls map[string]string `json:"-"`
+	// ResponseHeadersPolicyId is the id of the response headers policy of the default and of all the cache behaviors
+	ResponseHeadersPolicyId string `json:"-"`
+}
+
+// MarshalJSON marshals the distribution and sets the OriginAccessControlId of its origins
+// and the ResponseHeadersPolicyId of its cache behaviors
+func (d Distribution) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(d.Distribution)
+	if err != nil || (len(d.OriginAccessControls) == 0 && d.ResponseHeadersPolicyId == "") {
+		return data, err
+	}
+
+	var resource map[string]interface{}
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, err
+	}
+	properties, _ := resource["Properties"].(map[string]interface{})
+	config, _ := properties["DistributionConfig"].(map[string]interface{})
+
+	origins, _ := config["Origins"].([]interface{})
+	for _, o := range origins {
+		origin, _ := o.(map[string]interface{})
+		id, _ := origin["Id"].(string)
+		if control, ok := d.OriginAccessControls[id]; ok {
+			origin["OriginAccessControlId"] = control
+		}
+	}
+
+	if d.ResponseHeadersPolicyId != "" {
+		behaviors, _ := config["CacheBehaviors"].([]interface{})
+		if behavior, ok := config["DefaultCacheBehavior"]; ok {
+			behaviors = append(behaviors, behavior)
+		}
+		for _, b := range behaviors {
+			if behavior, ok := b.(map[string]interface{}); ok {
+				behavior["ResponseHeadersPolicyId"] = d.ResponseHeadersPolicyId
+			}
+		}
+	}
+	return json.Marshal(resource)
+}
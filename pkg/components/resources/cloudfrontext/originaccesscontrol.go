@@ -0,0 +1,66 @@
+// Package cloudfrontext adds the CloudFront resources and properties missing in goformation v4
+package cloudfrontext
+
+import (
+	"encoding/json"
+
+	"github.com/awslabs/goformation/v4/cloudformation/policies"
+)
+
+// OriginAccessControl is an AWS::CloudFront::OriginAccessControl resource
+type OriginAccessControl struct {
+	OriginAccessControlConfig *OriginAccessControlConfig
+
+	// AWSCloudFormationDeletionPolicy represents a CloudFormation DeletionPolicy
+	AWSCloudFormationDeletionPolicy policies.DeletionPolicy `json:"-"`
+}
+
+// OriginAccessControlConfig is the configuration of an origin access control
+type OriginAccessControlConfig struct {
+	Name                          string
+	Description                   string `json:",omitempty"`
+	OriginAccessControlOriginType string
+	SigningBehavior               string
+	SigningProtocol               string
+}
+
+// NewOriginAccessControl creates an origin access control signing all the requests to an S3 origin
+// param: name - the name of the origin access control, unique in the account
+// param: description - the description
+// return: *OriginAccessControl - the resource
+func NewOriginAccessControl(name string, description string) *OriginAccessControl {
+	return &OriginAccessControl{
+		OriginAccessControlConfig: &OriginAccessControlConfig{
+			Name:                          name,
+			Description:                   description,
+			OriginAccessControlOriginType: "s3",
+			SigningBehavior:               "always",
+			SigningProtocol:               "sigv4",
+		},
+	}
+}
+
+// AWSCloudFormationType returns the AWS CloudFormation resource type
+func (r *OriginAccessControl) AWSCloudFormationType() string {
+	return "AWS::CloudFront::OriginAccessControl"
+}
+
+// MarshalJSON embeds the properties in the Properties field of the resource and adds its Type
+func (r OriginAccessControl) MarshalJSON() ([]byte, error) {
+	return marshalResource(r.AWSCloudFormationType(), map[string]interface{}{
+		"OriginAccessControlConfig": r.OriginAccessControlConfig,
+	}, r.AWSCloudFormationDeletionPolicy)
+}
+
+// marshalResource marshals a resource of a template
+func marshalResource(resourceType string, properties interface{}, deletionPolicy policies.DeletionPolicy) ([]byte, error) {
+	return json.Marshal(&struct {
+		Type           string
+		Properties     interface{}
+		DeletionPolicy policies.DeletionPolicy `json:"DeletionPolicy,omitempty"`
+	}{
+		Type:           resourceType,
+		Properties:     properties,
+		DeletionPolicy: deletionPolicy,
+	})
+}
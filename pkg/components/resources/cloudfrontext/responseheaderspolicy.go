@@ -0,0 +1,102 @@
+package cloudfrontext
+
+import (
+	"github.com/awslabs/goformation/v4/cloudformation/policies"
+)
+
+// ResponseHeadersPolicy is an AWS::CloudFront::ResponseHeadersPolicy resource
+type ResponseHeadersPolicy struct {
+	ResponseHeadersPolicyConfig *ResponseHeadersPolicyConfig
+
+	// AWSCloudFormationDeletionPolicy represents a CloudFormation DeletionPolicy
+	AWSCloudFormationDeletionPolicy policies.DeletionPolicy `json:"-"`
+}
+
+// ResponseHeadersPolicyConfig is the configuration of a response headers policy
+type ResponseHeadersPolicyConfig struct {
+	Name                  string
+	Comment               string                 `json:",omitempty"`
+	CorsConfig            *CorsConfig            `json:",omitempty"`
+	CustomHeadersConfig   *CustomHeadersConfig   `json:",omitempty"`
+	SecurityHeadersConfig *SecurityHeadersConfig `json:",omitempty"`
+}
+
+// Items is a list of values of a response headers policy
+type Items struct {
+	Items []string
+}
+
+// CorsConfig are the CORS headers added to the responses
+type CorsConfig struct {
+	AccessControlAllowCredentials bool
+	AccessControlAllowHeaders     Items
+	AccessControlAllowMethods     Items
+	AccessControlAllowOrigins     Items
+	AccessControlExposeHeaders    *Items `json:",omitempty"`
+	AccessControlMaxAgeSec        int    `json:",omitempty"`
+	OriginOverride                bool
+}
+
+// CustomHeadersConfig are the custom headers added to the responses
+type CustomHeadersConfig struct {
+	Items []CustomHeader
+}
+
+// CustomHeader is a custom header added to the responses
+type CustomHeader struct {
+	Header   string
+	Value    string
+	Override bool
+}
+
+// SecurityHeadersConfig are the security headers added to the responses
+type SecurityHeadersConfig struct {
+	ContentSecurityPolicy   *ContentSecurityPolicy   `json:",omitempty"`
+	ContentTypeOptions      *ContentTypeOptions      `json:",omitempty"`
+	FrameOptions            *FrameOptions            `json:",omitempty"`
+	ReferrerPolicy          *ReferrerPolicy          `json:",omitempty"`
+	StrictTransportSecurity *StrictTransportSecurity `json:",omitempty"`
+}
+
+// ContentSecurityPolicy is the Content-Security-Policy header
+type ContentSecurityPolicy struct {
+	ContentSecurityPolicy string
+	Override              bool
+}
+
+// ContentTypeOptions is the X-Content-Type-Options header, always nosniff
+type ContentTypeOptions struct {
+	Override bool
+}
+
+// FrameOptions is the X-Frame-Options header
+type FrameOptions struct {
+	FrameOption string
+	Override    bool
+}
+
+// ReferrerPolicy is the Referrer-Policy header
+type ReferrerPolicy struct {
+	ReferrerPolicy string
+	Override       bool
+}
+
+// StrictTransportSecurity is the Strict-Transport-Security header
+type StrictTransportSecurity struct {
+	AccessControlMaxAgeSec int
+	IncludeSubdomains      bool `json:",omitempty"`
+	Preload                bool `json:",omitempty"`
+	Override               bool
+}
+
+// AWSCloudFormationType returns the AWS CloudFormation resource type
+func (r *ResponseHeadersPolicy) AWSCloudFormationType() string {
+	return "AWS::CloudFront::ResponseHeadersPolicy"
+}
+
+// MarshalJSON embeds the properties in the Properties field of the resource and adds its Type
+func (r ResponseHeadersPolicy) MarshalJSON() ([]byte, error) {
+	return marshalResource(r.AWSCloudFormationType(), map[string]interface{}{
+		"ResponseHeadersPolicyConfig": r.ResponseHeadersPolicyConfig,
+	}, r.AWSCloudFormationDeletionPolicy)
+}
@@ -0,0 +1,93 @@
+package viewerfunction
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Runtime is the runtime of the CloudFront Functions
+const Runtime = "cloudfront-js-1.0"
+
+// maxNameLength is the maximum length of the name of a CloudFront Function
+const maxNameLength = 64
+
+// hashLength is the length of the hash shortening the names too long
+const hashLength = 8
+
+const prettyUrls = `// generated by haws: serve the {{ .IndexDocument }} of the directories of the site
+function handler(event) {
+    var request = event.request;
+    var uri = request.uri;
+
+    // /posts/my-post/ -> /posts/my-post/{{ .IndexDocument }}
+    if (uri.charAt(uri.length - 1) === '/') {
+        request.uri = uri + '{{ .IndexDocument }}';
+        return request;
+    }
+
+    // /posts/my-post -> /posts/my-post/{{ .IndexDocument }}, the files have an extension
+    var name = uri.substring(uri.lastIndexOf('/') + 1);
+    if (name.indexOf('.') === -1) {
+        request.uri = uri + '/{{ .IndexDocument }}';
+    }
+    return request;
+}
+`
+
+// PrettyUrls holds the values of the template of the pretty URLs function
+type PrettyUrls struct {
+	// IndexDocument is the document served for the directories, e.g. index.html
+	IndexDocument string
+}
+
+// Code generates the code of the viewer-request function rewriting the directories and the extensionless paths
+// to their index document
+// return: string - the JavaScript code of the function
+// return: error - the error if the index document is not a plain file name
+func (p PrettyUrls) Code() (string, error) {
+	if p.IndexDocument == "" || strings.ContainsAny(p.IndexDocument, "/'\\\n") {
+		return "", fmt.Errorf("invalid index document %q", p.IndexDocument)
+	}
+
+	t := template.Must(template.New("prettyUrls").Parse(prettyUrls))
+	var code strings.Builder
+	if err := t.Execute(&code, p); err != nil {
+		return "", err
+	}
+	return code.String(), nil
+}
+
+// Name builds a valid function name from its parts, unique for distinct parts
+// A name too long keeps its last part whole and shortens the other ones, followed by a short hash of them
+// param: parts - the parts of the name, joined with dashes
+// return: string - the name, made of letters, digits, dashes and underscores
+func Name(parts ...string) string {
+	name := sanitize(strings.Join(parts, "-"))
+	if len(name) <= maxNameLength {
+		return name
+	}
+
+	suffix := sanitize(parts[len(parts)-1])
+	head := strings.Join(parts[:len(parts)-1], "-")
+	sum := sha256.Sum256([]byte(head))
+	hash := hex.EncodeToString(sum[:])[:hashLength]
+	keep := maxNameLength - len(suffix) - len(hash) - 2
+	if len(parts) < 2 || keep < 1 {
+		return name[:maxNameLength]
+	}
+	return sanitize(head)[:keep] + "-" + hash + "-" + suffix
+}
+
+// sanitize replaces the characters not allowed in a function name with dashes
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '-'
+	}, name)
+}
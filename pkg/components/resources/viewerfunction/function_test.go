@@ -0,0 +1,86 @@
+package viewerfunction
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrettyUrlsCode(t *testing.T) {
+	code, err := PrettyUrls{IndexDocument: "index.html"}.Code()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(code, "function handler(event)") || !strings.Contains(code, "'/index.html'") {
+		t.Errorf("Expected a handler serving index.html, got %s", code)
+	}
+
+	for _, invalid := range []string{"", "docs/index.html", "index'.html"} {
+		if _, err := (PrettyUrls{IndexDocument: invalid}).Code(); err == nil {
+			t.Errorf("Expected an error for index document %q", invalid)
+		}
+	}
+}
+
+// TestPrettyUrlsRewrite runs the function with node, when it is installed
+func TestPrettyUrlsRewrite(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	code, err := PrettyUrls{IndexDocument: "index.html"}.Code()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := code + `
+var cases = {
+    '/': '/index.html',
+    '/posts/my-post/': '/posts/my-post/index.html',
+    '/posts/my-post': '/posts/my-post/index.html',
+    '/css/site.css': '/css/site.css',
+    '/posts/v1.2/': '/posts/v1.2/index.html',
+    '/.well-known/security.txt': '/.well-known/security.txt'
+};
+for (var uri in cases) {
+    var got = handler({request: {uri: uri}}).uri;
+    if (got !== cases[uri]) {
+        console.log(uri + ' -> ' + got + ', expected ' + cases[uri]);
+        process.exitCode = 1;
+    }
+}
+`
+	path := filepath.Join(t.TempDir(), "function.js")
+	if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(node, path).CombinedOutput(); err != nil {
+		t.Errorf("Unexpected rewrites: %v\n%s", err, out)
+	}
+}
+
+func TestName(t *testing.T) {
+	if name := Name("site", "www.example.com", "pretty-urls"); name != "site-www-example-com-pretty-urls" {
+		t.Errorf("Expected site-www-example-com-pretty-urls, got %s", name)
+	}
+	if name := Name(strings.Repeat("a", 100)); len(name) != maxNameLength {
+		t.Errorf("Expected the name to be truncated to %d characters, got %d", maxNameLength, len(name))
+	}
+
+	// the suffixes of a long hostname are kept, the prefix and the hostname are shortened
+	host := "documentation.engineering-blog.example-company.com"
+	assets, html := Name("prod", host, "cache0x3600x86400"), Name("prod", host, "cache0x60x3600")
+	for _, name := range []string{assets, html} {
+		if len(name) > maxNameLength {
+			t.Errorf("Expected at most %d characters, got %s", maxNameLength, name)
+		}
+	}
+	if !strings.HasSuffix(assets, "-cache0x3600x86400") || !strings.HasSuffix(html, "-cache0x60x3600") {
+		t.Errorf("Expected the suffixes to be kept, got %s and %s", assets, html)
+	}
+	if other := Name("test", host, "cache0x60x3600"); other == html {
+		t.Errorf("Expected the prefixes to give distinct names, got %s", other)
+	}
+}
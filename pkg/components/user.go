@@ -3,11 +3,13 @@ package components
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dragosboca/haws/pkg/components/resources/customtags"
 	"github.com/dragosboca/haws/pkg/components/resources/iampolicy"
 	"github.com/dragosboca/haws/pkg/stack"
 
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	"github.com/awslabs/goformation/v4/cloudformation"
 	"github.com/awslabs/goformation/v4/cloudformation/iam"
 )
@@ -41,6 +43,11 @@ func NewIamUser(u *UserInput) *User {
 		TemplateComponent: stack.NewTemplate(u.Region),
 		recordName:        recordName,
 	}
+	user.Timeout = 15 * time.Minute
+	user.AddDependency("bucket")
+	user.AddDependency("cloudfront")
+	// the IAM user has an explicit name
+	user.AddCapability(types.CapabilityCapabilityNamedIam)
 
 	doc := iampolicy.New("PolicyForCloudfrontPrivateContent")
 	doc.AddStatement("haws", iampolicy.Statement{
@@ -101,6 +108,7 @@ func NewIamUser(u *UserInput) *User {
 		Value:       cloudformation.GetAtt("accesskey", "SecretAccessKey"),
 		Description: "SecretAccessKey for user",
 	}, "SECRET_ACCESS_KEY")
+	user.MarkSecret("SecretKey")
 
 	return user
 }
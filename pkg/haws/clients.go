@@ -0,0 +1,286 @@
+package haws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/dragosboca/haws/pkg/stack"
+)
+
+// The services whose endpoint can be overridden
+const (
+	ServiceCloudFormation = "cloudformation"
+	ServiceRoute53        = "route53"
+	ServiceS3             = "s3"
+	ServiceCloudFront     = "cloudfront"
+	ServiceACM            = "acm"
+	ServiceSTS            = "sts"
+)
+
+// DefaultSessionDuration is the duration of the session of the assumed role, as long as the longest stack operation
+// (the validation of the certificate) so the role is not assumed again, asking for another MFA code, while waiting
+const DefaultSessionDuration = time.Hour
+
+// globalRegion is the region of the global services (CloudFront, Route53) and of the certificates of CloudFront
+const globalRegion = "us-east-1"
+
+// ClientOptions selects the credentials and the endpoints of the AWS clients
+type ClientOptions struct {
+	// Profile is the shared config profile (empty for the default credential chain)
+	Profile string
+
+	// AssumeRoleArn is a role assumed with the credentials of the profile (empty to use them directly)
+	AssumeRoleArn string
+	// ExternalId is the external ID required by the trust policy of the role
+	ExternalId string
+	// MfaSerial is the MFA device required by the trust policy of the role
+	MfaSerial string
+	// MfaToken reads the MFA code (nil prompts for it on stdin)
+	MfaToken func() (string, error)
+	// SessionDuration is the duration of the session of the assumed role (0 for DefaultSessionDuration)
+	SessionDuration time.Duration
+
+	// EndpointURL is the endpoint of all the services, e.g. a local AWS emulator (empty for AWS)
+	EndpointURL string
+	// Endpoints overrides the endpoint of single services, indexed by service name (cloudformation, route53, s3, cloudfront, acm, sts)
+	Endpoints map[string]string
+}
+
+// ClientProvider creates the AWS clients of a site once per region and shares them between the stacks
+type ClientProvider struct {
+	options ClientOptions
+
+	mu             sync.Mutex
+	base           *aws.Config
+	cloudFormation map[string]*cloudformation.Client
+	s3             map[string]*s3.Client
+	acm            map[string]*acm.Client
+	staging        map[string]*stack.Staging
+	route53        *route53.Client
+	cloudFront     *cloudfront.Client
+}
+
+// NewClientProvider creates a client provider
+// param: options - the credentials and endpoints of the clients
+// return: *ClientProvider - the provider
+func NewClientProvider(options ClientOptions) *ClientProvider {
+	return &ClientProvider{
+		options:        options,
+		cloudFormation: make(map[string]*cloudformation.Client),
+		s3:             make(map[string]*s3.Client),
+		acm:            make(map[string]*acm.Client),
+		staging:        make(map[string]*stack.Staging),
+	}
+}
+
+// endpoint returns the endpoint URL of a service, or nil for the endpoint of AWS
+// param: service - the name of the service
+// return: *string - the endpoint URL
+func (p *ClientProvider) endpoint(service string) *string {
+	if url, ok := p.options.Endpoints[service]; ok && url != "" {
+		return aws.String(url)
+	}
+	if p.options.EndpointURL != "" {
+		return aws.String(p.options.EndpointURL)
+	}
+	return nil
+}
+
+// config returns the SDK config of a region
+// The credentials are resolved once (the role is assumed and the MFA code asked only once) and shared by all regions
+// param: region - the region
+// return: aws.Config - the config
+// return: error - the error if any
+func (p *ClientProvider) config(ctx context.Context, region string) (aws.Config, error) {
+	if p.base == nil {
+		opts := []func(*config.LoadOptions) error{config.WithRegion(globalRegion)}
+		if p.options.Profile != "" {
+			opts = append(opts, config.WithSharedConfigProfile(p.options.Profile))
+		}
+		cfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("unable to load SDK config: %w", err)
+		}
+
+		if p.options.AssumeRoleArn != "" {
+			stsClient := sts.NewFromConfig(cfg, func(o *sts.Options) {
+				o.BaseEndpoint = p.endpoint(ServiceSTS)
+			})
+			provider := stscreds.NewAssumeRoleProvider(stsClient, p.options.AssumeRoleArn, p.assumeRoleOptions)
+			cfg.Credentials = aws.NewCredentialsCache(provider)
+		}
+		p.base = &cfg
+	}
+
+	cfg := p.base.Copy()
+	cfg.Region = region
+	return cfg, nil
+}
+
+// assumeRoleOptions sets the options of the session of the assumed role
+// param: o - the options of the assume role provider
+func (p *ClientProvider) assumeRoleOptions(o *stscreds.AssumeRoleOptions) {
+	o.RoleSessionName = "haws"
+	o.Duration = p.options.SessionDuration
+	if o.Duration == 0 {
+		o.Duration = DefaultSessionDuration
+	}
+	if p.options.ExternalId != "" {
+		o.ExternalID = aws.String(p.options.ExternalId)
+	}
+	if p.options.MfaSerial != "" {
+		o.SerialNumber = aws.String(p.options.MfaSerial)
+		o.TokenProvider = p.options.MfaToken
+		if o.TokenProvider == nil {
+			o.TokenProvider = stscreds.StdinTokenProvider
+		}
+	}
+}
+
+// CloudFormation returns the CloudFormation client of a region
+// param: region - the region
+// return: stack.CloudFormationAPI - the client
+// return: error - the error if any
+func (p *ClientProvider) CloudFormation(ctx context.Context, region string) (stack.CloudFormationAPI, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, ok := p.cloudFormation[region]; ok {
+		return client, nil
+	}
+	cfg, err := p.config(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	client := cloudformation.NewFromConfig(cfg, func(o *cloudformation.Options) {
+		o.BaseEndpoint = p.endpoint(ServiceCloudFormation)
+	})
+	p.cloudFormation[region] = client
+	return client, nil
+}
+
+// S3 returns the S3 client of a region
+// With a custom endpoint the buckets are addressed by path, as the emulators expect
+// param: region - the region
+// return: *s3.Client - the client
+// return: error - the error if any
+func (p *ClientProvider) S3(ctx context.Context, region string) (*s3.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.s3Client(ctx, region)
+}
+
+// s3Client returns the S3 client of a region, with the lock held
+func (p *ClientProvider) s3Client(ctx context.Context, region string) (*s3.Client, error) {
+	if client, ok := p.s3[region]; ok {
+		return client, nil
+	}
+	cfg, err := p.config(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = p.endpoint(ServiceS3)
+		o.UsePathStyle = o.BaseEndpoint != nil
+	})
+	p.s3[region] = client
+	return client, nil
+}
+
+// Staging returns the staging of the large templates of a region
+// param: region - the region
+// return: *stack.Staging - the staging
+// return: error - the error if any
+func (p *ClientProvider) Staging(ctx context.Context, region string) (*stack.Staging, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if staging, ok := p.staging[region]; ok {
+		return staging, nil
+	}
+	s3Client, err := p.s3Client(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := p.config(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	stsClient := sts.NewFromConfig(cfg, func(o *sts.Options) {
+		o.BaseEndpoint = p.endpoint(ServiceSTS)
+	})
+	staging := stack.NewStaging(s3Client, stsClient, region)
+	p.staging[region] = staging
+	return staging, nil
+}
+
+// ACM returns the Certificate Manager client of a region
+// param: region - the region
+// return: *acm.Client - the client
+// return: error - the error if any
+func (p *ClientProvider) ACM(ctx context.Context, region string) (*acm.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, ok := p.acm[region]; ok {
+		return client, nil
+	}
+	cfg, err := p.config(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	client := acm.NewFromConfig(cfg, func(o *acm.Options) {
+		o.BaseEndpoint = p.endpoint(ServiceACM)
+	})
+	p.acm[region] = client
+	return client, nil
+}
+
+// Route53 returns the Route53 client
+// return: *route53.Client - the client
+// return: error - the error if any
+func (p *ClientProvider) Route53(ctx context.Context) (*route53.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.route53 == nil {
+		cfg, err := p.config(ctx, globalRegion)
+		if err != nil {
+			return nil, err
+		}
+		p.route53 = route53.NewFromConfig(cfg, func(o *route53.Options) {
+			o.BaseEndpoint = p.endpoint(ServiceRoute53)
+		})
+	}
+	return p.route53, nil
+}
+
+// CloudFront returns the CloudFront client
+// return: *cloudfront.Client - the client
+// return: error - the error if any
+func (p *ClientProvider) CloudFront(ctx context.Context) (*cloudfront.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cloudFront == nil {
+		cfg, err := p.config(ctx, globalRegion)
+		if err != nil {
+			return nil, err
+		}
+		p.cloudFront = cloudfront.NewFromConfig(cfg, func(o *cloudfront.Options) {
+			o.BaseEndpoint = p.endpoint(ServiceCloudFront)
+		})
+	}
+	return p.cloudFront, nil
+}
@@ -0,0 +1,82 @@
+package haws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+)
+
+func TestClientProviderEndpoint(t *testing.T) {
+	p := NewClientProvider(ClientOptions{})
+	if url := p.endpoint(ServiceS3); url != nil {
+		t.Errorf("Expected the endpoint of AWS, got %s", *url)
+	}
+
+	p = NewClientProvider(ClientOptions{
+		EndpointURL: "http://localhost:4566",
+		Endpoints:   map[string]string{ServiceS3: "http://localhost:9000"},
+	})
+	if url := p.endpoint(ServiceS3); url == nil || *url != "http://localhost:9000" {
+		t.Errorf("Expected the endpoint of s3, got %v", url)
+	}
+	if url := p.endpoint(ServiceCloudFormation); url == nil || *url != "http://localhost:4566" {
+		t.Errorf("Expected the endpoint of all the services, got %v", url)
+	}
+}
+
+func TestClientProviderRegions(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", t.TempDir()+"/config")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", t.TempDir()+"/credentials")
+
+	ctx := context.Background()
+	p := NewClientProvider(ClientOptions{EndpointURL: "http://localhost:4566"})
+
+	regional, err := p.CloudFormation(ctx, "eu-central-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	again, _ := p.CloudFormation(ctx, "eu-central-1")
+	if regional != again {
+		t.Error("Expected the client of a region to be shared")
+	}
+	global, _ := p.CloudFormation(ctx, "us-east-1")
+	if regional == global {
+		t.Error("Expected a client per region")
+	}
+
+	h, err := newSite(false, "test", "eu-central-1", "Z123", "example.com", "/", "www")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SetClientProvider(ctx, p); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if h.clientProvider() != p {
+		t.Error("Expected the site to use the provider")
+	}
+	if len(p.cloudFormation) != 2 || len(p.staging) != 2 {
+		t.Errorf("Expected the clients of 2 regions, got %d and %d stagings", len(p.cloudFormation), len(p.staging))
+	}
+}
+
+func TestClientProviderSessionDuration(t *testing.T) {
+	var o stscreds.AssumeRoleOptions
+	NewClientProvider(ClientOptions{MfaSerial: "arn:aws:iam::123456789012:mfa/me"}).assumeRoleOptions(&o)
+	// the session outlives the validation of the certificate, so the MFA code is asked once
+	if o.Duration != DefaultSessionDuration || o.Duration < time.Hour {
+		t.Errorf("Expected the default session duration, got %s", o.Duration)
+	}
+	if o.TokenProvider == nil || o.RoleSessionName != "haws" {
+		t.Errorf("Expected the MFA code to be asked for session haws, got %+v", o)
+	}
+
+	o = stscreds.AssumeRoleOptions{}
+	NewClientProvider(ClientOptions{SessionDuration: 2 * time.Hour}).assumeRoleOptions(&o)
+	if o.Duration != 2*time.Hour {
+		t.Errorf("Expected a session of 2h, got %s", o.Duration)
+	}
+}
@@ -0,0 +1,208 @@
+package haws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// S3API defines the subset of methods used from the AWS S3 client
+type S3API interface {
+	ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// ACMAPI defines the subset of methods used from the AWS Certificate Manager client
+type ACMAPI interface {
+	DescribeCertificate(ctx context.Context, params *acm.DescribeCertificateInput, optFns ...func(*acm.Options)) (*acm.DescribeCertificateOutput, error)
+}
+
+// Destroy deletes the stacks of the site in reverse dependency order, one by one:
+// user, cloudfront, bucket, then certificate
+// Stacks whose exports (or certificate) are still used by another site are kept
+// param: emptyBuckets - remove all the objects from the buckets of a stack before deleting it
+// return: error - the error if any
+func (h *Haws) Destroy(ctx context.Context, emptyBuckets bool) error {
+	order, err := h.order()
+	if err != nil {
+		return err
+	}
+	stacks := teardown(order)
+
+	own := make(map[string]bool)
+	for _, st := range h.stacks {
+		own[*st.GetStackName()] = true
+	}
+
+	// the distribution of this site is allowed to use the certificate
+	ownDistribution := ""
+	if arn, err := h.stacks["cloudfront"].Output(ctx, "CloudFrontArn"); err == nil {
+		ownDistribution = arn
+	}
+
+	for _, name := range stacks {
+		st := h.stacks[name]
+		stackName := *st.GetStackName()
+
+		exists, err := st.Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			logger.Info("Stack %s does not exist, skipping", stackName)
+			continue
+		}
+
+		users, err := st.Importers(ctx)
+		if err != nil {
+			return err
+		}
+		foreign := make([]string, 0)
+		for _, user := range users {
+			if !own[user] {
+				foreign = append(foreign, user)
+			}
+		}
+		if name == "certificate" {
+			distributions, err := h.certificateUsers(ctx, ownDistribution)
+			if err != nil {
+				return err
+			}
+			foreign = append(foreign, distributions...)
+		}
+		if len(foreign) > 0 {
+			logger.Warn("Keeping stack %s: it is still used by %s", stackName, strings.Join(foreign, ", "))
+			continue
+		}
+
+		if emptyBuckets {
+			for _, logicalId := range st.ResourcesOfType("AWS::S3::Bucket") {
+				bucket, err := st.PhysicalResourceId(ctx, logicalId)
+				if err != nil {
+					return err
+				}
+				if err := h.emptyBucket(ctx, bucket); err != nil {
+					return fmt.Errorf("unable to empty bucket %s: %w", bucket, err)
+				}
+			}
+		}
+
+		if h.dryRun {
+			logger.Info("Would delete stack %s", stackName)
+			continue
+		}
+		if err := st.Delete(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// certificateUsers returns the resources other than the distribution of this site that use the certificate
+// param: ownDistribution - the ARN of the distribution of this site
+// return: []string - the ARNs of the resources using the certificate
+// return: error - the error if any
+func (h *Haws) certificateUsers(ctx context.Context, ownDistribution string) ([]string, error) {
+	certificateArn, err := h.stacks["certificate"].PhysicalResourceId(ctx, "HugoSslCertificate")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.ensureACM(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := h.acmClient.DescribeCertificate(ctx, &acm.DescribeCertificateInput{
+		CertificateArn: &certificateArn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]string, 0)
+	for _, user := range resp.Certificate.InUseBy {
+		if user != ownDistribution {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// ensureACM creates the Certificate Manager client of us-east-1 (where the certificates of CloudFront live) if needed
+// return: error - the error if any
+func (h *Haws) ensureACM(ctx context.Context) error {
+	if h.acmClient != nil {
+		return nil
+	}
+	client, err := h.clientProvider().ACM(ctx, globalRegion)
+	if err != nil {
+		return err
+	}
+	h.acmClient = client
+	return nil
+}
+
+// emptyBucket deletes all the objects (and their versions) from a bucket
+// param: bucket - the name of the bucket
+// return: error - the error if any
+func (h *Haws) emptyBucket(ctx context.Context, bucket string) error {
+	if h.dryRun {
+		logger.Info("Would empty bucket %s", bucket)
+		return nil
+	}
+
+	if h.s3Client == nil {
+		client, err := h.clientProvider().S3(ctx, h.region)
+		if err != nil {
+			return err
+		}
+		h.s3Client = client
+	}
+
+	logger.Info("Emptying bucket %s", bucket)
+
+	var keyMarker, versionMarker *string
+	for {
+		resp, err := h.s3Client.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{
+			Bucket:          &bucket,
+			KeyMarker:       keyMarker,
+			VersionIdMarker: versionMarker,
+		})
+		if err != nil {
+			return err
+		}
+
+		objects := make([]s3types.ObjectIdentifier, 0, len(resp.Versions)+len(resp.DeleteMarkers))
+		for _, v := range resp.Versions {
+			objects = append(objects, s3types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+		}
+		for _, m := range resp.DeleteMarkers {
+			objects = append(objects, s3types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+		}
+
+		if len(objects) > 0 {
+			out, err := h.s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+				Bucket: &bucket,
+				Delete: &s3types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+			})
+			if err != nil {
+				return err
+			}
+			if len(out.Errors) > 0 {
+				return fmt.Errorf("unable to delete %s: %s", aws.ToString(out.Errors[0].Key), aws.ToString(out.Errors[0].Message))
+			}
+		}
+
+		if !aws.ToBool(resp.IsTruncated) {
+			return nil
+		}
+		keyMarker = resp.NextKeyMarker
+		versionMarker = resp.NextVersionIdMarker
+	}
+}
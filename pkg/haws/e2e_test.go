@@ -0,0 +1,543 @@
+package haws
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	acmtypes "github.com/aws/aws-sdk-go-v2/service/acm/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/dragosboca/haws/pkg/components"
+	"github.com/dragosboca/haws/pkg/stack"
+	"github.com/dragosboca/haws/pkg/stack/cfnfake"
+)
+
+var _ stack.CloudFormationAPI = (*cfnfake.Fake)(nil)
+
+type mockACM struct {
+	inUseBy      []string
+	alternatives []string
+}
+
+func (m *mockACM) DescribeCertificate(ctx context.Context, params *acm.DescribeCertificateInput, optFns ...func(*acm.Options)) (*acm.DescribeCertificateOutput, error) {
+	return &acm.DescribeCertificateOutput{
+		Certificate: &acmtypes.CertificateDetail{
+			CertificateArn:          params.CertificateArn,
+			Status:                  acmtypes.CertificateStatusIssued,
+			InUseBy:                 m.inUseBy,
+			SubjectAlternativeNames: m.alternatives,
+		},
+	}, nil
+}
+
+// e2eSite is a site deployed on the CloudFormation fakes of its region and of us-east-1
+type e2eSite struct {
+	*Haws
+	regional *cfnfake.Fake
+	global   *cfnfake.Fake
+}
+
+func newE2ESite(t *testing.T) *e2eSite {
+	clock := cfnfake.NewClock()
+	site := &e2eSite{
+		regional: cfnfake.New("eu-central-1", clock),
+		global:   cfnfake.New("us-east-1", clock),
+	}
+	site.regional.OperationTime = 3 * time.Minute
+	site.global.OperationTime = 5 * time.Minute
+	site.Haws = site.reopen(t)
+	return site
+}
+
+// reopen returns a new instance of the site on the same fakes, as a new haws process would
+func (s *e2eSite) reopen(t *testing.T) *Haws {
+	t.Helper()
+	h, err := newSite(false, "test", "eu-central-1", "Z123", "example.com", "/", "www")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetCloudFormationClient("eu-central-1", s.regional)
+	h.SetCloudFormationClient("us-east-1", s.global)
+	h.SetClock(s.regional.Clock())
+	h.acmClient = &mockACM{}
+	return &h
+}
+
+// deployLegacyOAI deploys the bucket stack of a site deployed before the origin access control,
+// with a distribution stack using its origin access identity
+// return: string - the name of the export of the identity
+func (s *e2eSite) deployLegacyOAI(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+
+	s.bucket.KeepOAI(true)
+	if err := s.DeployStack(ctx, "bucket"); err != nil {
+		t.Fatalf("Deploy of the legacy bucket: %v", err)
+	}
+	oai := s.stacks["bucket"].GetExportName("Oai")
+	if _, ok := s.regional.Exports()[oai]; !ok {
+		t.Fatalf("Expected the legacy bucket to export %s", oai)
+	}
+
+	arn := s.stacks["cloudfront"].GetExportName("CloudFrontArn")
+	body := `{"Resources": {"topic": {"Type": "AWS::SNS::Topic", "Properties": {"DisplayName": {"Fn::ImportValue": "` + oai + `"}}}},
+		"Outputs": {"CloudFrontArn": {"Value": {"Ref": "topic"}, "Export": {"Name": "` + arn + `"}}}}`
+	cdn := s.stacks["cloudfront"].GetStackName()
+	if _, err := s.regional.CreateChangeSet(ctx, &cloudformation.CreateChangeSetInput{
+		StackName:     cdn,
+		ChangeSetName: aws.String("legacy"),
+		ChangeSetType: types.ChangeSetTypeCreate,
+		TemplateBody:  &body,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.regional.ExecuteChangeSet(ctx, &cloudformation.ExecuteChangeSetInput{
+		StackName:     cdn,
+		ChangeSetName: aws.String("legacy"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.regional.Clock().Advance(time.Hour)
+	return oai
+}
+
+// status returns the status of a stack of the site
+func (s *e2eSite) status(t *testing.T, name string) string {
+	t.Helper()
+	status, err := s.stacks[name].Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status of %s: %v", name, err)
+	}
+	return status.Status
+}
+
+// readers returns the distributions allowed to read the deployed bucket
+func (s *e2eSite) readers(t *testing.T) string {
+	t.Helper()
+	resp, err := s.regional.DescribeStacks(context.Background(), &cloudformation.DescribeStacksInput{
+		StackName: s.stacks["bucket"].GetStackName(),
+	})
+	if err != nil {
+		t.Fatalf("Describe bucket: %v", err)
+	}
+	for _, param := range resp.Stacks[0].Parameters {
+		if aws.ToString(param.ParameterKey) == "Distributions" {
+			return aws.ToString(param.ParameterValue)
+		}
+	}
+	t.Fatal("Expected the bucket to have a Distributions parameter")
+	return ""
+}
+
+func TestE2E_Deploy(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	for _, name := range []string{"certificate", "cloudfront", "user"} {
+		if status := site.status(t, name); status != string(types.StackStatusCreateComplete) {
+			t.Errorf("Expected stack %s in CREATE_COMPLETE, got %s", name, status)
+		}
+	}
+	// the bucket is updated to let the new distribution read it
+	if status := site.status(t, "bucket"); status != string(types.StackStatusUpdateComplete) {
+		t.Errorf("Expected stack bucket in UPDATE_COMPLETE, got %s", status)
+	}
+
+	// the certificate lives in us-east-1, the other stacks share the exports of the region of the site
+	if _, ok := site.global.Exports()[site.stacks["certificate"].GetExportName("Arn")]; !ok {
+		t.Errorf("Expected the certificate export in us-east-1, got %v", site.global.Exports())
+	}
+	for _, name := range []string{site.stacks["bucket"].GetExportName("Domain"), site.stacks["cloudfront"].GetExportName("CloudFrontArn")} {
+		if _, ok := site.regional.Exports()[name]; !ok {
+			t.Errorf("Expected export %s, got %v", name, site.regional.Exports())
+		}
+	}
+
+	values, err := site.CollectOutputs(ctx, false)
+	if err != nil {
+		t.Fatalf("CollectOutputs: %v", err)
+	}
+	if values["HAWS_BUCKET_NAME"] != "haws-test-example-com-bucket" || values["HAWS_DISTRIBUTION_ID"] == "" {
+		t.Errorf("Unexpected outputs %v", values)
+	}
+	if _, ok := values["HAWS_SECRET_ACCESS_KEY"]; ok {
+		t.Error("Expected the secret access key to be left out")
+	}
+	if readers := site.readers(t); readers != values["HAWS_DISTRIBUTION_ARN"] {
+		t.Errorf("Expected only the distribution of the site to read the bucket, got %s", readers)
+	}
+
+	executed := site.regional.Calls("ExecuteChangeSet") + site.global.Calls("ExecuteChangeSet")
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Redeploy: %v", err)
+	}
+	if again := site.regional.Calls("ExecuteChangeSet") + site.global.Calls("ExecuteChangeSet"); again != executed {
+		t.Errorf("Expected the redeployment without changes to execute nothing, got %d more change sets", again-executed)
+	}
+}
+
+func TestE2E_PlanApply(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+
+	site.SetTags(map[string]string{"team": "web"})
+	plan, err := site.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	for name, stackPlan := range plan.Stacks {
+		if stackPlan.ChangeSet == "" || stackPlan.ChangeSetType != "UPDATE" {
+			t.Errorf("Expected an update change set for stack %s, got %+v", name, stackPlan)
+		}
+	}
+
+	if err := site.Apply(ctx, plan); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for name := range plan.Stacks {
+		if status := site.status(t, name); status != string(types.StackStatusUpdateComplete) {
+			t.Errorf("Expected stack %s in UPDATE_COMPLETE, got %s", name, status)
+		}
+	}
+
+	if err := site.Apply(ctx, plan); err == nil {
+		t.Error("Expected a plan that was already applied to be refused")
+	}
+}
+
+func TestE2E_PlanApplyAnotherProcess(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+	oai := site.deployLegacyOAI(t)
+	if err := site.DeployStack(ctx, "certificate"); err != nil {
+		t.Fatalf("Deploy of the certificate: %v", err)
+	}
+
+	// haws plan and haws apply run in different processes
+	plan, err := site.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if plan.Stacks["cloudfront"].ChangeSetType != "UPDATE" {
+		t.Fatalf("Expected the distribution to switch to the origin access control, got %+v", plan.Stacks["cloudfront"])
+	}
+	if err := site.reopen(t).Apply(ctx, plan); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if _, ok := site.regional.Exports()[oai]; !ok {
+		t.Error("Expected the origin access identity to be kept by the apply")
+	}
+}
+
+func TestE2E_PlanApplyNewSite(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+
+	// each plan covers the stacks whose dependencies exist: certificate and bucket, then cloudfront, then user
+	// (with the bucket letting the new distribution read it)
+	expected := []map[string]string{
+		{"certificate": "CREATE", "bucket": "CREATE", "cloudfront": "deferred", "user": "deferred"},
+		{"certificate": "none", "bucket": "none", "cloudfront": "CREATE", "user": "deferred"},
+		{"certificate": "none", "bucket": "UPDATE", "cloudfront": "none", "user": "CREATE"},
+	}
+	for round, states := range expected {
+		plan, err := site.Plan(ctx)
+		if err != nil {
+			t.Fatalf("Plan %d: %v", round, err)
+		}
+		for name, state := range states {
+			stackPlan := plan.Stacks[name]
+			actual := stackPlan.ChangeSetType
+			if stackPlan.Deferred != "" {
+				actual = "deferred"
+			} else if stackPlan.ChangeSet == "" {
+				actual = "none"
+			}
+			if actual != state {
+				t.Errorf("Plan %d: expected %s for stack %s, got %s", round, state, name, actual)
+			}
+		}
+
+		err = site.Apply(ctx, plan)
+		if last := round == len(expected)-1; last && err != nil {
+			t.Fatalf("Apply %d: %v", round, err)
+		} else if !last && (err == nil || !strings.Contains(err.Error(), "plan again")) {
+			t.Fatalf("Apply %d: expected the deferred stacks to be refused, got %v", round, err)
+		}
+	}
+	for _, name := range []string{"certificate", "cloudfront", "user"} {
+		if status := site.status(t, name); status != string(types.StackStatusCreateComplete) {
+			t.Errorf("Expected stack %s in CREATE_COMPLETE, got %s", name, status)
+		}
+	}
+	// the bucket is updated to let the new distribution read it
+	if status := site.status(t, "bucket"); status != string(types.StackStatusUpdateComplete) {
+		t.Errorf("Expected stack bucket in UPDATE_COMPLETE, got %s", status)
+	}
+}
+
+func TestE2E_PlanCertificateReplacement(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	oldArn, err := site.stacks["certificate"].Output(ctx, "Arn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new alias replaces the certificate, the distribution must wait for its ARN
+	if err := site.SetHostnames([]string{"example.com"}, false); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := site.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if !strings.Contains(plan.Stacks["cloudfront"].Deferred, "HugoSslCertificate") || plan.Stacks["cloudfront"].ChangeSet != "" {
+		t.Errorf("Expected the cloudfront stack to be deferred, got %+v", plan.Stacks["cloudfront"])
+	}
+	if err := site.Apply(ctx, plan); err == nil || !strings.Contains(err.Error(), "plan again") {
+		t.Fatalf("Expected the deferred stacks to be refused, got %v", err)
+	}
+
+	newArn, err := site.stacks["certificate"].Output(ctx, "Arn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newArn == oldArn {
+		t.Fatalf("Expected the certificate to be replaced, still %s", newArn)
+	}
+	plan, err = site.Plan(ctx)
+	if err != nil {
+		t.Fatalf("Plan again: %v", err)
+	}
+	if arn := plan.Stacks["cloudfront"].Parameters["CertificateArn"]; arn != newArn {
+		t.Errorf("Expected the distribution planned with the new certificate %s, got %s", newArn, arn)
+	}
+	if err := site.Apply(ctx, plan); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+}
+
+func TestE2E_ReplaceProtectedBucket(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+
+	if err := site.SetStackParameterValue("bucket", "BucketName", "other-bucket"); err != nil {
+		t.Fatal(err)
+	}
+	err := site.DeployStack(ctx, "bucket")
+
+	var failed *stack.StackFailedError
+	if !errors.As(err, &failed) {
+		t.Fatalf("Expected a StackFailedError, got %v", err)
+	}
+	if failed.Status != string(types.StackStatusUpdateRollbackComplete) || len(failed.Resources) != 1 || failed.Resources[0].LogicalId != "bucket" {
+		t.Errorf("Expected the stack policy to refuse the replacement of the bucket, got %+v", failed)
+	}
+	if name := site.regional.Exports()[site.stacks["bucket"].GetExportName("Name")]; name != "haws-test-example-com-bucket" {
+		t.Errorf("Expected the bucket to be kept, got %s", name)
+	}
+}
+
+func TestE2E_Drift(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+
+	drifted, err := site.Drift(ctx)
+	if err != nil || drifted {
+		t.Fatalf("Expected no drift, got %t, %v", drifted, err)
+	}
+
+	site.regional.SetDrift(*site.stacks["cloudfront"].GetStackName(), "distribution", types.PropertyDifference{
+		DifferenceType: types.DifferenceTypeNotEqual,
+		PropertyPath:   aws.String("/DistributionConfig/Enabled"),
+		ExpectedValue:  aws.String("true"),
+		ActualValue:    aws.String("false"),
+	})
+	drifted, err = site.Drift(ctx)
+	if err != nil || !drifted {
+		t.Fatalf("Expected the distribution to drift, got %t, %v", drifted, err)
+	}
+	status, err := site.stacks["cloudfront"].Status(ctx)
+	if err != nil || status.DriftStatus != string(types.StackDriftStatusDrifted) {
+		t.Errorf("Expected the stack to be reported as drifted, got %+v, %v", status, err)
+	}
+}
+
+func TestE2E_Destroy(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+
+	// another stack of the region uses the bucket of the site
+	body := `{"Resources": {"topic": {"Type": "AWS::SNS::Topic", "Properties": {"DisplayName": {"Fn::ImportValue": "` +
+		site.stacks["bucket"].GetExportName("Name") + `"}}}}}`
+	if _, err := site.regional.CreateChangeSet(ctx, &cloudformation.CreateChangeSetInput{
+		StackName:     aws.String("foreign"),
+		ChangeSetName: aws.String("foreign"),
+		ChangeSetType: types.ChangeSetTypeCreate,
+		TemplateBody:  &body,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := site.regional.ExecuteChangeSet(ctx, &cloudformation.ExecuteChangeSetInput{
+		StackName:     aws.String("foreign"),
+		ChangeSetName: aws.String("foreign"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	site.regional.Clock().Advance(time.Hour)
+
+	if err := site.Destroy(ctx, false); err != nil {
+		t.Fatalf("Destroy: %v", err)
+	}
+	if stacks := site.regional.StackNames(); !reflect.DeepEqual(stacks, []string{"foreign", *site.stacks["bucket"].GetStackName()}) {
+		t.Errorf("Expected only the bucket used by the foreign stack to be kept, got %v", stacks)
+	}
+	if stacks := site.global.StackNames(); len(stacks) != 0 {
+		t.Errorf("Expected the certificate to be deleted, got %v", stacks)
+	}
+}
+
+func TestE2E_MigrateOAI(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+
+	// the stacks of a site deployed before the origin access control, its distribution uses the origin access identity
+	oai := site.deployLegacyOAI(t)
+
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	exports := site.regional.Exports()
+	if _, ok := exports[oai]; ok {
+		t.Errorf("Expected the origin access identity to be removed once the distribution switched, got %v", exports)
+	}
+	if _, ok := exports[site.stacks["bucket"].GetExportName("Oac")]; !ok {
+		t.Errorf("Expected the origin access control to be exported, got %v", exports)
+	}
+	if resources := site.stacks["bucket"].ResourcesOfType("AWS::CloudFront::CloudFrontOriginAccessIdentity"); len(resources) != 0 {
+		t.Errorf("Expected no origin access identity in the template, got %v", resources)
+	}
+}
+
+func TestE2E_SharedWildcardCertificate(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+
+	// a second site of the prefix in the same zone
+	blog, err := newSite(false, "test", "eu-central-1", "Z123", "example.com", "/blog", "blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blog.SetCloudFormationClient("eu-central-1", site.regional)
+	blog.SetCloudFormationClient("us-east-1", site.global)
+	blog.SetClock(site.regional.Clock())
+	blog.acmClient = &mockACM{}
+
+	for _, h := range []*Haws{site.Haws, &blog} {
+		if err := h.SetHostnames(nil, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Deploy(ctx); err != nil {
+			t.Fatalf("Deploy: %v", err)
+		}
+	}
+	if stacks := site.global.StackNames(); !reflect.DeepEqual(stacks, []string{"test-certificate"}) {
+		t.Errorf("Expected one wildcard certificate for both sites, got %v", stacks)
+	}
+
+	// both distributions read the shared bucket, and only them
+	arns := make([]string, 0)
+	for _, h := range []*Haws{&blog, site.Haws} {
+		arn, err := h.stacks["cloudfront"].Output(ctx, "CloudFrontArn")
+		if err != nil {
+			t.Fatal(err)
+		}
+		arns = append(arns, arn)
+	}
+	sort.Strings(arns)
+	if readers := site.readers(t); readers != strings.Join(arns, ",") {
+		t.Errorf("Expected the distributions %v to read the bucket, got %s", arns, readers)
+	}
+
+	// the distribution of the blog still uses the certificate
+	arn, err := blog.stacks["cloudfront"].Output(ctx, "CloudFrontArn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	site.acmClient = &mockACM{inUseBy: []string{arn}}
+	if err := site.Destroy(ctx, false); err != nil {
+		t.Fatalf("Destroy: %v", err)
+	}
+	if stacks := site.global.StackNames(); !reflect.DeepEqual(stacks, []string{"test-certificate"}) {
+		t.Errorf("Expected the certificate used by the blog to be kept, got %v", stacks)
+	}
+}
+
+func TestE2E_RemoveLegacyCertificate(t *testing.T) {
+	ctx := context.Background()
+	site := newE2ESite(t)
+
+	// the certificate stack shared by the sites of the prefix deployed with an older haws
+	certificate, err := components.NewCertificate(&components.CertificateInput{
+		Prefix: "test", Region: "us-east-1", Domain: "example.com", ZoneId: "Z123", Wildcard: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := site.stacks["certificate"].Sibling(certificate)
+	if err := legacy.Run(ctx); err != nil {
+		t.Fatalf("Deploy of the legacy certificate: %v", err)
+	}
+
+	// still used by the distribution of another site
+	site.acmClient = &mockACM{inUseBy: []string{"arn:aws:cloudfront::123456789012:distribution/EBLOG"}}
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	if stacks := site.global.StackNames(); !reflect.DeepEqual(stacks, []string{"test-certificate", "test-www-example-com-certificate"}) {
+		t.Errorf("Expected the legacy certificate used by another site to be kept, got %v", stacks)
+	}
+
+	// the wildcard certificate of the sites of the prefix
+	site.acmClient = &mockACM{alternatives: []string{"*.example.com"}}
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	if stacks := site.global.StackNames(); len(stacks) != 2 {
+		t.Errorf("Expected the wildcard certificate to be kept, got %v", stacks)
+	}
+
+	site.acmClient = &mockACM{}
+	if err := site.Deploy(ctx); err != nil {
+		t.Fatalf("Deploy: %v", err)
+	}
+	if stacks := site.global.StackNames(); !reflect.DeepEqual(stacks, []string{"test-www-example-com-certificate"}) {
+		t.Errorf("Expected the unused legacy certificate to be deleted, got %v", stacks)
+	}
+}
@@ -2,56 +2,95 @@ package haws
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/route53"
 	"github.com/dragosboca/haws/pkg/components"
 	"github.com/dragosboca/haws/pkg/logger"
 	"github.com/dragosboca/haws/pkg/stack"
 )
 
 type Haws struct {
-	dryRun bool
-	stacks map[string]*stack.Stack
+	dryRun               bool
+	region               string
+	stacks               map[string]*stack.Stack
+	certificate          *components.Certificate
+	bucket               *components.Bucket
+	cdn                  *components.Cdn
+	imports              map[string]map[string]string
+	s3Client             S3API
+	acmClient            ACMAPI
+	bucketLocationClient BucketLocationAPI
+	cloudFrontClient     CloudFrontAPI
+	clients              *ClientProvider
+
+	// accountDistributions lets every distribution of the account read the bucket
+	accountDistributions bool
 }
 
-func New(dryRun bool, prefix string, region string, zone_id string, bucketPath string, record string) Haws {
-	domain, err := getZoneDomain(zone_id)
+// New builds the stacks of a site
+// param: zones - the resolver of the domain of the hosted zone zone_id
+// return: Haws - the site
+// return: error - the error if the domain of the zone cannot be found, or the record is not a valid hostname
+func New(ctx context.Context, zones ZoneResolver, dryRun bool, prefix string, region string, zone_id string, bucketPath string, record string) (Haws, error) {
+	domain, err := zones.ZoneDomain(ctx, zone_id)
 	if err != nil {
-		logger.Fatal("Failed to get zone domain: %v", err)
+		return Haws{}, err
 	}
+	return newSite(dryRun, prefix, region, zone_id, domain, bucketPath, record)
+}
 
+// newSite builds the stacks of a site in a hosted zone whose domain is already known
+// param: domain - the domain of the hosted zone zone_id
+// return: Haws - the site
+// return: error - the error if the record is not a valid hostname
+func newSite(dryRun bool, prefix string, region string, zone_id string, domain string, bucketPath string, record string) (Haws, error) {
 	h := Haws{
 		dryRun: dryRun,
+		region: region,
 		stacks: make(map[string]*stack.Stack),
 	}
 
-	h.stacks["certificate"] = stack.NewStack(components.NewCertificate(&components.CertificateInput{
+	recordName := domain
+	if record != "" {
+		recordName = fmt.Sprintf("%s.%s", record, domain)
+	}
+	certificate, err := components.NewCertificate(&components.CertificateInput{
 		Prefix: prefix,
 		Region: region,
 		Domain: domain,
 		ZoneId: zone_id,
-	}))
+		Names:  []string{recordName},
+	})
+	if err != nil {
+		return Haws{}, err
+	}
+	h.certificate = certificate
+	h.stacks["certificate"] = stack.NewStack(h.certificate)
 
-	h.stacks["bucket"] = stack.NewStack(components.NewBucket(&components.BucketInput{
+	h.bucket = components.NewBucket(&components.BucketInput{
 		Prefix: prefix,
 		Region: region,
 		Domain: domain,
-	}))
+	})
+	h.stacks["bucket"] = stack.NewStack(h.bucket)
 
-	h.stacks["cloudfront"] = stack.NewStack(components.NewCdn(&components.CdnInput{
-		Prefix:         prefix,
-		Path:           bucketPath,
-		Region:         region,
-		Domain:         domain,
-		Record:         record,
-		CertificateArn: h.stacks["certificate"].GetExportName("Arn"), // FIXME: this is not working because one cannot reference a resource from another region
-		BucketDomain:   h.stacks["bucket"].GetExportName("Domain"),
-		BucketOAI:      h.stacks["bucket"].GetExportName("Oai"),
-		ZoneId:         zone_id,
-	}))
+	cdn, err := components.NewCdn(&components.CdnInput{
+		Prefix:       prefix,
+		Path:         bucketPath,
+		Region:       region,
+		Domain:       domain,
+		Record:       record,
+		BucketDomain: h.stacks["bucket"].GetExportName("Domain"),
+		BucketOAC:    h.stacks["bucket"].GetExportName("Oac"),
+		ZoneId:       zone_id,
+	})
+	if err != nil {
+		return Haws{}, err
+	}
+	h.cdn = cdn
+	h.stacks["cloudfront"] = stack.NewStack(h.cdn)
 
 	h.stacks["user"] = stack.NewStack(components.NewIamUser(&components.UserInput{
 		Prefix:        prefix,
@@ -60,42 +99,131 @@ func New(dryRun bool, prefix string, region string, zone_id string, bucketPath s
 		Domain:        domain,
 		Record:        record,
 		BucketName:    h.stacks["bucket"].GetExportName("Name"),
-		CloudfrontArn: h.stacks["cloudfront"].GetExportName("Arn"),
+		CloudfrontArn: h.stacks["cloudfront"].GetExportName("CloudFrontArn"),
 	}))
-	return h
+	return h, nil
 }
 
+// Deploy deploys the stacks of the site, running the stacks that do not depend on each other concurrently
+// The bucket stack is deployed again once the distribution is: a new distribution is allowed to read the bucket,
+// and a site deployed with an Origin Access Identity is migrated to the Origin Access Control (the identity is
+// removed from the bucket stack once the distribution no longer uses it)
+// The certificate stack shared by the sites of the prefix before they had their own one is deleted once no distribution uses it
+// return: error - the first error if any
 func (h *Haws) Deploy(ctx context.Context) error {
-	stacks := []string{"certificate", "bucket", "cloudfront", "user"}
-	for _, stack := range stacks {
-		if stack == "cloudfront" { // CloudFormation cross-region limitation workaround
-			if err := h.GetStackOutput(ctx, "certificate"); err != nil {
-				return err
-			}
+	order, err := h.order()
+	if err != nil {
+		return err
+	}
+	legacy, err := h.keepLegacyOAI(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := h.setBucketReaders(ctx); err != nil {
+		return err
+	}
+	err = runWaves(ctx, order, func(ctx context.Context, stack string) error {
+		if err := h.resolveParameters(ctx, stack); err != nil {
+			return err
+		}
+		return h.DeployStack(ctx, stack)
+	})
+	if err != nil {
+		return err
+	}
+	if err := h.updateBucketAccess(ctx, legacy); err != nil {
+		return err
+	}
+	return h.removeLegacyCertificate(ctx)
+}
 
-			certificateArn, err := h.GetOutputByName("certificate", "certificateArn")
-			if err != nil {
-				return err
-			}
+// resolveParameters sets the parameters of a stack that reference the outputs of other stacks
+// In dry-run mode the dry-run values of the outputs are used
+// param: name - the name of the stack
+// return: error - the error if any
+func (h *Haws) resolveParameters(ctx context.Context, name string) error {
+	st := h.stacks[name]
+	for _, ref := range st.GetReferences() {
+		producer, ok := h.stacks[ref.Stack]
+		if !ok {
+			return fmt.Errorf("stack %s references unknown stack %s", name, ref.Stack)
+		}
 
-			if err = h.SetStackParameterValue("certificate", "certificateArn", certificateArn); err != nil {
+		var value string
+		if h.dryRun {
+			if value, ok = producer.GetDryRunOutputs()[ref.Output]; !ok {
+				return fmt.Errorf("stack %s has no output %s", *producer.GetStackName(), ref.Output)
+			}
+		} else {
+			var err error
+			if value, err = producer.Output(ctx, ref.Output); err != nil {
 				return err
 			}
 		}
-		if err := h.DeployStack(ctx, stack); err != nil {
+
+		logger.Debug("Parameter %s of stack %s is output %s of stack %s: %s", ref.Parameter, name, ref.Output, ref.Stack, value)
+		if err := st.SetParameterValue(ref.Parameter, value); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// GetOutputs reads the outputs of all the stacks of the site
+// return: error - the first error if any
+func (h *Haws) GetOutputs(ctx context.Context) error {
+	order, err := h.order()
+	if err != nil {
+		return err
+	}
+	return runWaves(ctx, order, h.GetStackOutput)
+}
+
+// Drift runs the drift detection on all the stacks of the site and prints the drifted resources
+// return: bool - true if at least one resource drifted
+// return: error - the error if any
+func (h *Haws) Drift(ctx context.Context) (bool, error) {
+	order, err := h.order()
+	if err != nil {
+		return false, err
+	}
+
+	drifted := false
+	for _, name := range flatten(order) {
+		st := h.stacks[name]
+		exists, err := st.Exists(ctx)
+		if err != nil {
+			return drifted, err
+		}
+		if !exists {
+			logger.Warn("Stack %s does not exist, skipping", *st.GetStackName())
+			continue
+		}
+
+		drifts, err := st.DetectDrift(ctx)
+		if err != nil {
+			return drifted, err
+		}
+		st.LogDrift(drifts)
+		if len(drifts) > 0 {
+			drifted = true
+		}
+	}
+	return drifted, nil
+}
+
 func (h *Haws) DeployStack(ctx context.Context, name string) error {
 	if h.dryRun {
 		logger.Info("DryRunning %s", name)
 		return h.stacks[name].DryRun(ctx)
 	} else {
 		logger.Info("Running %s", name)
-		return h.stacks[name].Run(ctx)
+		err := h.stacks[name].Run(ctx)
+		if errors.Is(err, stack.ErrNoChanges) {
+			logger.Info("Stack %s is up to date", *h.stacks[name].GetStackName())
+			return nil
+		}
+		return err
 	}
 }
 
@@ -103,25 +231,134 @@ func (h *Haws) GetStackOutput(ctx context.Context, name string) error {
 	return h.stacks[name].GetOutputs(ctx)
 }
 
-func getZoneDomain(zoneId string) (string, error) {
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return "", fmt.Errorf("unable to load SDK config: %w", err)
+// SetConfirm sets the function used to approve the change sets of all stacks
+func (h *Haws) SetConfirm(confirm stack.ConfirmFunc) {
+	for _, st := range h.stacks {
+		st.SetConfirm(confirm)
 	}
-	
-	svc := route53.NewFromConfig(cfg)
-	
-	result, err := svc.GetHostedZone(ctx, &route53.GetHostedZoneInput{
-		Id: &zoneId,
-	})
-	if err != nil {
-		return "", err
+}
+
+// SetClientProvider makes the stacks and the site use the AWS clients of a provider
+// param: clients - the provider
+// return: error - the error if a client cannot be created
+func (h *Haws) SetClientProvider(ctx context.Context, clients *ClientProvider) error {
+	h.clients = clients
+	for _, st := range h.stacks {
+		client, err := clients.CloudFormation(ctx, st.GetRegion())
+		if err != nil {
+			return err
+		}
+		st.SetCloudFormationClient(client)
+
+		staging, err := clients.Staging(ctx, st.GetRegion())
+		if err != nil {
+			return err
+		}
+		st.SetStaging(staging)
+	}
+	return nil
+}
+
+// clientProvider returns the provider of the AWS clients, with the default credentials if none was set
+func (h *Haws) clientProvider() *ClientProvider {
+	if h.clients == nil {
+		h.clients = NewClientProvider(ClientOptions{})
+	}
+	return h.clients
+}
+
+// SetCloudFormationClient sets the CloudFormation client of the stacks living in a region
+// param: region - the region of the client
+// param: client - the client
+func (h *Haws) SetCloudFormationClient(region string, client stack.CloudFormationAPI) {
+	for _, st := range h.stacks {
+		if st.GetRegion() == region {
+			st.SetCloudFormationClient(client)
+		}
 	}
-	// trim trailing dot if any
-	domain := strings.TrimSuffix(*result.HostedZone.Name, ".")
+}
 
-	return domain, nil
+// SetClock sets the clock used by all the stacks while waiting for their operations
+func (h *Haws) SetClock(clock stack.Clock) {
+	for _, st := range h.stacks {
+		st.SetClock(clock)
+	}
+}
+
+// SetTimeouts overrides the maximum duration of the operations on the given stacks
+// param: timeouts - the timeouts indexed by stack name (certificate, bucket, cloudfront, user)
+// return: error - the error if a stack does not exist
+func (h *Haws) SetTimeouts(timeouts map[string]time.Duration) error {
+	for name, timeout := range timeouts {
+		st, ok := h.stacks[name]
+		if !ok {
+			return fmt.Errorf("stack %s not found", name)
+		}
+		st.SetTimeout(timeout)
+	}
+	return nil
+}
+
+// SetTags sets the tags applied to all the stacks of the site (and propagated to their resources)
+// param: tags - the tags indexed by key
+func (h *Haws) SetTags(tags map[string]string) {
+	for _, st := range h.stacks {
+		st.SetTags(tags)
+	}
+}
+
+// SetRoleArn sets the service role CloudFormation assumes to operate on all the stacks of the site
+func (h *Haws) SetRoleArn(roleArn string) {
+	for _, st := range h.stacks {
+		st.SetRoleArn(roleArn)
+	}
+}
+
+// SetAllowReplace allows the change sets to replace or delete the content bucket and the distribution
+func (h *Haws) SetAllowReplace(allow bool) {
+	for _, st := range h.stacks {
+		st.SetAllowReplace(allow)
+	}
+}
+
+// SetHostnames sets the other hostnames served by the site and the names covered by its certificate
+// param: aliases - the hostnames served besides the record of the site, in the domain of the zone
+// param: wildcard - use the certificate of the domain and *.domain shared by the sites of the prefix
+// return: error - the error if a hostname is not in the zone, or not covered by the wildcard certificate
+func (h *Haws) SetHostnames(aliases []string, wildcard bool) error {
+	if err := h.cdn.SetAliases(aliases); err != nil {
+		return err
+	}
+	return h.certificate.SetNames(h.cdn.Hostnames(), wildcard)
+}
+
+// AllowAccountDistributions lets every distribution of the account signing with an origin access control read
+// the bucket, instead of the distributions of the sites sharing it
+// param: allow - true to allow all the distributions of the account
+func (h *Haws) AllowAccountDistributions(allow bool) {
+	h.accountDistributions = allow
+	h.bucket.AllowAccountDistributions(allow)
+}
+
+// SetErrorPages sets the pages the distribution serves for the missing pages
+// param: pages - the error pages
+// return: error - the error if the configuration is not valid
+func (h *Haws) SetErrorPages(pages components.ErrorPages) error {
+	return h.cdn.SetErrorPages(pages)
+}
+
+// SetCache sets the cache policies and the extra cache behaviors of the distribution
+// param: cache - the cache model
+// return: error - the error if the cache model is not valid
+func (h *Haws) SetCache(cache components.Cache) error {
+	return h.cdn.SetCache(cache)
+}
+
+// SetResponseHeaders sets the headers the distribution adds to the responses
+// param: headers - the headers
+// return: error - the error if the headers are not valid
+func (h *Haws) SetResponseHeaders(headers components.ResponseHeaders) error {
+	return h.cdn.SetResponseHeaders(headers)
 }
 
 func (h *Haws) SetStackParameterValue(stack string, parameter string, value string) error {
@@ -133,7 +370,10 @@ func (h *Haws) SetStackParameterValue(stack string, parameter string, value stri
 
 func (h *Haws) GetOutputByName(stack string, output string) (string, error) {
 	if st, ok := h.stacks[stack]; ok {
-		return st.Outputs[st.GetExportName(output)], nil
+		if value, ok := st.Outputs[output]; ok {
+			return value, nil
+		}
+		return "", fmt.Errorf("stack %s has no output %s", *st.GetStackName(), output)
 	}
 	return "", fmt.Errorf("stack %s not found", stack)
 
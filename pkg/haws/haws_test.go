@@ -0,0 +1,43 @@
+package haws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/dragosboca/haws/pkg/components"
+	"github.com/dragosboca/haws/pkg/stack"
+)
+
+func TestResolveParameters_DryRun(t *testing.T) {
+	certificate, err := components.NewCertificate(&components.CertificateInput{Prefix: "test", Domain: "example.com", ZoneId: "zone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cdn, err := components.NewCdn(&components.CdnInput{Prefix: "test", Path: "/", Region: "eu-central-1", Domain: "example.com", Record: "www"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Haws{
+		dryRun: true,
+		stacks: map[string]*stack.Stack{
+			"certificate": stack.NewStack(certificate),
+			"cloudfront":  stack.NewStack(cdn),
+		},
+	}
+
+	if err := h.resolveParameters(context.Background(), "cloudfront"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := certificate.GetDryRunOutputs()["Arn"]
+	actual := ""
+	for _, param := range cdn.GetParameters() {
+		if aws.ToString(param.ParameterKey) == "CertificateArn" {
+			actual = aws.ToString(param.ParameterValue)
+		}
+	}
+	if actual != expected {
+		t.Errorf("Expected the certificate ARN %s, got %q", expected, actual)
+	}
+}
@@ -0,0 +1,268 @@
+package haws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/dragosboca/haws/pkg/components"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// CloudFrontAPI defines the subset of methods used from the AWS CloudFront client
+type CloudFrontAPI interface {
+	GetDistribution(ctx context.Context, params *cloudfront.GetDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetDistributionOutput, error)
+}
+
+// BucketLocationAPI defines the method used from the AWS S3 client to validate an imported bucket
+type BucketLocationAPI interface {
+	GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error)
+}
+
+// SetImports declares existing resources adopted by the stacks of the site
+// The imported resources are retained when removed from the stacks, and the stacks use their names,
+// so the imports must stay in the config for every later deployment
+// param: imports - the physical ids indexed by logical id (bucket = "bucket name", distribution = "distribution id")
+// return: error - the error if a resource cannot be imported
+func (h *Haws) SetImports(imports map[string]string) error {
+	h.imports = make(map[string]map[string]string)
+	for resource, id := range imports {
+		name := ""
+		for stackName, st := range h.stacks {
+			if _, ok := st.GetImportable()[resource]; ok {
+				name = stackName
+			}
+		}
+		if name == "" {
+			return fmt.Errorf("resource %s cannot be imported (importable: %s)", resource, strings.Join(h.importable(), ", "))
+		}
+		if h.imports[name] == nil {
+			h.imports[name] = make(map[string]string)
+		}
+		h.imports[name][resource] = id
+	}
+
+	for name, resources := range h.imports {
+		if err := h.stacks[name].Adopt(resources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importable returns the resources of the site that can be imported
+// return: []string - the logical ids, sorted
+func (h *Haws) importable() []string {
+	names := make([]string, 0)
+	for _, st := range h.stacks {
+		for resource := range st.GetImportable() {
+			names = append(names, resource)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Import adopts the existing resources declared with SetImports, then deploys the rest of the site
+// The stacks are processed one by one, in dependency order
+// return: error - the error if any
+func (h *Haws) Import(ctx context.Context) error {
+	if len(h.imports) == 0 {
+		return fmt.Errorf("nothing to import: declare the existing resources in the [import] table of the config file")
+	}
+	if err := h.validateImports(ctx); err != nil {
+		return err
+	}
+
+	order, err := h.order()
+	if err != nil {
+		return err
+	}
+	if _, err := h.setBucketReaders(ctx); err != nil {
+		return err
+	}
+	for _, name := range flatten(order) {
+		if err := h.resolveParameters(ctx, name); err != nil {
+			return err
+		}
+		if resources, ok := h.imports[name]; ok {
+			if id, ok := resources["distribution"]; ok {
+				// the origin and the certificate are known once the bucket and certificate stacks are deployed
+				want, err := h.templateDistribution(ctx)
+				if err != nil {
+					return err
+				}
+				if err := h.compareDistribution(ctx, id, want); err != nil {
+					return err
+				}
+				logger.Warn("CloudFormation cannot import Route53 records: if %s already exists, delete it before the deployment of %s so it can be created",
+					h.stacks[name].ParameterValues()["RecordName"], *h.stacks[name].GetStackName())
+			}
+			if err := h.stacks[name].Import(ctx, resources); err != nil {
+				return fmt.Errorf("stack %s: %w", name, err)
+			}
+		}
+		if err := h.DeployStack(ctx, name); err != nil {
+			return fmt.Errorf("stack %s: %w", name, err)
+		}
+	}
+	// the imported distribution is allowed to read the bucket
+	return h.updateBucketAccess(ctx, false)
+}
+
+// validateImports checks that the live resources can be adopted by the templates
+// The bucket must be in the region of the site and the distribution must serve the record of the site;
+// the settings of the distribution are compared with the template before its import, see compareDistribution
+// return: error - the error if any
+func (h *Haws) validateImports(ctx context.Context) error {
+	if bucket, ok := h.imports["bucket"]["bucket"]; ok {
+		if err := h.validateBucket(ctx, bucket); err != nil {
+			return err
+		}
+	}
+	if id, ok := h.imports["cloudfront"]["distribution"]; ok {
+		record := h.stacks["cloudfront"].ParameterValues()["RecordName"]
+		if err := h.validateDistribution(ctx, id, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBucket checks that the bucket exists in the region of the site
+// param: bucket - the name of the bucket
+// return: error - the error if any
+func (h *Haws) validateBucket(ctx context.Context, bucket string) error {
+	if h.bucketLocationClient == nil {
+		client, err := h.clientProvider().S3(ctx, h.region)
+		if err != nil {
+			return err
+		}
+		h.bucketLocationClient = client
+	}
+
+	resp, err := h.bucketLocationClient.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: &bucket})
+	if err != nil {
+		return fmt.Errorf("unable to find bucket %s: %w", bucket, err)
+	}
+	// buckets in us-east-1 have no location constraint
+	location := string(resp.LocationConstraint)
+	if location == "" {
+		location = "us-east-1"
+	}
+	if location != h.region {
+		return fmt.Errorf("bucket %s is in %s, not in the region of the site %s", bucket, location, h.region)
+	}
+	return nil
+}
+
+// validateDistribution checks that the distribution exists and serves the record of the site
+// param: id - the id of the distribution
+// param: record - the record name of the site
+// return: error - the error if any
+func (h *Haws) validateDistribution(ctx context.Context, id string, record string) error {
+	if err := h.ensureCloudFront(ctx); err != nil {
+		return err
+	}
+
+	resp, err := h.cloudFrontClient.GetDistribution(ctx, &cloudfront.GetDistributionInput{Id: &id})
+	if err != nil {
+		return fmt.Errorf("unable to find distribution %s: %w", id, err)
+	}
+
+	aliases := make([]string, 0)
+	if resp.Distribution.DistributionConfig != nil && resp.Distribution.DistributionConfig.Aliases != nil {
+		aliases = resp.Distribution.DistributionConfig.Aliases.Items
+	}
+	if !slices.Contains(aliases, record) {
+		return fmt.Errorf("distribution %s does not serve %s (aliases: %s)", id, record, strings.Join(aliases, ", "))
+	}
+	if status := aws.ToString(resp.Distribution.Status); status != "Deployed" {
+		logger.Warn("Distribution %s is %s: the import may fail until it is deployed", id, status)
+	}
+	return nil
+}
+
+// templateDistribution returns the settings the cloudfront template gives to the distribution
+// The bucket and certificate stacks must be deployed and the parameters of the cloudfront stack resolved
+// return: components.DistributionSettings - the settings of the template
+// return: error - the error if any
+func (h *Haws) templateDistribution(ctx context.Context) (components.DistributionSettings, error) {
+	want := h.cdn.ImportSettings()
+	want.CertificateArn = h.stacks["cloudfront"].ParameterValues()["CertificateArn"]
+	domain, err := h.stacks["bucket"].Output(ctx, "Domain")
+	if err != nil {
+		return want, err
+	}
+	want.OriginDomain = domain
+	return want, nil
+}
+
+// compareDistribution checks that the distribution has the settings of the template
+// param: id - the id of the distribution
+// param: want - the settings of the template
+// return: error - the differences if any
+func (h *Haws) compareDistribution(ctx context.Context, id string, want components.DistributionSettings) error {
+	if err := h.ensureCloudFront(ctx); err != nil {
+		return err
+	}
+
+	resp, err := h.cloudFrontClient.GetDistribution(ctx, &cloudfront.GetDistributionInput{Id: &id})
+	if err != nil {
+		return fmt.Errorf("unable to find distribution %s: %w", id, err)
+	}
+
+	live := components.DistributionSettings{}
+	if config := resp.Distribution.DistributionConfig; config != nil {
+		if config.Origins != nil && len(config.Origins.Items) > 0 {
+			live.OriginDomain = aws.ToString(config.Origins.Items[0].DomainName)
+			live.OriginPath = aws.ToString(config.Origins.Items[0].OriginPath)
+		}
+		if config.ViewerCertificate != nil {
+			live.CertificateArn = aws.ToString(config.ViewerCertificate.ACMCertificateArn)
+			live.MinimumProtocolVersion = string(config.ViewerCertificate.MinimumProtocolVersion)
+			live.SslSupportMethod = string(config.ViewerCertificate.SSLSupportMethod)
+		}
+		if config.DefaultCacheBehavior != nil {
+			live.ViewerProtocolPolicy = string(config.DefaultCacheBehavior.ViewerProtocolPolicy)
+		}
+	}
+
+	// the root of the bucket is "/" in the template and "" in CloudFront
+	diff := make([]string, 0)
+	for _, setting := range []struct{ name, live, template string }{
+		{"origin domain", live.OriginDomain, want.OriginDomain},
+		{"origin path", strings.TrimSuffix(live.OriginPath, "/"), strings.TrimSuffix(want.OriginPath, "/")},
+		{"certificate", live.CertificateArn, want.CertificateArn},
+		{"viewer protocol policy", live.ViewerProtocolPolicy, want.ViewerProtocolPolicy},
+		{"minimum protocol version", live.MinimumProtocolVersion, want.MinimumProtocolVersion},
+		{"SSL support method", live.SslSupportMethod, want.SslSupportMethod},
+	} {
+		if setting.live != setting.template {
+			diff = append(diff, fmt.Sprintf("  %s: live %q, template %q", setting.name, setting.live, setting.template))
+		}
+	}
+	if len(diff) > 0 {
+		return fmt.Errorf("distribution %s differs from the template, align it before the import:\n%s", id, strings.Join(diff, "\n"))
+	}
+	return nil
+}
+
+// ensureCloudFront creates the CloudFront client if needed
+// return: error - the error if any
+func (h *Haws) ensureCloudFront(ctx context.Context) error {
+	if h.cloudFrontClient != nil {
+		return nil
+	}
+	client, err := h.clientProvider().CloudFront(ctx)
+	if err != nil {
+		return err
+	}
+	h.cloudFrontClient = client
+	return nil
+}
@@ -0,0 +1,146 @@
+package haws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/dragosboca/haws/pkg/components"
+	"github.com/dragosboca/haws/pkg/stack"
+)
+
+type mockBucketLocation struct {
+	location s3types.BucketLocationConstraint
+}
+
+func (m *mockBucketLocation) GetBucketLocation(ctx context.Context, params *s3.GetBucketLocationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	return &s3.GetBucketLocationOutput{LocationConstraint: m.location}, nil
+}
+
+type mockCloudFront struct {
+	aliases  []string
+	settings components.DistributionSettings
+}
+
+func (m *mockCloudFront) GetDistribution(ctx context.Context, params *cloudfront.GetDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetDistributionOutput, error) {
+	return &cloudfront.GetDistributionOutput{
+		Distribution: &cftypes.Distribution{
+			Status: aws.String("Deployed"),
+			DistributionConfig: &cftypes.DistributionConfig{
+				Aliases: &cftypes.Aliases{Items: m.aliases, Quantity: aws.Int32(int32(len(m.aliases)))},
+				Origins: &cftypes.Origins{Items: []cftypes.Origin{{
+					DomainName: aws.String(m.settings.OriginDomain),
+					OriginPath: aws.String(m.settings.OriginPath),
+				}}},
+				ViewerCertificate: &cftypes.ViewerCertificate{
+					ACMCertificateArn:      aws.String(m.settings.CertificateArn),
+					MinimumProtocolVersion: cftypes.MinimumProtocolVersion(m.settings.MinimumProtocolVersion),
+					SSLSupportMethod:       cftypes.SSLSupportMethod(m.settings.SslSupportMethod),
+				},
+				DefaultCacheBehavior: &cftypes.DefaultCacheBehavior{
+					ViewerProtocolPolicy: cftypes.ViewerProtocolPolicy(m.settings.ViewerProtocolPolicy),
+				},
+			},
+		},
+	}, nil
+}
+
+func newImportSite(t *testing.T) *Haws {
+	t.Helper()
+	cdn, err := components.NewCdn(&components.CdnInput{Prefix: "test", Path: "/", Region: "eu-central-1", Domain: "example.com", Record: "www"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Haws{
+		region: "eu-central-1",
+		cdn:    cdn,
+		stacks: map[string]*stack.Stack{
+			"bucket":     stack.NewStack(components.NewBucket(&components.BucketInput{Prefix: "test", Region: "eu-central-1", Domain: "example.com"})),
+			"cloudfront": stack.NewStack(cdn),
+		},
+	}
+}
+
+func TestSetImports(t *testing.T) {
+	h := newImportSite(t)
+	if err := h.SetImports(map[string]string{"bucket": "old-bucket", "distribution": "E123"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if h.imports["bucket"]["bucket"] != "old-bucket" || h.imports["cloudfront"]["distribution"] != "E123" {
+		t.Errorf("Unexpected imports %v", h.imports)
+	}
+	if name := h.stacks["bucket"].ParameterValues()["BucketName"]; name != "old-bucket" {
+		t.Errorf("Expected the bucket stack to use the imported bucket, got %s", name)
+	}
+
+	err := h.SetImports(map[string]string{"recordset": "www.example.com"})
+	if err == nil || !strings.Contains(err.Error(), "cannot be imported") {
+		t.Errorf("Expected 'cannot be imported' error, got %v", err)
+	}
+}
+
+func TestValidateImports(t *testing.T) {
+	h := newImportSite(t)
+	if err := h.SetImports(map[string]string{"bucket": "old-bucket", "distribution": "E123"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	h.bucketLocationClient = &mockBucketLocation{location: "eu-central-1"}
+	h.cloudFrontClient = &mockCloudFront{aliases: []string{"www.example.com"}}
+
+	if err := h.validateImports(context.Background()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	h.bucketLocationClient = &mockBucketLocation{}
+	err := h.validateImports(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "is in us-east-1") {
+		t.Errorf("Expected a region mismatch, got %v", err)
+	}
+
+	h.bucketLocationClient = &mockBucketLocation{location: "eu-central-1"}
+	h.cloudFrontClient = &mockCloudFront{aliases: []string{"blog.example.com"}}
+	err = h.validateImports(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "does not serve www.example.com") {
+		t.Errorf("Expected an alias mismatch, got %v", err)
+	}
+}
+
+func TestCompareDistribution(t *testing.T) {
+	h := newImportSite(t)
+	want := h.cdn.ImportSettings()
+	want.OriginDomain = "old-bucket.s3.amazonaws.com"
+	want.CertificateArn = "arn:aws:acm:us-east-1:123456789012:certificate/new"
+
+	live := want
+	live.OriginPath = ""
+	h.cloudFrontClient = &mockCloudFront{settings: live}
+	if err := h.compareDistribution(context.Background(), "E123", want); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	live.OriginPath = "/public"
+	live.CertificateArn = "arn:aws:acm:us-east-1:123456789012:certificate/old"
+	live.ViewerProtocolPolicy = "allow-all"
+	h.cloudFrontClient = &mockCloudFront{settings: live}
+	err := h.compareDistribution(context.Background(), "E123", want)
+	if err == nil {
+		t.Fatal("Expected the differences, got no error")
+	}
+	for _, diff := range []string{
+		`origin path: live "/public", template ""`,
+		`certificate: live "arn:aws:acm:us-east-1:123456789012:certificate/old"`,
+		`viewer protocol policy: live "allow-all", template "redirect-to-https"`,
+	} {
+		if !strings.Contains(err.Error(), diff) {
+			t.Errorf("Expected %s in %v", diff, err)
+		}
+	}
+	if strings.Contains(err.Error(), "origin domain") {
+		t.Errorf("Expected the origin domain to match, got %v", err)
+	}
+}
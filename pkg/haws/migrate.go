@@ -0,0 +1,157 @@
+package haws
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	"github.com/dragosboca/haws/pkg/components"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// keepLegacyOAI keeps the Origin Access Identity of the bucket while a distribution still uses it
+// The sites deployed before the Origin Access Control import the identity from the bucket stack: removing it
+// before their distribution is signed with the Origin Access Control would fail and break the sites
+// return: bool - true if the identity is kept
+// return: error - the error if any
+func (h *Haws) keepLegacyOAI(ctx context.Context) (bool, error) {
+	if h.dryRun || h.bucket == nil {
+		return false, nil
+	}
+
+	importers, err := h.stacks["bucket"].ImportersOf(ctx, h.bucket.GetExportName("Oai"))
+	if err != nil {
+		return false, err
+	}
+	keep := len(importers) > 0
+	h.bucket.KeepOAI(keep)
+	if keep {
+		logger.Info("Keeping the origin access identity of the bucket, used by %s", strings.Join(importers, ", "))
+	}
+	return keep, nil
+}
+
+// setBucketReaders allows the distributions of the sites sharing the bucket to read it
+// They are the distributions of the stacks importing the origin access control of the bucket, none before the
+// distributions exist; in dry-run mode the distribution of this site is the only one (with its dry-run ARN)
+// return: bool - true if the distributions changed
+// return: error - the error if any
+func (h *Haws) setBucketReaders(ctx context.Context) (bool, error) {
+	if h.bucket == nil || h.accountDistributions {
+		return false, nil
+	}
+
+	readers := make([]string, 0)
+	if h.dryRun {
+		readers = append(readers, h.stacks["cloudfront"].GetDryRunOutputs()["CloudFrontArn"])
+	} else {
+		importers, err := h.stacks["bucket"].ImportersOf(ctx, h.bucket.GetExportName("Oac"))
+		if err != nil {
+			return false, err
+		}
+		for _, importer := range importers {
+			arn, err := h.stacks["bucket"].OutputOf(ctx, importer, "CloudFrontArn")
+			if err != nil {
+				return false, err
+			}
+			if arn == "" {
+				logger.Debug("Stack %s imports the origin access control but has no distribution", importer)
+				continue
+			}
+			readers = append(readers, arn)
+		}
+		sort.Strings(readers)
+	}
+
+	if slices.Equal(readers, h.bucket.Distributions()) {
+		return false, nil
+	}
+	return true, h.bucket.SetDistributions(readers)
+}
+
+// updateBucketAccess deploys the bucket stack again once the distributions are deployed: the distributions
+// created since the bucket was deployed are allowed to read it, and the Origin Access Identity kept by
+// keepLegacyOAI is removed once no distribution uses it
+// param: legacy - true if the identity was kept
+// return: error - the error if any
+func (h *Haws) updateBucketAccess(ctx context.Context, legacy bool) error {
+	redeploy := false
+	if legacy {
+		keep, err := h.keepLegacyOAI(ctx)
+		if err != nil {
+			return err
+		}
+		if !keep {
+			logger.Info("The distributions use the origin access control, removing the origin access identity of the bucket")
+			redeploy = true
+		}
+	}
+
+	changed, err := h.setBucketReaders(ctx)
+	if err != nil {
+		return err
+	}
+	if changed {
+		logger.Info("Allowing the distributions %s to read the bucket", strings.Join(h.bucket.Distributions(), ", "))
+		redeploy = true
+	}
+	if !redeploy {
+		return nil
+	}
+	return h.DeployStack(ctx, "bucket")
+}
+
+// removeLegacyCertificate deletes the <prefix>-certificate stack of the sites deployed before each site had its own certificate
+// The stack is kept while a distribution still uses its certificate, and when it holds the wildcard certificate of the prefix
+// return: error - the error if any
+func (h *Haws) removeLegacyCertificate(ctx context.Context) error {
+	if h.dryRun || h.certificate == nil {
+		return nil
+	}
+	legacy, err := components.NewCertificate(&components.CertificateInput{
+		Prefix:   h.certificate.Prefix,
+		Region:   globalRegion,
+		Domain:   h.certificate.Domain,
+		Wildcard: true,
+	})
+	if err != nil {
+		return err
+	}
+	stackName := *legacy.GetStackName()
+	if stackName == *h.certificate.GetStackName() {
+		// the site uses the wildcard certificate of the prefix
+		return nil
+	}
+
+	st := h.stacks["certificate"].Sibling(legacy)
+	exists, err := st.Exists(ctx)
+	if err != nil || !exists {
+		return err
+	}
+	certificateArn, err := st.PhysicalResourceId(ctx, "HugoSslCertificate")
+	if err != nil {
+		return err
+	}
+	if err := h.ensureACM(ctx); err != nil {
+		return err
+	}
+	resp, err := h.acmClient.DescribeCertificate(ctx, &acm.DescribeCertificateInput{
+		CertificateArn: &certificateArn,
+	})
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(resp.Certificate.SubjectAlternativeNames, "*."+h.certificate.Domain) {
+		logger.Debug("Stack %s holds the wildcard certificate of the sites of the prefix, keeping it", stackName)
+		return nil
+	}
+	if users := resp.Certificate.InUseBy; len(users) > 0 {
+		logger.Warn("Keeping the legacy certificate stack %s: it is still used by %s", stackName, strings.Join(users, ", "))
+		return nil
+	}
+	logger.Info("No distribution uses the certificate of the legacy stack %s anymore, deleting it", stackName)
+	return st.Delete(ctx)
+}
@@ -0,0 +1,168 @@
+package haws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+const (
+	// OutputsJSON renders the outputs as a JSON object
+	OutputsJSON = "json"
+
+	// OutputsEnv renders the outputs as shell export statements
+	OutputsEnv = "env"
+
+	// OutputsDotenv renders the outputs as a .env file
+	OutputsDotenv = "dotenv"
+
+	// OutputsGithub appends the outputs to the $GITHUB_OUTPUT file of a GitHub Actions step
+	OutputsGithub = "github"
+)
+
+// outputKey maps an output of a stack to its variable name
+type outputKey struct {
+	Stack  string
+	Output string
+	Key    string
+}
+
+// outputKeys are the documented variable names of the outputs
+var outputKeys = []outputKey{
+	{"bucket", "Name", "HAWS_BUCKET_NAME"},
+	{"bucket", "Arn", "HAWS_BUCKET_ARN"},
+	{"bucket", "Domain", "HAWS_BUCKET_DOMAIN"},
+	{"bucket", "OAC", "HAWS_BUCKET_OAC"},
+	{"certificate", "Arn", "HAWS_CERTIFICATE_ARN"},
+	{"cloudfront", "CloudFrontId", "HAWS_DISTRIBUTION_ID"},
+	{"cloudfront", "CloudFrontArn", "HAWS_DISTRIBUTION_ARN"},
+	{"user", "AccessKey", "HAWS_ACCESS_KEY_ID"},
+	{"user", "SecretKey", "HAWS_SECRET_ACCESS_KEY"},
+}
+
+// CollectOutputs gathers the outputs of the deployed stacks under their documented variable names
+// The stacks that do not exist are skipped
+// param: includeSecrets - include the secret outputs (the secret access key of the user)
+// return: map[string]string - the values indexed by variable name, with HAWS_REGION
+// return: error - the error if any
+func (h *Haws) CollectOutputs(ctx context.Context, includeSecrets bool) (map[string]string, error) {
+	values := map[string]string{"HAWS_REGION": h.region}
+
+	deployed := make(map[string]bool)
+	for name, st := range h.stacks {
+		exists, err := st.Exists(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			logger.Warn("Stack %s does not exist, skipping its outputs", *st.GetStackName())
+			continue
+		}
+		deployed[name] = true
+	}
+
+	for _, key := range outputKeys {
+		st, ok := h.stacks[key.Stack]
+		if !ok || !deployed[key.Stack] {
+			continue
+		}
+		if st.IsSecret(key.Output) && !includeSecrets {
+			continue
+		}
+		value, err := st.Output(ctx, key.Output)
+		if err != nil {
+			return nil, err
+		}
+		values[key.Key] = value
+	}
+	return values, nil
+}
+
+// FormatOutputs renders the outputs, sorted by variable name
+// param: values - the values indexed by variable name
+// param: format - the format (json, env, dotenv or github)
+// return: string - the rendered outputs
+// return: error - the error if the format is not supported
+func FormatOutputs(values map[string]string, format string) (string, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var out strings.Builder
+	switch format {
+	case OutputsJSON:
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		out.Write(data)
+		out.WriteString("\n")
+	case OutputsEnv:
+		for _, key := range keys {
+			fmt.Fprintf(&out, "export %s=%s\n", key, shellQuote(values[key]))
+		}
+	case OutputsDotenv:
+		for _, key := range keys {
+			fmt.Fprintf(&out, "%s=%s\n", key, dotenvQuote(values[key]))
+		}
+	case OutputsGithub:
+		for _, key := range keys {
+			if strings.Contains(values[key], "\n") {
+				fmt.Fprintf(&out, "%s<<HAWS_EOF\n%s\nHAWS_EOF\n", key, values[key])
+			} else {
+				fmt.Fprintf(&out, "%s=%s\n", key, values[key])
+			}
+		}
+	default:
+		return "", fmt.Errorf("unsupported format %s (json, env, dotenv or github)", format)
+	}
+	return out.String(), nil
+}
+
+// WriteGithubOutputs appends the outputs to the file named by $GITHUB_OUTPUT
+// param: values - the values indexed by variable name
+// return: error - the error if any
+func WriteGithubOutputs(values map[string]string) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		return fmt.Errorf("GITHUB_OUTPUT is not set: the github format only works in a GitHub Actions step")
+	}
+
+	rendered, err := FormatOutputs(values, OutputsGithub)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(rendered)
+	return err
+}
+
+// shellQuote quotes a value for a POSIX shell
+// param: value - the value
+// return: string - the value in single quotes
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// dotenvQuote quotes a value for a .env file when it contains special characters
+// param: value - the value
+// return: string - the value, in double quotes if needed
+func dotenvQuote(value string) string {
+	if !strings.ContainsAny(value, " \t\n\"'#$\\=") {
+		return value
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, `$`, `\$`)
+	return `"` + replacer.Replace(value) + `"`
+}
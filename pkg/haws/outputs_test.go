@@ -0,0 +1,53 @@
+package haws
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatOutputs(t *testing.T) {
+	values := map[string]string{
+		"HAWS_REGION":      "eu-central-1",
+		"HAWS_BUCKET_NAME": "it's a bucket",
+	}
+
+	tests := map[string]string{
+		OutputsJSON:   "{\n  \"HAWS_BUCKET_NAME\": \"it's a bucket\",\n  \"HAWS_REGION\": \"eu-central-1\"\n}\n",
+		OutputsEnv:    "export HAWS_BUCKET_NAME='it'\\''s a bucket'\nexport HAWS_REGION='eu-central-1'\n",
+		OutputsDotenv: "HAWS_BUCKET_NAME=\"it's a bucket\"\nHAWS_REGION=eu-central-1\n",
+		OutputsGithub: "HAWS_BUCKET_NAME=it's a bucket\nHAWS_REGION=eu-central-1\n",
+	}
+	for format, expected := range tests {
+		actual, err := FormatOutputs(values, format)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", format, err)
+		}
+		if actual != expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", format, expected, actual)
+		}
+	}
+
+	if _, err := FormatOutputs(values, "xml"); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+func TestWriteGithubOutputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output")
+	if err := os.WriteFile(path, []byte("previous=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_OUTPUT", path)
+
+	if err := WriteGithubOutputs(map[string]string{"HAWS_REGION": "eu-central-1"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "previous=1\nHAWS_REGION=eu-central-1\n" {
+		t.Errorf("Expected the outputs to be appended, got %q", string(data))
+	}
+}
@@ -0,0 +1,187 @@
+package haws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dragosboca/haws/pkg/logger"
+	"github.com/dragosboca/haws/pkg/stack"
+)
+
+// planVersion is the version of the plan file format
+const planVersion = 1
+
+// Plan records the change sets created for the stacks of a site
+type Plan struct {
+	Version int                         `json:"version"`
+	Created time.Time                   `json:"created"`
+	Stacks  map[string]*stack.StackPlan `json:"stacks"`
+}
+
+// Plan creates the change sets of all the stacks of the site without executing them
+// The stacks are planned one by one, in dependency order. A stack depending on a stack that does not exist yet,
+// or whose change set replaces a resource behind an output it references, is deferred: its parameters are only
+// known once that stack is applied, so it is planned again after the apply
+// return: *Plan - the plan
+// return: error - the error if any
+func (h *Haws) Plan(ctx context.Context) (*Plan, error) {
+	order, err := h.order()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Version: planVersion,
+		Created: time.Now().UTC(),
+		Stacks:  make(map[string]*stack.StackPlan),
+	}
+	if _, err := h.keepLegacyOAI(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := h.setBucketReaders(ctx); err != nil {
+		return nil, err
+	}
+	for _, name := range flatten(order) {
+		st := h.stacks[name]
+		reason, err := h.deferReason(name, plan)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			logger.Warn("Stack %s is not planned, %s: plan again after the apply", *st.GetStackName(), reason)
+			plan.Stacks[name] = &stack.StackPlan{
+				Stack:    *st.GetStackName(),
+				Region:   st.GetRegion(),
+				Changes:  make([]string, 0),
+				Deferred: reason,
+			}
+			continue
+		}
+
+		if err := h.resolveParameters(ctx, name); err != nil {
+			return nil, err
+		}
+		stackPlan, err := st.Plan(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("stack %s: %w", name, err)
+		}
+		plan.Stacks[name] = stackPlan
+	}
+	return plan, nil
+}
+
+// deferReason tells why a stack cannot be planned before the stacks it depends on are applied
+// param: name - the name of the stack
+// param: plan - the plans of the stacks it depends on
+// return: string - the reason, empty if the stack can be planned
+// return: error - the error if the outputs of a dependency cannot be read from its template
+func (h *Haws) deferReason(name string, plan *Plan) (string, error) {
+	st := h.stacks[name]
+	for _, dep := range st.GetDependencies() {
+		depPlan := plan.Stacks[dep]
+		if depPlan.Deferred != "" {
+			return fmt.Sprintf("stack %s is not planned", depPlan.Stack), nil
+		}
+		if depPlan.ChangeSetType == "CREATE" {
+			return fmt.Sprintf("stack %s does not exist yet", depPlan.Stack), nil
+		}
+	}
+
+	for _, ref := range st.GetReferences() {
+		depPlan := plan.Stacks[ref.Stack]
+		if len(depPlan.Replaced) == 0 {
+			continue
+		}
+		resources, err := h.stacks[ref.Stack].OutputResources(ref.Output)
+		if err != nil {
+			return "", err
+		}
+		for _, resource := range resources {
+			if slices.Contains(depPlan.Replaced, resource) {
+				return fmt.Sprintf("output %s of stack %s changes with resource %s", ref.Output, depPlan.Stack, resource), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Apply executes the change sets of a plan, running the stacks that do not depend on each other concurrently
+// The bucket template is built from the deployed stacks as in Plan, so it matches the planned one
+// The deferred stacks are not applied: once the others are, the apply fails asking to plan them
+// param: plan - the plan
+// return: error - the first error if any
+func (h *Haws) Apply(ctx context.Context, plan *Plan) error {
+	if plan.Version != planVersion {
+		return fmt.Errorf("unsupported plan version %d", plan.Version)
+	}
+	for name := range h.stacks {
+		if _, ok := plan.Stacks[name]; !ok {
+			return fmt.Errorf("the plan does not contain stack %s", name)
+		}
+	}
+
+	order, err := h.order()
+	if err != nil {
+		return err
+	}
+	if _, err := h.keepLegacyOAI(ctx); err != nil {
+		return err
+	}
+	if _, err := h.setBucketReaders(ctx); err != nil {
+		return err
+	}
+	err = runWaves(ctx, order, func(ctx context.Context, name string) error {
+		if plan.Stacks[name].Deferred != "" {
+			return nil
+		}
+		return h.stacks[name].Apply(ctx, plan.Stacks[name])
+	})
+	if err != nil {
+		return err
+	}
+
+	deferred := make([]string, 0)
+	for _, name := range flatten(order) {
+		if plan.Stacks[name].Deferred != "" {
+			deferred = append(deferred, plan.Stacks[name].Stack)
+		}
+	}
+	if len(deferred) > 0 {
+		return fmt.Errorf("stacks %s were not planned: plan again and apply", strings.Join(deferred, ", "))
+	}
+	return nil
+}
+
+// WritePlan saves a plan to a file
+// param: path - the path of the file
+// param: plan - the plan
+// return: error - the error if any
+func WritePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadPlan loads a plan from a file
+// param: path - the path of the file
+// return: *Plan - the plan
+// return: error - the error if any
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, fmt.Errorf("invalid plan file %s: %w", path, err)
+	}
+	return plan, nil
+}
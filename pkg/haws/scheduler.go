@@ -0,0 +1,134 @@
+package haws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// waves groups the stacks in waves that can run concurrently
+// Every stack is placed in the first wave after all the stacks it depends on
+// param: dependencies - the dependencies of each stack, indexed by stack name
+// return: [][]string - the waves in execution order, each one sorted by name
+// return: error - the error if a dependency is unknown or the dependencies form a cycle
+func waves(dependencies map[string][]string) ([][]string, error) {
+	pending := make(map[string]int)
+	dependents := make(map[string][]string)
+	for name, deps := range dependencies {
+		pending[name] = len(deps)
+		for _, dep := range deps {
+			if _, ok := dependencies[dep]; !ok {
+				return nil, fmt.Errorf("stack %s depends on unknown stack %s", name, dep)
+			}
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	result := make([][]string, 0)
+	done := 0
+	for done < len(dependencies) {
+		wave := make([]string, 0)
+		for name, count := range pending {
+			if count == 0 {
+				wave = append(wave, name)
+			}
+		}
+		if len(wave) == 0 {
+			cycle := make([]string, 0, len(pending))
+			for name := range pending {
+				cycle = append(cycle, name)
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("dependency cycle between stacks: %s", strings.Join(cycle, ", "))
+		}
+		sort.Strings(wave)
+
+		for _, name := range wave {
+			delete(pending, name)
+			for _, dependent := range dependents[name] {
+				pending[dependent]--
+			}
+		}
+		done += len(wave)
+		result = append(result, wave)
+	}
+	return result, nil
+}
+
+// runWaves runs the stacks of each wave concurrently, one wave after another
+// The first error cancels the context of the stacks still running and no other wave is started
+// param: waves - the waves returned by waves
+// param: run - the function called for each stack
+// return: error - the first error if any
+func runWaves(ctx context.Context, waves [][]string, run func(ctx context.Context, name string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, wave := range waves {
+		if len(wave) > 1 {
+			logger.Info("Running stacks together: %s", strings.Join(wave, ", "))
+		}
+
+		var (
+			wg       sync.WaitGroup
+			once     sync.Once
+			firstErr error
+		)
+		for _, name := range wave {
+			wg.Add(1)
+			go func(name string) {
+				defer wg.Done()
+				if err := run(ctx, name); err != nil {
+					once.Do(func() {
+						firstErr = fmt.Errorf("stack %s: %w", name, err)
+						cancel()
+					})
+				}
+			}(name)
+		}
+		wg.Wait()
+
+		if firstErr != nil {
+			return firstErr
+		}
+	}
+	return nil
+}
+
+// order returns the deployment waves of the stacks of the site
+// return: [][]string - the waves in execution order
+// return: error - the error if the dependencies are invalid
+func (h *Haws) order() ([][]string, error) {
+	dependencies := make(map[string][]string)
+	for name, st := range h.stacks {
+		dependencies[name] = st.GetDependencies()
+	}
+	return waves(dependencies)
+}
+
+// flatten returns the stacks of the waves in execution order
+// param: waves - the waves
+// return: []string - the names of the stacks
+func flatten(waves [][]string) []string {
+	names := make([]string, 0)
+	for _, wave := range waves {
+		names = append(names, wave...)
+	}
+	return names
+}
+
+// teardown returns the stacks of the waves in deletion order: the last wave first,
+// the stacks of a wave in their order (user, cloudfront, bucket, certificate for a site)
+// param: waves - the waves
+// return: []string - the names of the stacks
+func teardown(waves [][]string) []string {
+	names := make([]string, 0)
+	for i := len(waves) - 1; i >= 0; i-- {
+		names = append(names, waves[i]...)
+	}
+	return names
+}
@@ -0,0 +1,119 @@
+package haws
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWaves(t *testing.T) {
+	order, err := waves(map[string][]string{
+		"certificate": nil,
+		"bucket":      nil,
+		"cloudfront":  {"certificate", "bucket"},
+		"user":        {"bucket", "cloudfront"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := [][]string{{"bucket", "certificate"}, {"cloudfront"}, {"user"}}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected %v, got %v", expected, order)
+	}
+}
+
+func TestTeardown(t *testing.T) {
+	names := teardown([][]string{{"bucket", "certificate"}, {"cloudfront"}, {"user"}})
+	expected := []string{"user", "cloudfront", "bucket", "certificate"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+}
+
+func TestWaves_Errors(t *testing.T) {
+	testCases := []struct {
+		name         string
+		dependencies map[string][]string
+		expected     string
+	}{
+		{"cycle", map[string][]string{"a": {"b"}, "b": {"a"}, "c": nil}, "dependency cycle between stacks: a, b"},
+		{"unknown", map[string][]string{"a": {"b"}}, "stack a depends on unknown stack b"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := waves(tc.dependencies)
+			if err == nil || err.Error() != tc.expected {
+				t.Errorf("Expected error %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestRunWaves_Concurrent(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		running int
+		maximum int
+	)
+	started := make(chan struct{}, 2)
+
+	err := runWaves(context.Background(), [][]string{{"bucket", "certificate"}}, func(ctx context.Context, name string) error {
+		mu.Lock()
+		running++
+		if running > maximum {
+			maximum = running
+		}
+		mu.Unlock()
+
+		// both stacks of the wave must be running at the same time
+		started <- struct{}{}
+		for len(started) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if maximum != 2 {
+		t.Errorf("Expected 2 stacks to run together, got %d", maximum)
+	}
+}
+
+func TestRunWaves_FirstErrorCancels(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		ran []string
+	)
+
+	err := runWaves(context.Background(), [][]string{{"bucket", "certificate"}, {"cloudfront"}}, func(ctx context.Context, name string) error {
+		mu.Lock()
+		ran = append(ran, name)
+		mu.Unlock()
+
+		if name == "bucket" {
+			return fmt.Errorf("boom")
+		}
+		// the certificate waits until the failure of the bucket cancels it
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err == nil || !strings.Contains(err.Error(), "stack bucket: boom") {
+		t.Errorf("Expected the bucket error, got %v", err)
+	}
+	for _, name := range ran {
+		if name == "cloudfront" {
+			t.Error("The next wave should not run after an error")
+		}
+	}
+}
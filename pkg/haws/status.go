@@ -0,0 +1,176 @@
+package haws
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	acmtypes "github.com/aws/aws-sdk-go-v2/service/acm/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/dragosboca/haws/pkg/stack"
+)
+
+// SiteStatus is the deployed state of the stacks of a site
+type SiteStatus struct {
+	Stacks []*stack.StackStatus
+
+	// CertificateStatus is the validation status of the certificate (empty if it does not exist)
+	CertificateStatus string
+	// CertificateExpiry is the end of validity of the certificate
+	CertificateExpiry time.Time
+
+	// DistributionId is the id of the distribution (empty if it does not exist)
+	DistributionId string
+	// DistributionStatus is the deployment status of the distribution (InProgress or Deployed)
+	DistributionStatus string
+}
+
+// Stable returns true if no operation is running on the stacks, the certificate or the distribution
+// return: bool - true if the site is stable
+func (s *SiteStatus) Stable() bool {
+	for _, st := range s.Stacks {
+		if !st.Stable() {
+			return false
+		}
+	}
+	if s.CertificateStatus == string(acmtypes.CertificateStatusPendingValidation) {
+		return false
+	}
+	return s.DistributionId == "" || s.DistributionStatus == "Deployed"
+}
+
+// Print writes the status of the site as a table, followed by the outputs of every stack
+// param: w - the writer
+func (s *SiteStatus) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STACK\tREGION\tSTATUS\tLAST UPDATED\tDRIFT\tPROTECTED")
+	for _, st := range s.Stacks {
+		updated := "-"
+		if !st.LastUpdated.IsZero() {
+			updated = st.LastUpdated.UTC().Format(time.RFC3339)
+		}
+		drift := st.DriftStatus
+		if drift == "" {
+			drift = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\n", st.Name, st.Region, st.Status, updated, drift, st.TerminationProtection)
+	}
+	tw.Flush()
+
+	if s.CertificateStatus != "" {
+		fmt.Fprintf(w, "\nCertificate: %s, expires %s\n", s.CertificateStatus, expiry(s.CertificateExpiry))
+	}
+	if s.DistributionId != "" {
+		fmt.Fprintf(w, "Distribution %s: %s\n", s.DistributionId, s.DistributionStatus)
+	}
+
+	for _, st := range s.Stacks {
+		if len(st.Outputs) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s outputs:\n", st.Name)
+		names := make([]string, 0, len(st.Outputs))
+		for name := range st.Outputs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, name := range names {
+			fmt.Fprintf(tw, "  %s\t%s\n", name, st.Outputs[name])
+		}
+		tw.Flush()
+	}
+}
+
+// expiry formats the end of validity of the certificate
+// param: notAfter - the end of validity
+// return: string - the date, or "-" if unknown
+func expiry(notAfter time.Time) string {
+	if notAfter.IsZero() {
+		return "-"
+	}
+	return notAfter.UTC().Format("2006-01-02")
+}
+
+// Status reads the deployed state of the stacks of the site, in dependency order,
+// the validation status of the certificate and the deployment status of the distribution
+// return: *SiteStatus - the state of the site
+// return: error - the error if any
+func (h *Haws) Status(ctx context.Context) (*SiteStatus, error) {
+	order, err := h.order()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &SiteStatus{Stacks: make([]*stack.StackStatus, 0, len(h.stacks))}
+	for _, name := range flatten(order) {
+		st, err := h.stacks[name].Status(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("stack %s: %w", name, err)
+		}
+		status.Stacks = append(status.Stacks, st)
+
+		if st.Status == stack.NotCreated || strings.HasPrefix(st.Status, "DELETE") {
+			continue
+		}
+		switch name {
+		case "certificate":
+			// the certificate may not be requested yet while the stack is being created
+			if err := h.certificateStatus(ctx, status); err != nil && st.Stable() {
+				return nil, err
+			}
+		case "cloudfront":
+			if id, ok := st.Outputs["CloudFrontId"]; ok {
+				if err := h.distributionStatus(ctx, id, status); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return status, nil
+}
+
+// certificateStatus reads the validation status and the expiry of the certificate
+// param: status - the status of the site
+// return: error - the error if any
+func (h *Haws) certificateStatus(ctx context.Context, status *SiteStatus) error {
+	arn, err := h.stacks["certificate"].PhysicalResourceId(ctx, "HugoSslCertificate")
+	if err != nil {
+		return err
+	}
+	if err := h.ensureACM(ctx); err != nil {
+		return err
+	}
+
+	resp, err := h.acmClient.DescribeCertificate(ctx, &acm.DescribeCertificateInput{CertificateArn: &arn})
+	if err != nil {
+		return fmt.Errorf("unable to describe certificate %s: %w", arn, err)
+	}
+	status.CertificateStatus = string(resp.Certificate.Status)
+	status.CertificateExpiry = aws.ToTime(resp.Certificate.NotAfter)
+	return nil
+}
+
+// distributionStatus reads the deployment status of the distribution
+// param: id - the id of the distribution
+// param: status - the status of the site
+// return: error - the error if any
+func (h *Haws) distributionStatus(ctx context.Context, id string, status *SiteStatus) error {
+	if err := h.ensureCloudFront(ctx); err != nil {
+		return err
+	}
+
+	resp, err := h.cloudFrontClient.GetDistribution(ctx, &cloudfront.GetDistributionInput{Id: &id})
+	if err != nil {
+		return fmt.Errorf("unable to get distribution %s: %w", id, err)
+	}
+	status.DistributionId = id
+	status.DistributionStatus = aws.ToString(resp.Distribution.Status)
+	return nil
+}
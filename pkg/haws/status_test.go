@@ -0,0 +1,37 @@
+package haws
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dragosboca/haws/pkg/stack"
+)
+
+func TestSiteStatus(t *testing.T) {
+	status := &SiteStatus{
+		Stacks: []*stack.StackStatus{
+			{Name: "test-bucket", Region: "eu-central-1", Status: "UPDATE_COMPLETE", LastUpdated: time.Unix(1700000000, 0), DriftStatus: "IN_SYNC", TerminationProtection: true, Outputs: map[string]string{"Name": "my-bucket"}},
+			{Name: "test-cloudfront", Region: "eu-central-1", Status: stack.NotCreated, Outputs: map[string]string{}},
+		},
+		CertificateStatus:  "ISSUED",
+		CertificateExpiry:  time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC),
+		DistributionId:     "E123",
+		DistributionStatus: "InProgress",
+	}
+	if status.Stable() {
+		t.Error("A distribution being deployed is not stable")
+	}
+	status.DistributionStatus = "Deployed"
+	if !status.Stable() {
+		t.Error("Expected the site to be stable")
+	}
+
+	var out strings.Builder
+	status.Print(&out)
+	for _, expected := range []string{"test-bucket", "2023-11-14T22:13:20Z", "NOT_CREATED", "ISSUED, expires 2027-01-02", "Distribution E123: Deployed", "Name  my-bucket"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in\n%s", expected, out.String())
+		}
+	}
+}
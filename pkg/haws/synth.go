@@ -0,0 +1,67 @@
+package haws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dragosboca/haws/pkg/logger"
+	"github.com/dragosboca/haws/pkg/stack"
+)
+
+// Synth writes the template of every stack and the values of their parameters to a directory
+// The templates are written to <stack name>.template.<format> and the parameters to parameters.<format>
+// The parameters taken from the outputs of other stacks are read from the deployed stacks, or get the dry-run
+// values of the outputs in dry-run mode, so the files do not depend on the state of the account
+// param: dir - the output directory (created if missing)
+// param: format - the format of the files (json or yaml)
+// return: error - the error if any
+func (h *Haws) Synth(ctx context.Context, dir string, format string) error {
+	if format != stack.FormatJSON && format != stack.FormatYAML {
+		return fmt.Errorf("unknown format %s (expected %s or %s)", format, stack.FormatJSON, stack.FormatYAML)
+	}
+
+	order, err := h.order()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if _, err := h.setBucketReaders(ctx); err != nil {
+		return err
+	}
+
+	parameters := make(map[string]map[string]string)
+	for _, name := range flatten(order) {
+		if err := h.resolveParameters(ctx, name); err != nil {
+			return fmt.Errorf("unable to resolve the parameters of stack %s: %w", name, err)
+		}
+
+		st := h.stacks[name]
+		template, err := st.Synth(format)
+		if err != nil {
+			return fmt.Errorf("stack %s: %w", name, err)
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%s.template.%s", *st.GetStackName(), format))
+		if err := os.WriteFile(path, template, 0644); err != nil {
+			return err
+		}
+		logger.Info("Wrote %s", path)
+
+		parameters[*st.GetStackName()] = st.ParameterValues()
+	}
+
+	data, err := stack.Render(parameters, format)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "parameters."+format)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	logger.Info("Wrote %s", path)
+	return nil
+}
@@ -0,0 +1,62 @@
+package haws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+)
+
+// ZoneResolver finds the domain of a Route53 hosted zone
+type ZoneResolver interface {
+	ZoneDomain(ctx context.Context, zoneId string) (string, error)
+}
+
+// Route53API defines the subset of methods used from the AWS Route53 client
+type Route53API interface {
+	GetHostedZone(ctx context.Context, params *route53.GetHostedZoneInput, optFns ...func(*route53.Options)) (*route53.GetHostedZoneOutput, error)
+}
+
+// Route53Zones looks up the domain of the hosted zones in Route53
+type Route53Zones struct {
+	// Client is the Route53 client (nil creates one with the default credentials on first use)
+	Client Route53API
+}
+
+// ZoneDomain returns the domain of a hosted zone, without the trailing dot
+// param: zoneId - the id of the hosted zone
+// return: string - the domain
+// return: error - the error if any
+func (z *Route53Zones) ZoneDomain(ctx context.Context, zoneId string) (string, error) {
+	if z.Client == nil {
+		client, err := NewClientProvider(ClientOptions{}).Route53(ctx)
+		if err != nil {
+			return "", err
+		}
+		z.Client = client
+	}
+
+	result, err := z.Client.GetHostedZone(ctx, &route53.GetHostedZoneInput{
+		Id: &zoneId,
+	})
+	if err != nil {
+		return "", fmt.Errorf("unable to get the domain of zone %s: %w", zoneId, err)
+	}
+	// trim trailing dot if any
+	return strings.TrimSuffix(*result.HostedZone.Name, "."), nil
+}
+
+// StaticZone is the domain of the hosted zone, known without asking Route53
+type StaticZone string
+
+// ZoneDomain returns the domain, whatever the zone
+// return: string - the domain, without the trailing dot
+// return: error - the error if the domain is empty
+func (z StaticZone) ZoneDomain(ctx context.Context, zoneId string) (string, error) {
+	domain := strings.TrimSuffix(string(z), ".")
+	if domain == "" {
+		return "", fmt.Errorf("the domain of zone %s is empty", zoneId)
+	}
+	return domain, nil
+}
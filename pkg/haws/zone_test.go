@@ -0,0 +1,50 @@
+package haws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	r53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+type mockRoute53 struct {
+	name string
+	err  error
+}
+
+func (m *mockRoute53) GetHostedZone(ctx context.Context, params *route53.GetHostedZoneInput, optFns ...func(*route53.Options)) (*route53.GetHostedZoneOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &route53.GetHostedZoneOutput{HostedZone: &r53types.HostedZone{Id: params.Id, Name: aws.String(m.name)}}, nil
+}
+
+func TestRoute53Zones(t *testing.T) {
+	zones := &Route53Zones{Client: &mockRoute53{name: "example.com."}}
+	domain, err := zones.ZoneDomain(context.Background(), "Z123")
+	if err != nil || domain != "example.com" {
+		t.Errorf("Expected example.com, got %q, %v", domain, err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	h, err := New(context.Background(), StaticZone("example.com."), false, "test", "eu-central-1", "Z123", "/", "www")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if name := *h.stacks["cloudfront"].GetStackName(); name != "test-www-example-com-cloudfront" {
+		t.Errorf("Expected the stacks of www.example.com, got %s", name)
+	}
+
+	if _, err := New(context.Background(), StaticZone("example.com."), false, "test", "eu-central-1", "Z123", "/", "*"); err == nil {
+		t.Error("Expected an error for a wildcard record")
+	}
+
+	failing := &Route53Zones{Client: &mockRoute53{err: errors.New("no credentials")}}
+	if _, err := New(context.Background(), failing, false, "test", "eu-central-1", "Z123", "/", "www"); err == nil {
+		t.Error("Expected the error of the zone lookup")
+	}
+}
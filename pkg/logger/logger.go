@@ -3,6 +3,7 @@ package logger
 import (
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
@@ -32,6 +33,9 @@ var (
 	warnColor  = color.New(color.FgYellow)
 	errorColor = color.New(color.FgRed)
 	fatalColor = color.New(color.FgRed, color.Bold)
+
+	// mu keeps the lines of concurrent stacks from interleaving
+	mu sync.Mutex
 )
 
 // SetLevel sets the current log level
@@ -73,13 +77,16 @@ func Fatal(format string, args ...interface{}) {
 	os.Exit(1)
 }
 
-// log formats and prints a log message
+// log formats and prints a log message to stderr, leaving stdout to the output of the commands
 func log(level string, format string, args ...interface{}) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	message := fmt.Sprintf(format, args...)
+
+	mu.Lock()
+	defer mu.Unlock()
 	
 	// Format: [timestamp] LEVEL: message
-	fmt.Printf("[%s] ", timestamp)
+	fmt.Fprintf(os.Stderr, "[%s] ", timestamp)
 	
 	// Select the appropriate color based on the level
 	var levelColor *color.Color
@@ -98,6 +105,6 @@ func log(level string, format string, args ...interface{}) {
 		levelColor = infoColor
 	}
 	
-	levelColor.Printf("%s: ", level)
-	fmt.Println(message)
+	levelColor.Fprintf(os.Stderr, "%s: ", level)
+	fmt.Fprintln(os.Stderr, message)
 }
@@ -8,11 +8,11 @@ import (
 	"testing"
 )
 
-// captureOutput captures stdout for testing log output
+// captureOutput captures stderr for testing log output
 func captureOutput(f func()) string {
-	old := os.Stdout
+	old := os.Stderr
 	r, w, _ := os.Pipe()
-	os.Stdout = w
+	os.Stderr = w
 
 	f()
 
@@ -24,7 +24,7 @@ func captureOutput(f func()) string {
 	}()
 
 	w.Close()
-	os.Stdout = old
+	os.Stderr = old
 	return <-outC
 }
 
@@ -94,3 +94,20 @@ func TestSetLevel(t *testing.T) {
 		}
 	}
 }
+
+func TestLogToStderr(t *testing.T) {
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	captureOutput(func() {
+		Info("test stderr message")
+	})
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing on stdout, got: %s", buf.String())
+	}
+}
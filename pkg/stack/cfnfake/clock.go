@@ -0,0 +1,46 @@
+package cfnfake
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a clock that never sleeps: waiting on it moves its time forward
+// It satisfies stack.Clock, so the stacks and the fake can share the same time
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock creates a clock starting at the current time
+// return: *Clock - the clock
+func NewClock() *Clock {
+	return &Clock{now: time.Now()}
+}
+
+// Now returns the time of the clock
+// return: time.Time - the time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After moves the time of the clock forward and returns a channel that is already ready
+// param: d - the duration to wait
+// return: <-chan time.Time - the channel receiving the new time
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- c.Advance(d)
+	return ch
+}
+
+// Advance moves the time of the clock forward
+// param: d - the duration
+// return: time.Time - the new time
+func (c *Clock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
@@ -0,0 +1,1324 @@
+// Package cfnfake is a stateful in-memory CloudFormation for the tests of the deployments
+// It keeps the stacks, change sets, exports and imports of one region, lets the stack operations
+// take time on a simulated clock and injects faults, so the flows of haws run end to end without AWS
+package cfnfake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+)
+
+// EmptyChangeSet is the reason of the change sets that do not contain changes
+const EmptyChangeSet = "The submitted information didn't contain changes. Submit different information to create a change set."
+
+// Fake is an in-memory CloudFormation of one region
+// It implements the methods of the CloudFormation client used by the stacks
+type Fake struct {
+	// OperationTime is how long the stack operations stay in progress (zero completes them on the next read)
+	OperationTime time.Duration
+
+	mu         sync.Mutex
+	region     string
+	clock      *Clock
+	seq        int
+	stacks     map[string]*fakeStack
+	exports    map[string]export
+	detections map[string]string
+	drifts     map[string]map[string][]types.PropertyDifference
+	faults     map[string]string
+	callFaults map[string]error
+	calls      map[string]int
+}
+
+// export is a value exported by a stack
+type export struct {
+	stack string
+	value string
+}
+
+// fakeStack is the state of a stack
+type fakeStack struct {
+	id         string
+	name       string
+	status     types.StackStatus
+	reason     string
+	template   template
+	parameters map[string]string
+	tags       []types.Tag
+	resources  map[string]*resource
+	outputs    []types.Output
+	imports    []string
+	changeSets []*changeSet
+	events     []types.StackEvent
+	policy     string
+	protected  bool
+	drift      types.StackDriftStatus
+	driftCheck *time.Time
+	created    time.Time
+	updated    *time.Time
+	pending    *operation
+}
+
+// resource is a resource of a stack
+type resource struct {
+	logicalId    string
+	physicalId   string
+	resourceType string
+}
+
+// changeSet is a change set of a stack
+type changeSet struct {
+	id           string
+	name         string
+	csType       types.ChangeSetType
+	status       types.ChangeSetStatus
+	reason       string
+	execution    types.ExecutionStatus
+	template     template
+	parameters   map[string]string
+	tags         []types.Tag
+	capabilities []types.Capability
+	imports      map[string]string
+	changes      []types.Change
+	created      time.Time
+}
+
+// operation is a stack operation in progress, completed by finish once the clock reaches done
+type operation struct {
+	done   time.Time
+	finish func()
+}
+
+// New creates an empty fake of a region
+// param: region - the region of the fake
+// param: clock - the clock measuring the duration of the operations (nil for a new clock)
+// return: *Fake - the fake
+func New(region string, clock *Clock) *Fake {
+	if clock == nil {
+		clock = NewClock()
+	}
+	return &Fake{
+		region:     region,
+		clock:      clock,
+		stacks:     make(map[string]*fakeStack),
+		exports:    make(map[string]export),
+		detections: make(map[string]string),
+		drifts:     make(map[string]map[string][]types.PropertyDifference),
+		faults:     make(map[string]string),
+		callFaults: make(map[string]error),
+		calls:      make(map[string]int),
+	}
+}
+
+// Clock returns the clock of the fake
+// return: *Clock - the clock
+func (f *Fake) Clock() *Clock {
+	return f.clock
+}
+
+// FailResource makes the next operation creating, updating or deleting a resource fail on it
+// param: stack - the name of the stack
+// param: logicalId - the name of the resource in the template
+// param: reason - the status reason of the failed resource
+func (f *Fake) FailResource(stack string, logicalId string, reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults[stack+"/"+logicalId] = reason
+}
+
+// FailCall makes the next call of an API method return an error
+// param: method - the name of the method (e.g. DescribeStacks)
+// param: err - the error to return
+func (f *Fake) FailCall(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.callFaults[method] = err
+}
+
+// SetDrift reports a resource as drifted in the next drift detections
+// param: stack - the name of the stack
+// param: logicalId - the name of the resource in the template
+// param: differences - the drifted properties (none reports the resource as deleted)
+func (f *Fake) SetDrift(stack string, logicalId string, differences ...types.PropertyDifference) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.drifts[stack] == nil {
+		f.drifts[stack] = make(map[string][]types.PropertyDifference)
+	}
+	f.drifts[stack][logicalId] = differences
+}
+
+// SetStackStatus forces the status of a stack, e.g. to leave it in a failed state
+// param: stack - the name of the stack
+// param: status - the status
+// param: reason - the status reason
+// return: error - the error if the stack does not exist
+func (f *Fake) SetStackStatus(stack string, status types.StackStatus, reason string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	st, err := f.stack(stack)
+	if err != nil {
+		return err
+	}
+	st.status = status
+	st.reason = reason
+	return nil
+}
+
+// Exports returns the values exported by the stacks
+// return: map[string]string - the values indexed by export name
+func (f *Fake) Exports() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.advance()
+	values := make(map[string]string, len(f.exports))
+	for name, exp := range f.exports {
+		values[name] = exp.value
+	}
+	return values
+}
+
+// StackNames returns the names of the stacks
+// return: []string - the names, sorted
+func (f *Fake) StackNames() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.advance()
+	names := make([]string, 0, len(f.stacks))
+	for name := range f.stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Calls returns the number of calls of an API method
+// param: method - the name of the method (e.g. ExecuteChangeSet)
+// return: int - the number of calls
+func (f *Fake) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// call records the call of a method and returns its injected fault if any
+// It also completes the operations that are over
+func (f *Fake) call(method string) error {
+	f.calls[method]++
+	f.advance()
+	if err, ok := f.callFaults[method]; ok {
+		delete(f.callFaults, method)
+		return err
+	}
+	return nil
+}
+
+// advance completes the operations whose time is over
+func (f *Fake) advance() {
+	now := f.clock.Now()
+	for _, name := range f.sortedStacks() {
+		st := f.stacks[name]
+		if st.pending != nil && !now.Before(st.pending.done) {
+			op := st.pending
+			st.pending = nil
+			op.finish()
+		}
+	}
+}
+
+// sortedStacks returns the names of the stacks, sorted
+func (f *Fake) sortedStacks() []string {
+	names := make([]string, 0, len(f.stacks))
+	for name := range f.stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apiError builds an error of the CloudFormation API
+func apiError(code string, format string, args ...interface{}) error {
+	return &smithy.GenericAPIError{Code: code, Message: fmt.Sprintf(format, args...), Fault: smithy.FaultClient}
+}
+
+// stack finds a stack by name or id
+func (f *Fake) stack(name string) (*fakeStack, error) {
+	if st, ok := f.stacks[name]; ok {
+		return st, nil
+	}
+	for _, st := range f.stacks {
+		if st.id == name {
+			return st, nil
+		}
+	}
+	return nil, apiError("ValidationError", "Stack with id %s does not exist", name)
+}
+
+// nextId returns a new unique number
+func (f *Fake) nextId() int {
+	f.seq++
+	return f.seq
+}
+
+// start puts the stack in an in progress status and completes the operation after OperationTime
+func (f *Fake) start(st *fakeStack, status types.StackStatus, token string, finish func()) {
+	st.status = status
+	st.reason = ""
+	f.event(st, st.name, st.id, "AWS::CloudFormation::Stack", string(status), "User Initiated", token)
+	st.pending = &operation{done: f.clock.Now().Add(f.OperationTime), finish: finish}
+}
+
+// event appends an event to the stack
+func (f *Fake) event(st *fakeStack, logicalId string, physicalId string, resourceType string, status string, reason string, token string) {
+	now := f.clock.Now()
+	ev := types.StackEvent{
+		EventId:            aws.String(fmt.Sprintf("%s-event-%d", logicalId, f.nextId())),
+		StackId:            aws.String(st.id),
+		StackName:          aws.String(st.name),
+		LogicalResourceId:  aws.String(logicalId),
+		PhysicalResourceId: aws.String(physicalId),
+		ResourceType:       aws.String(resourceType),
+		ResourceStatus:     types.ResourceStatus(status),
+		Timestamp:          &now,
+		ClientRequestToken: aws.String(token),
+	}
+	if reason != "" {
+		ev.ResourceStatusReason = aws.String(reason)
+	}
+	st.events = append(st.events, ev)
+}
+
+// importers returns the stacks importing an export
+func (f *Fake) importers(exportName string) []string {
+	users := make([]string, 0)
+	for _, name := range f.sortedStacks() {
+		for _, imported := range f.stacks[name].imports {
+			if imported == exportName {
+				users = append(users, name)
+			}
+		}
+	}
+	return users
+}
+
+// exportValues returns the values of the exports
+func (f *Fake) exportValues() map[string]string {
+	values := make(map[string]string, len(f.exports))
+	for name, exp := range f.exports {
+		values[name] = exp.value
+	}
+	return values
+}
+
+// resolver returns the resolver of a template deployed on a stack
+func (f *Fake) resolver(st *fakeStack, parameters map[string]string, tpl template) *resolver {
+	r := &resolver{
+		stack:      st.name,
+		region:     f.region,
+		parameters: parameters,
+		physical:   make(map[string]string),
+		types:      make(map[string]string),
+		exports:    f.exportValues(),
+	}
+	for name, res := range st.resources {
+		r.physical[name] = res.physicalId
+		r.types[name] = res.resourceType
+	}
+	for _, name := range tpl.resourceNames() {
+		r.types[name] = tpl.resourceType(name)
+	}
+	return r
+}
+
+// CreateChangeSet creates a change set, and the stack in REVIEW_IN_PROGRESS if it does not exist yet
+func (f *Fake) CreateChangeSet(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("CreateChangeSet"); err != nil {
+		return nil, err
+	}
+
+	name := aws.ToString(params.StackName)
+	csName := aws.ToString(params.ChangeSetName)
+	if name == "" || csName == "" {
+		return nil, apiError("ValidationError", "StackName and ChangeSetName are required")
+	}
+	if params.TemplateURL != nil {
+		return nil, apiError("ValidationError", "the fake only supports TemplateBody")
+	}
+	tpl, err := parseTemplate(aws.ToString(params.TemplateBody))
+	if err != nil {
+		return nil, apiError("ValidationError", "%s", err)
+	}
+	if err := checkCapabilities(tpl, params.Capabilities); err != nil {
+		return nil, err
+	}
+
+	csType := params.ChangeSetType
+	if csType == "" {
+		csType = types.ChangeSetTypeUpdate
+	}
+
+	st, err := f.stack(name)
+	switch {
+	case err != nil && csType == types.ChangeSetTypeUpdate:
+		return nil, err
+	case err != nil:
+		st = &fakeStack{
+			id:        fmt.Sprintf("arn:aws:cloudformation:%s:%s:stack/%s/%d", f.region, Account, name, f.nextId()),
+			name:      name,
+			status:    types.StackStatusReviewInProgress,
+			resources: make(map[string]*resource),
+			created:   f.clock.Now(),
+		}
+		f.stacks[name] = st
+		f.event(st, name, st.id, "AWS::CloudFormation::Stack", string(st.status), "User Initiated", "")
+	case csType == types.ChangeSetTypeCreate && st.status != types.StackStatusReviewInProgress:
+		return nil, apiError("AlreadyExistsException", "Stack [%s] already exists and cannot be created again with the changeSet [%s].", name, csName)
+	case csType == types.ChangeSetTypeUpdate && (st.status == types.StackStatusReviewInProgress || strings.HasSuffix(string(st.status), "_IN_PROGRESS")):
+		return nil, apiError("ValidationError", "Stack:%s is in %s state and can not be updated.", st.id, st.status)
+	}
+	for _, cs := range st.changeSets {
+		if cs.name == csName {
+			return nil, apiError("AlreadyExistsException", "ChangeSet %s already exists", csName)
+		}
+	}
+
+	parameters, err := parameterValues(tpl, params.Parameters, st.parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &changeSet{
+		id:           fmt.Sprintf("arn:aws:cloudformation:%s:%s:changeSet/%s/%d", f.region, Account, csName, f.nextId()),
+		name:         csName,
+		csType:       csType,
+		status:       types.ChangeSetStatusCreateComplete,
+		execution:    types.ExecutionStatusAvailable,
+		template:     tpl,
+		parameters:   parameters,
+		tags:         params.Tags,
+		capabilities: params.Capabilities,
+		imports:      make(map[string]string),
+		created:      f.clock.Now(),
+	}
+	st.changeSets = append(st.changeSets, cs)
+
+	if reason := f.plan(st, cs, params.ResourcesToImport); reason != "" {
+		cs.status = types.ChangeSetStatusFailed
+		cs.execution = types.ExecutionStatusUnavailable
+		cs.reason = reason
+	}
+
+	return &cloudformation.CreateChangeSetOutput{Id: aws.String(cs.id), StackId: aws.String(st.id)}, nil
+}
+
+// checkCapabilities checks that the capabilities acknowledge the IAM resources of a template
+func checkCapabilities(tpl template, capabilities []types.Capability) error {
+	acknowledged := make(map[types.Capability]bool)
+	for _, c := range capabilities {
+		acknowledged[c] = true
+	}
+
+	required := types.Capability("")
+	for _, name := range tpl.resourceNames() {
+		if !strings.HasPrefix(tpl.resourceType(name), "AWS::IAM::") {
+			continue
+		}
+		if required == "" {
+			required = types.CapabilityCapabilityIam
+		}
+		props, _ := tpl.resource(name)["Properties"].(map[string]interface{})
+		for _, property := range []string{"UserName", "RoleName", "GroupName", "ManagedPolicyName"} {
+			if _, ok := props[property]; ok {
+				required = types.CapabilityCapabilityNamedIam
+			}
+		}
+	}
+
+	switch {
+	case required == "" || acknowledged[types.CapabilityCapabilityNamedIam]:
+		return nil
+	case required == types.CapabilityCapabilityIam && acknowledged[types.CapabilityCapabilityIam]:
+		return nil
+	}
+	return apiError("InsufficientCapabilitiesException", "Requires capabilities : [%s]", required)
+}
+
+// parameterValues merges the parameters of a change set with the defaults of the template and the previous values
+func parameterValues(tpl template, given []types.Parameter, previous map[string]string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, name := range sortedKeys(tpl.section("Parameters")) {
+		if def, ok := tpl.section("Parameters")[name].(map[string]interface{}); ok {
+			if value, ok := def["Default"]; ok {
+				values[name] = fmt.Sprint(value)
+			}
+		}
+	}
+	for _, p := range given {
+		key := aws.ToString(p.ParameterKey)
+		if _, ok := tpl.section("Parameters")[key]; !ok {
+			return nil, apiError("ValidationError", "Parameters: [%s] do not exist in the template", key)
+		}
+		if aws.ToBool(p.UsePreviousValue) {
+			values[key] = previous[key]
+		} else {
+			values[key] = aws.ToString(p.ParameterValue)
+		}
+	}
+	for _, name := range sortedKeys(tpl.section("Parameters")) {
+		if _, ok := values[name]; !ok {
+			return nil, apiError("ValidationError", "Parameters: [%s] must have values", name)
+		}
+	}
+	return values, nil
+}
+
+// plan computes the changes of a change set against the deployed stack
+// return: string - the reason of the failure of the change set (empty if it can be executed)
+func (f *Fake) plan(st *fakeStack, cs *changeSet, toImport []types.ResourceToImport) string {
+	for _, name := range cs.template.imports(f.resolver(st, cs.parameters, cs.template)) {
+		if _, ok := f.exports[name]; !ok {
+			return fmt.Sprintf("No export named %s found", name)
+		}
+	}
+
+	before := st.template
+	if before == nil {
+		before = template{}
+	}
+	changedParameters := make([]string, 0)
+	for name, value := range cs.parameters {
+		if old, ok := st.parameters[name]; !ok || old != value {
+			changedParameters = append(changedParameters, name)
+		}
+	}
+	sort.Strings(changedParameters)
+
+	if cs.csType == types.ChangeSetTypeImport {
+		return f.planImport(st, cs, toImport, before, changedParameters)
+	}
+
+	for _, name := range cs.template.resourceNames() {
+		resourceType := cs.template.resourceType(name)
+		if _, ok := st.resources[name]; !ok {
+			cs.changes = append(cs.changes, resourceChange(types.ChangeActionAdd, name, "", resourceType, "", nil))
+			continue
+		}
+		physicalId := st.resources[name].physicalId
+		if before.resourceType(name) != resourceType {
+			cs.changes = append(cs.changes, resourceChange(types.ChangeActionModify, name, physicalId, resourceType, types.ReplacementTrue, nil))
+			continue
+		}
+		changed := changedProperties(before.resource(name), cs.template.resource(name), changedParameters)
+		if len(changed) == 0 {
+			continue
+		}
+		replacement := types.ReplacementFalse
+		details := make([]types.ResourceChangeDetail, 0, len(changed))
+		for _, property := range changed {
+			recreation := types.RequiresRecreationNever
+			if replaces(resourceType, property) {
+				recreation = types.RequiresRecreationAlways
+				replacement = types.ReplacementTrue
+			}
+			details = append(details, types.ResourceChangeDetail{
+				ChangeSource: types.ChangeSourceDirectModification,
+				Evaluation:   types.EvaluationTypeStatic,
+				Target: &types.ResourceTargetDefinition{
+					Attribute:          types.ResourceAttributeProperties,
+					Name:               aws.String(property),
+					RequiresRecreation: recreation,
+				},
+			})
+		}
+		cs.changes = append(cs.changes, resourceChange(types.ChangeActionModify, name, physicalId, resourceType, replacement, details))
+	}
+	for _, name := range before.resourceNames() {
+		if _, ok := cs.template.section("Resources")[name]; !ok {
+			cs.changes = append(cs.changes, resourceChange(types.ChangeActionRemove, name, st.resources[name].physicalId, before.resourceType(name), "", nil))
+		}
+	}
+
+	if len(cs.changes) > 0 || !reflect.DeepEqual(before.section("Outputs"), cs.template.section("Outputs")) ||
+		!reflect.DeepEqual(st.tags, cs.tags) && !(len(st.tags) == 0 && len(cs.tags) == 0) {
+		return ""
+	}
+	for _, param := range changedParameters {
+		if refersTo(cs.template["Outputs"], param) {
+			return ""
+		}
+	}
+	return EmptyChangeSet
+}
+
+// planImport checks an import change set: only the imported resources may change
+func (f *Fake) planImport(st *fakeStack, cs *changeSet, toImport []types.ResourceToImport, before template, changedParameters []string) string {
+	if len(toImport) == 0 {
+		return "ResourcesToImport must be set for an IMPORT change set"
+	}
+	for _, res := range toImport {
+		name := aws.ToString(res.LogicalResourceId)
+		definition, ok := cs.template.section("Resources")[name].(map[string]interface{})
+		if !ok {
+			return fmt.Sprintf("Resource %s to import does not exist in the template", name)
+		}
+		if _, ok := definition["DeletionPolicy"]; !ok {
+			return fmt.Sprintf("The following resources to import [%s] must have DeletionPolicy attribute specified in the template.", name)
+		}
+		if _, ok := st.resources[name]; ok {
+			return fmt.Sprintf("Resource %s already belongs to stack %s", name, st.name)
+		}
+		identifiers := make([]string, 0, len(res.ResourceIdentifier))
+		for _, key := range sortedStrings(res.ResourceIdentifier) {
+			identifiers = append(identifiers, res.ResourceIdentifier[key])
+		}
+		if len(identifiers) == 0 || identifiers[0] == "" {
+			return fmt.Sprintf("Resource %s to import has no identifier", name)
+		}
+		cs.imports[name] = identifiers[0]
+		cs.changes = append(cs.changes, resourceChange(types.ChangeActionImport, name, identifiers[0], aws.ToString(res.ResourceType), "", nil))
+	}
+
+	for _, name := range cs.template.resourceNames() {
+		if _, ok := cs.imports[name]; ok {
+			continue
+		}
+		if _, ok := st.resources[name]; !ok {
+			return fmt.Sprintf("There are additional resources (%s) in the template that are not being imported", name)
+		}
+		if len(changedProperties(before.resource(name), cs.template.resource(name), changedParameters)) > 0 {
+			return fmt.Sprintf("Resource %s cannot be modified during an import", name)
+		}
+	}
+	for _, name := range before.resourceNames() {
+		if _, ok := cs.template.section("Resources")[name]; !ok {
+			return fmt.Sprintf("Resource %s cannot be removed during an import", name)
+		}
+	}
+	return ""
+}
+
+// replaces returns true if changing the property replaces a resource of the type
+func replaces(resourceType string, property string) bool {
+	for _, p := range replacementProperties[resourceType] {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceChange builds a change of a change set
+func resourceChange(action types.ChangeAction, logicalId string, physicalId string, resourceType string, replacement types.Replacement, details []types.ResourceChangeDetail) types.Change {
+	rc := &types.ResourceChange{
+		Action:            action,
+		LogicalResourceId: aws.String(logicalId),
+		ResourceType:      aws.String(resourceType),
+		Replacement:       replacement,
+		Details:           details,
+	}
+	if physicalId != "" {
+		rc.PhysicalResourceId = aws.String(physicalId)
+	}
+	return types.Change{Type: types.ChangeTypeResource, ResourceChange: rc}
+}
+
+// sortedStrings returns the keys of a map of strings, sorted
+func sortedStrings(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// changeSet finds a change set of a stack by name or id
+func (f *Fake) changeSet(stackName string, name string) (*fakeStack, *changeSet, error) {
+	st, err := f.stack(stackName)
+	if err == nil {
+		for _, cs := range st.changeSets {
+			if cs.name == name || cs.id == name {
+				return st, cs, nil
+			}
+		}
+	}
+	return nil, nil, apiError("ChangeSetNotFoundException", "ChangeSet [%s] does not exist", name)
+}
+
+// DescribeChangeSet describes a change set and its changes
+func (f *Fake) DescribeChangeSet(ctx context.Context, params *cloudformation.DescribeChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DescribeChangeSet"); err != nil {
+		return nil, err
+	}
+
+	st, cs, err := f.changeSet(aws.ToString(params.StackName), aws.ToString(params.ChangeSetName))
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := make([]types.Parameter, 0, len(cs.parameters))
+	for _, key := range sortedStrings(cs.parameters) {
+		parameters = append(parameters, types.Parameter{ParameterKey: aws.String(key), ParameterValue: aws.String(cs.parameters[key])})
+	}
+	created := cs.created
+	out := &cloudformation.DescribeChangeSetOutput{
+		ChangeSetId:     aws.String(cs.id),
+		ChangeSetName:   aws.String(cs.name),
+		StackId:         aws.String(st.id),
+		StackName:       aws.String(st.name),
+		Status:          cs.status,
+		ExecutionStatus: cs.execution,
+		Changes:         append([]types.Change(nil), cs.changes...),
+		Parameters:      parameters,
+		Tags:            cs.tags,
+		Capabilities:    cs.capabilities,
+		CreationTime:    &created,
+	}
+	if cs.reason != "" {
+		out.StatusReason = aws.String(cs.reason)
+	}
+	return out, nil
+}
+
+// DeleteChangeSet deletes a change set that is not being executed
+func (f *Fake) DeleteChangeSet(ctx context.Context, params *cloudformation.DeleteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DeleteChangeSet"); err != nil {
+		return nil, err
+	}
+
+	st, cs, err := f.changeSet(aws.ToString(params.StackName), aws.ToString(params.ChangeSetName))
+	if err != nil {
+		return nil, err
+	}
+	if cs.execution == types.ExecutionStatusExecuteInProgress {
+		return nil, apiError("InvalidChangeSetStatus", "Cannot delete ChangeSet in status %s", cs.execution)
+	}
+	for i, other := range st.changeSets {
+		if other == cs {
+			st.changeSets = append(st.changeSets[:i], st.changeSets[i+1:]...)
+			break
+		}
+	}
+	return &cloudformation.DeleteChangeSetOutput{}, nil
+}
+
+// ExecuteChangeSet starts the stack operation of a change set
+func (f *Fake) ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("ExecuteChangeSet"); err != nil {
+		return nil, err
+	}
+
+	st, cs, err := f.changeSet(aws.ToString(params.StackName), aws.ToString(params.ChangeSetName))
+	if err != nil {
+		return nil, err
+	}
+	if cs.status != types.ChangeSetStatusCreateComplete || cs.execution != types.ExecutionStatusAvailable {
+		return nil, apiError("InvalidChangeSetStatus", "ChangeSet [%s] cannot be executed in its current status of [%s]", cs.name, cs.status)
+	}
+	if st.pending != nil {
+		return nil, apiError("ValidationError", "Stack:%s is in %s state and can not be updated.", st.id, st.status)
+	}
+
+	cs.execution = types.ExecutionStatusExecuteInProgress
+	for _, other := range st.changeSets {
+		if other != cs {
+			other.execution = types.ExecutionStatusObsolete
+		}
+	}
+
+	token := aws.ToString(params.ClientRequestToken)
+	inProgress, complete, failed, rollback := types.StackStatusUpdateInProgress, types.StackStatusUpdateComplete,
+		types.ResourceStatusUpdateFailed, types.StackStatusUpdateRollbackComplete
+	switch cs.csType {
+	case types.ChangeSetTypeCreate:
+		inProgress, complete, failed, rollback = types.StackStatusCreateInProgress, types.StackStatusCreateComplete,
+			types.ResourceStatusCreateFailed, types.StackStatusRollbackComplete
+	case types.ChangeSetTypeImport:
+		inProgress, complete, failed, rollback = types.StackStatusImportInProgress, types.StackStatusImportComplete,
+			types.ResourceStatusImportFailed, types.StackStatusImportRollbackComplete
+	}
+
+	f.start(st, inProgress, token, func() {
+		if logicalId, reason := f.failure(st, cs); reason != "" {
+			physicalId, resourceType := "", cs.template.resourceType(logicalId)
+			if res, ok := st.resources[logicalId]; ok {
+				physicalId = res.physicalId
+			}
+			if logicalId != "" {
+				f.event(st, logicalId, physicalId, resourceType, string(failed), reason, token)
+			}
+			cs.execution = types.ExecutionStatusExecuteFailed
+			if cs.csType == types.ChangeSetTypeCreate {
+				st.resources = make(map[string]*resource)
+			}
+			st.status = rollback
+			st.reason = reason
+			if logicalId != "" {
+				st.reason = fmt.Sprintf("The following resource(s) failed: [%s]", logicalId)
+			}
+			f.event(st, st.name, st.id, "AWS::CloudFormation::Stack", string(rollback), st.reason, token)
+			return
+		}
+
+		f.apply(st, cs, token)
+		cs.execution = types.ExecutionStatusExecuteComplete
+		st.status = complete
+		if cs.csType != types.ChangeSetTypeCreate {
+			now := f.clock.Now()
+			st.updated = &now
+		}
+		f.event(st, st.name, st.id, "AWS::CloudFormation::Stack", string(complete), "", token)
+	})
+	return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+// failure finds the reason why a change set fails to execute
+// return: string - the failed resource (empty if the stack itself fails)
+// return: string - the reason of the failure (empty if the change set succeeds)
+func (f *Fake) failure(st *fakeStack, cs *changeSet) (string, string) {
+	for _, change := range cs.changes {
+		logicalId := aws.ToString(change.ResourceChange.LogicalResourceId)
+		if reason, ok := f.faults[st.name+"/"+logicalId]; ok {
+			delete(f.faults, st.name+"/"+logicalId)
+			return logicalId, reason
+		}
+		action := ""
+		switch {
+		case change.ResourceChange.Action == types.ChangeActionRemove:
+			action = "Update:Delete"
+		case change.ResourceChange.Replacement == types.ReplacementTrue:
+			action = "Update:Replace"
+		}
+		if action != "" && denied(st.policy, action, logicalId) {
+			return logicalId, "Action denied by stack policy: Statement [#1] has a Deny effect"
+		}
+	}
+
+	outputs, _ := f.outputs(st, cs)
+	for _, output := range outputs {
+		if output.ExportName == nil {
+			continue
+		}
+		name := aws.ToString(output.ExportName)
+		if exp, ok := f.exports[name]; ok && exp.stack != st.name {
+			return "", fmt.Sprintf("Export with name %s is already exported by stack %s", name, exp.stack)
+		}
+		if exp, ok := f.exports[name]; ok && exp.value != aws.ToString(output.OutputValue) {
+			if users := f.importers(name); len(users) > 0 {
+				return "", fmt.Sprintf("Cannot update export %s as it is in use by %s", name, strings.Join(users, ", "))
+			}
+		}
+	}
+	for _, name := range f.removedExports(st, outputs) {
+		if users := f.importers(name); len(users) > 0 {
+			return "", fmt.Sprintf("Export %s cannot be deleted as it is in use by %s", name, strings.Join(users, ", "))
+		}
+	}
+	return "", ""
+}
+
+// denied returns true if the stack policy denies an action on a resource
+func denied(policy string, action string, logicalId string) bool {
+	if policy == "" {
+		return false
+	}
+	var document struct {
+		Statement []struct {
+			Effect   string
+			Action   []string
+			Resource []string
+		}
+	}
+	if err := json.Unmarshal([]byte(policy), &document); err != nil {
+		return false
+	}
+	for _, statement := range document.Statement {
+		if statement.Effect != "Deny" {
+			continue
+		}
+		matches := false
+		for _, a := range statement.Action {
+			matches = matches || a == action || a == "Update:*"
+		}
+		for _, r := range statement.Resource {
+			if matches && (r == "*" || r == "LogicalResourceId/"+logicalId) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removedExports returns the exports of the stack that the new outputs do not export anymore
+func (f *Fake) removedExports(st *fakeStack, outputs []types.Output) []string {
+	kept := make(map[string]bool)
+	for _, output := range outputs {
+		kept[aws.ToString(output.ExportName)] = true
+	}
+	removed := make([]string, 0)
+	for _, output := range st.outputs {
+		if name := aws.ToString(output.ExportName); name != "" && !kept[name] {
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
+
+// apply deploys the template of a change set on the stack
+func (f *Fake) apply(st *fakeStack, cs *changeSet, token string) {
+	replaced := make(map[string]bool)
+	for _, change := range cs.changes {
+		if change.ResourceChange.Replacement == types.ReplacementTrue {
+			replaced[aws.ToString(change.ResourceChange.LogicalResourceId)] = true
+		}
+	}
+
+	r := f.resolver(st, cs.parameters, cs.template)
+	resources := make(map[string]*resource)
+	for _, name := range cs.template.resourceNames() {
+		resourceType := cs.template.resourceType(name)
+		res, ok := st.resources[name]
+		status := "UPDATE_COMPLETE"
+		switch {
+		case cs.imports[name] != "":
+			res = &resource{logicalId: name, physicalId: cs.imports[name], resourceType: resourceType}
+			status = string(types.ResourceStatusImportComplete)
+		case !ok || replaced[name]:
+			res = &resource{logicalId: name, physicalId: f.physicalId(st, name, resourceType, cs.template, r), resourceType: resourceType}
+			status = "CREATE_COMPLETE"
+		}
+		resources[name] = res
+		r.physical[name] = res.physicalId
+		if !ok || replaced[name] || cs.imports[name] != "" || f.changed(cs, name) {
+			f.event(st, name, res.physicalId, resourceType, status, "", token)
+		}
+	}
+	for name, res := range st.resources {
+		if _, ok := resources[name]; !ok {
+			f.event(st, name, res.physicalId, res.resourceType, "DELETE_COMPLETE", "", token)
+		}
+	}
+	st.resources = resources
+
+	outputs, imports := f.outputs(st, cs)
+	for _, name := range f.removedExports(st, outputs) {
+		delete(f.exports, name)
+	}
+	for _, output := range outputs {
+		if output.ExportName != nil {
+			f.exports[aws.ToString(output.ExportName)] = export{stack: st.name, value: aws.ToString(output.OutputValue)}
+		}
+	}
+
+	st.template = cs.template
+	st.parameters = cs.parameters
+	st.tags = cs.tags
+	st.outputs = outputs
+	st.imports = imports
+}
+
+// changed returns true if the change set modifies a resource
+func (f *Fake) changed(cs *changeSet, logicalId string) bool {
+	for _, change := range cs.changes {
+		if aws.ToString(change.ResourceChange.LogicalResourceId) == logicalId {
+			return true
+		}
+	}
+	return false
+}
+
+// physicalId generates the physical id of a new resource
+func (f *Fake) physicalId(st *fakeStack, logicalId string, resourceType string, tpl template, r *resolver) string {
+	props, _ := r.resolve(tpl.resource(logicalId)["Properties"]).(map[string]interface{})
+	id := f.nextId()
+	switch resourceType {
+	case "AWS::S3::Bucket":
+		if name, ok := props["BucketName"].(string); ok && name != "" {
+			return name
+		}
+		return strings.ToLower(fmt.Sprintf("%s-%s-%d", st.name, logicalId, id))
+	case "AWS::IAM::User":
+		if name, ok := props["UserName"].(string); ok && name != "" {
+			return name
+		}
+	case "AWS::CloudFront::Function":
+		if name, ok := props["Name"].(string); ok && name != "" {
+			return name
+		}
+	case "AWS::IAM::AccessKey":
+		return fmt.Sprintf("AKIAFAKE%012d", id)
+	case "AWS::CloudFront::Distribution", "AWS::CloudFront::CloudFrontOriginAccessIdentity", "AWS::CloudFront::OriginAccessControl":
+		return fmt.Sprintf("E%013d", id)
+	case "AWS::CertificateManager::Certificate":
+		return fmt.Sprintf("arn:aws:acm:%s:%s:certificate/%08d-fake", f.region, Account, id)
+	case "AWS::Route53::RecordSet":
+		if name, ok := props["Name"].(string); ok && name != "" {
+			return name
+		}
+	}
+	return fmt.Sprintf("%s-%s-%d", st.name, logicalId, id)
+}
+
+// outputs evaluates the outputs of the template of a change set
+// return: []types.Output - the outputs
+// return: []string - the exports imported by the template
+func (f *Fake) outputs(st *fakeStack, cs *changeSet) ([]types.Output, []string) {
+	r := f.resolver(st, cs.parameters, cs.template)
+	section := cs.template.section("Outputs")
+	outputs := make([]types.Output, 0, len(section))
+	for _, key := range sortedKeys(section) {
+		definition, _ := section[key].(map[string]interface{})
+		output := types.Output{
+			OutputKey:   aws.String(key),
+			OutputValue: aws.String(fmt.Sprint(r.resolve(definition["Value"]))),
+		}
+		if description, ok := definition["Description"].(string); ok {
+			output.Description = aws.String(description)
+		}
+		if exp, ok := definition["Export"].(map[string]interface{}); ok {
+			output.ExportName = aws.String(fmt.Sprint(r.resolve(exp["Name"])))
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, cs.template.imports(r)
+}
+
+// DescribeStacks describes a stack, or all the stacks if no name is given
+func (f *Fake) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DescribeStacks"); err != nil {
+		return nil, err
+	}
+
+	names := f.sortedStacks()
+	if params.StackName != nil {
+		st, err := f.stack(aws.ToString(params.StackName))
+		if err != nil {
+			return nil, err
+		}
+		names = []string{st.name}
+	}
+
+	out := &cloudformation.DescribeStacksOutput{}
+	for _, name := range names {
+		st := f.stacks[name]
+		created := st.created
+		desc := types.Stack{
+			StackId:                     aws.String(st.id),
+			StackName:                   aws.String(st.name),
+			StackStatus:                 st.status,
+			CreationTime:                &created,
+			LastUpdatedTime:             st.updated,
+			Outputs:                     append([]types.Output(nil), st.outputs...),
+			Tags:                        st.tags,
+			EnableTerminationProtection: aws.Bool(st.protected),
+			DriftInformation: &types.StackDriftInformation{
+				StackDriftStatus:   types.StackDriftStatusNotChecked,
+				LastCheckTimestamp: st.driftCheck,
+			},
+		}
+		if st.drift != "" {
+			desc.DriftInformation.StackDriftStatus = st.drift
+		}
+		if st.reason != "" {
+			desc.StackStatusReason = aws.String(st.reason)
+		}
+		for _, key := range sortedStrings(st.parameters) {
+			desc.Parameters = append(desc.Parameters, types.Parameter{ParameterKey: aws.String(key), ParameterValue: aws.String(st.parameters[key])})
+		}
+		out.Stacks = append(out.Stacks, desc)
+	}
+	return out, nil
+}
+
+// DescribeStackEvents returns the events of a stack, newest first
+func (f *Fake) DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DescribeStackEvents"); err != nil {
+		return nil, err
+	}
+
+	st, err := f.stack(aws.ToString(params.StackName))
+	if err != nil {
+		return nil, err
+	}
+	events := make([]types.StackEvent, 0, len(st.events))
+	for i := len(st.events) - 1; i >= 0; i-- {
+		events = append(events, st.events[i])
+	}
+	return &cloudformation.DescribeStackEventsOutput{StackEvents: events}, nil
+}
+
+// DescribeStackResource describes a resource of a stack
+func (f *Fake) DescribeStackResource(ctx context.Context, params *cloudformation.DescribeStackResourceInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DescribeStackResource"); err != nil {
+		return nil, err
+	}
+
+	st, err := f.stack(aws.ToString(params.StackName))
+	if err != nil {
+		return nil, err
+	}
+	res, ok := st.resources[aws.ToString(params.LogicalResourceId)]
+	if !ok {
+		return nil, apiError("ValidationError", "Resource %s does not exist for stack %s", aws.ToString(params.LogicalResourceId), st.name)
+	}
+	return &cloudformation.DescribeStackResourceOutput{
+		StackResourceDetail: &types.StackResourceDetail{
+			LogicalResourceId:  aws.String(res.logicalId),
+			PhysicalResourceId: aws.String(res.physicalId),
+			ResourceType:       aws.String(res.resourceType),
+			StackId:            aws.String(st.id),
+			StackName:          aws.String(st.name),
+		},
+	}, nil
+}
+
+// DeleteStack starts the deletion of a stack; deleting a stack that does not exist succeeds
+func (f *Fake) DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DeleteStack"); err != nil {
+		return nil, err
+	}
+
+	st, err := f.stack(aws.ToString(params.StackName))
+	if err != nil {
+		return &cloudformation.DeleteStackOutput{}, nil
+	}
+	if st.protected {
+		return nil, apiError("ValidationError", "Stack [%s] cannot be deleted while TerminationProtection is enabled", st.name)
+	}
+	if st.pending != nil {
+		return nil, apiError("ValidationError", "Stack [%s] cannot be deleted while in status %s", st.name, st.status)
+	}
+
+	token := aws.ToString(params.ClientRequestToken)
+	f.start(st, types.StackStatusDeleteInProgress, token, func() {
+		for _, name := range f.removedExports(st, nil) {
+			if users := f.importers(name); len(users) > 0 {
+				st.status = types.StackStatusDeleteFailed
+				st.reason = fmt.Sprintf("Export %s cannot be deleted as it is in use by %s", name, strings.Join(users, ", "))
+				f.event(st, st.name, st.id, "AWS::CloudFormation::Stack", string(st.status), st.reason, token)
+				return
+			}
+		}
+		for _, name := range sortedResources(st.resources) {
+			res := st.resources[name]
+			if reason, ok := f.faults[st.name+"/"+name]; ok {
+				delete(f.faults, st.name+"/"+name)
+				f.event(st, name, res.physicalId, res.resourceType, string(types.ResourceStatusDeleteFailed), reason, token)
+				st.status = types.StackStatusDeleteFailed
+				st.reason = fmt.Sprintf("The following resource(s) failed to delete: [%s]. ", name)
+				f.event(st, st.name, st.id, "AWS::CloudFormation::Stack", string(st.status), st.reason, token)
+				return
+			}
+			f.event(st, name, res.physicalId, res.resourceType, string(types.ResourceStatusDeleteComplete), "", token)
+		}
+		for _, name := range f.removedExports(st, nil) {
+			delete(f.exports, name)
+		}
+		delete(f.stacks, st.name)
+	})
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+
+// sortedResources returns the names of the resources, sorted
+func sortedResources(resources map[string]*resource) []string {
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ListImports returns the stacks importing an export
+func (f *Fake) ListImports(ctx context.Context, params *cloudformation.ListImportsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("ListImports"); err != nil {
+		return nil, err
+	}
+
+	name := aws.ToString(params.ExportName)
+	users := f.importers(name)
+	if len(users) == 0 {
+		return nil, apiError("ValidationError", "Export '%s' is not imported by any stack.", name)
+	}
+	return &cloudformation.ListImportsOutput{Imports: users}, nil
+}
+
+// ContinueUpdateRollback completes a rollback that failed
+func (f *Fake) ContinueUpdateRollback(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("ContinueUpdateRollback"); err != nil {
+		return nil, err
+	}
+
+	st, err := f.stack(aws.ToString(params.StackName))
+	if err != nil {
+		return nil, err
+	}
+	if st.status != types.StackStatusUpdateRollbackFailed {
+		return nil, apiError("ValidationError", "Stack %s is in %s state and can not continue the rollback", st.name, st.status)
+	}
+	f.start(st, types.StackStatusUpdateRollbackInProgress, aws.ToString(params.ClientRequestToken), func() {
+		st.status = types.StackStatusUpdateRollbackComplete
+		f.event(st, st.name, st.id, "AWS::CloudFormation::Stack", string(st.status), "", aws.ToString(params.ClientRequestToken))
+	})
+	return &cloudformation.ContinueUpdateRollbackOutput{}, nil
+}
+
+// DetectStackDrift starts a drift detection; it completes with the drifts set with SetDrift
+func (f *Fake) DetectStackDrift(ctx context.Context, params *cloudformation.DetectStackDriftInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DetectStackDriftOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DetectStackDrift"); err != nil {
+		return nil, err
+	}
+
+	st, err := f.stack(aws.ToString(params.StackName))
+	if err != nil {
+		return nil, err
+	}
+	if st.pending != nil || st.status == types.StackStatusReviewInProgress {
+		return nil, apiError("ValidationError", "Drift detection is not supported for stack %s in %s", st.name, st.status)
+	}
+
+	id := fmt.Sprintf("drift-%d", f.nextId())
+	f.detections[id] = st.name
+	now := f.clock.Now()
+	st.driftCheck = &now
+	st.drift = types.StackDriftStatusInSync
+	if len(f.drifts[st.name]) > 0 {
+		st.drift = types.StackDriftStatusDrifted
+	}
+	return &cloudformation.DetectStackDriftOutput{StackDriftDetectionId: aws.String(id)}, nil
+}
+
+// DescribeStackDriftDetectionStatus reports a drift detection as complete
+func (f *Fake) DescribeStackDriftDetectionStatus(ctx context.Context, params *cloudformation.DescribeStackDriftDetectionStatusInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DescribeStackDriftDetectionStatus"); err != nil {
+		return nil, err
+	}
+
+	id := aws.ToString(params.StackDriftDetectionId)
+	name, ok := f.detections[id]
+	if !ok {
+		return nil, apiError("ValidationError", "Drift detection %s does not exist", id)
+	}
+	st, err := f.stack(name)
+	if err != nil {
+		return nil, err
+	}
+	return &cloudformation.DescribeStackDriftDetectionStatusOutput{
+		StackDriftDetectionId: aws.String(id),
+		StackId:               aws.String(st.id),
+		DetectionStatus:       types.StackDriftDetectionStatusDetectionComplete,
+		StackDriftStatus:      st.drift,
+		Timestamp:             st.driftCheck,
+	}, nil
+}
+
+// DescribeStackResourceDrifts returns the drift of the resources of a stack
+func (f *Fake) DescribeStackResourceDrifts(ctx context.Context, params *cloudformation.DescribeStackResourceDriftsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceDriftsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("DescribeStackResourceDrifts"); err != nil {
+		return nil, err
+	}
+
+	st, err := f.stack(aws.ToString(params.StackName))
+	if err != nil {
+		return nil, err
+	}
+	filter := make(map[types.StackResourceDriftStatus]bool)
+	for _, status := range params.StackResourceDriftStatusFilters {
+		filter[status] = true
+	}
+
+	drifts := make([]types.StackResourceDrift, 0)
+	for _, name := range sortedResources(st.resources) {
+		res := st.resources[name]
+		status := types.StackResourceDriftStatusInSync
+		differences, drifted := f.drifts[st.name][name]
+		switch {
+		case drifted && len(differences) == 0:
+			status = types.StackResourceDriftStatusDeleted
+		case drifted:
+			status = types.StackResourceDriftStatusModified
+		}
+		if len(filter) > 0 && !filter[status] {
+			continue
+		}
+		drifts = append(drifts, types.StackResourceDrift{
+			StackId:                  aws.String(st.id),
+			LogicalResourceId:        aws.String(name),
+			PhysicalResourceId:       aws.String(res.physicalId),
+			ResourceType:             aws.String(res.resourceType),
+			StackResourceDriftStatus: status,
+			PropertyDifferences:      differences,
+			Timestamp:                st.driftCheck,
+		})
+	}
+	return &cloudformation.DescribeStackResourceDriftsOutput{StackResourceDrifts: drifts}, nil
+}
+
+// UpdateTerminationProtection enables or disables the termination protection of a stack
+func (f *Fake) UpdateTerminationProtection(ctx context.Context, params *cloudformation.UpdateTerminationProtectionInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateTerminationProtectionOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("UpdateTerminationProtection"); err != nil {
+		return nil, err
+	}
+
+	st, err := f.stack(aws.ToString(params.StackName))
+	if err != nil {
+		return nil, err
+	}
+	st.protected = aws.ToBool(params.EnableTerminationProtection)
+	return &cloudformation.UpdateTerminationProtectionOutput{StackId: aws.String(st.id)}, nil
+}
+
+// SetStackPolicy sets the stack policy checked by the next updates of a stack
+func (f *Fake) SetStackPolicy(ctx context.Context, params *cloudformation.SetStackPolicyInput, optFns ...func(*cloudformation.Options)) (*cloudformation.SetStackPolicyOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call("SetStackPolicy"); err != nil {
+		return nil, err
+	}
+
+	st, err := f.stack(aws.ToString(params.StackName))
+	if err != nil {
+		return nil, err
+	}
+	st.policy = aws.ToString(params.StackPolicyBody)
+	return &cloudformation.SetStackPolicyOutput{}, nil
+}
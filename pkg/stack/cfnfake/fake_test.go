@@ -0,0 +1,183 @@
+package cfnfake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+const producerTemplate = `{
+  "Parameters": {"BucketName": {"Type": "String"}},
+  "Resources": {"bucket": {"Type": "AWS::S3::Bucket", "Properties": {"BucketName": {"Ref": "BucketName"}}}},
+  "Outputs": {"Arn": {"Value": {"Fn::GetAtt": ["bucket", "Arn"]}, "Export": {"Name": "BucketArn"}}}
+}`
+
+const consumerTemplate = `{
+  "Resources": {"topic": {"Type": "AWS::SNS::Topic", "Properties": {"DisplayName": {"Fn::ImportValue": "BucketArn"}}}}
+}`
+
+// deploy creates or updates a stack with a change set and returns the status of the change set
+func deploy(t *testing.T, f *Fake, name string, csType types.ChangeSetType, body string, params map[string]string) *cloudformation.DescribeChangeSetOutput {
+	t.Helper()
+	ctx := context.Background()
+
+	parameters := make([]types.Parameter, 0, len(params))
+	for key, value := range params {
+		parameters = append(parameters, types.Parameter{ParameterKey: aws.String(key), ParameterValue: aws.String(value)})
+	}
+	csName := fmt.Sprintf("%s-%s-%d", name, csType, f.Calls("CreateChangeSet"))
+	if _, err := f.CreateChangeSet(ctx, &cloudformation.CreateChangeSetInput{
+		StackName:     &name,
+		ChangeSetName: &csName,
+		ChangeSetType: csType,
+		TemplateBody:  &body,
+		Parameters:    parameters,
+	}); err != nil {
+		t.Fatalf("CreateChangeSet on %s: %v", name, err)
+	}
+	desc, err := f.DescribeChangeSet(ctx, &cloudformation.DescribeChangeSetInput{StackName: &name, ChangeSetName: &csName})
+	if err != nil {
+		t.Fatalf("DescribeChangeSet on %s: %v", name, err)
+	}
+	if desc.Status != types.ChangeSetStatusCreateComplete {
+		return desc
+	}
+	if _, err := f.ExecuteChangeSet(ctx, &cloudformation.ExecuteChangeSetInput{StackName: &name, ChangeSetName: &csName, ClientRequestToken: &csName}); err != nil {
+		t.Fatalf("ExecuteChangeSet on %s: %v", name, err)
+	}
+	return desc
+}
+
+// status returns the status of a stack
+func status(t *testing.T, f *Fake, name string) types.Stack {
+	t.Helper()
+	out, err := f.DescribeStacks(context.Background(), &cloudformation.DescribeStacksInput{StackName: &name})
+	if err != nil {
+		t.Fatalf("DescribeStacks on %s: %v", name, err)
+	}
+	return out.Stacks[0]
+}
+
+func TestFake_ChangeSetLifecycle(t *testing.T) {
+	f := New("eu-central-1", nil)
+	f.OperationTime = time.Minute
+
+	desc := deploy(t, f, "producer", types.ChangeSetTypeCreate, producerTemplate, map[string]string{"BucketName": "my-bucket"})
+	if len(desc.Changes) != 1 || desc.Changes[0].ResourceChange.Action != types.ChangeActionAdd {
+		t.Fatalf("Expected the bucket to be added, got %+v", desc.Changes)
+	}
+	if st := status(t, f, "producer"); st.StackStatus != types.StackStatusCreateInProgress {
+		t.Fatalf("Expected CREATE_IN_PROGRESS before the operation time passed, got %s", st.StackStatus)
+	}
+
+	f.Clock().Advance(time.Minute)
+	st := status(t, f, "producer")
+	if st.StackStatus != types.StackStatusCreateComplete {
+		t.Fatalf("Expected CREATE_COMPLETE, got %s", st.StackStatus)
+	}
+	if aws.ToString(st.Outputs[0].OutputValue) != "arn:aws:s3:::my-bucket" {
+		t.Errorf("Expected the ARN of the bucket, got %s", aws.ToString(st.Outputs[0].OutputValue))
+	}
+	if f.Exports()["BucketArn"] != "arn:aws:s3:::my-bucket" {
+		t.Errorf("Expected the export of the ARN, got %v", f.Exports())
+	}
+
+	desc = deploy(t, f, "producer", types.ChangeSetTypeUpdate, producerTemplate, map[string]string{"BucketName": "my-bucket"})
+	if desc.Status != types.ChangeSetStatusFailed || aws.ToString(desc.StatusReason) != EmptyChangeSet {
+		t.Errorf("Expected an empty change set, got %s: %s", desc.Status, aws.ToString(desc.StatusReason))
+	}
+
+	desc = deploy(t, f, "producer", types.ChangeSetTypeUpdate, producerTemplate, map[string]string{"BucketName": "other-bucket"})
+	change := desc.Changes[0].ResourceChange
+	if change.Action != types.ChangeActionModify || change.Replacement != types.ReplacementTrue {
+		t.Errorf("Expected the bucket to be replaced, got %s %s", change.Action, change.Replacement)
+	}
+}
+
+func TestFake_Exports(t *testing.T) {
+	f := New("eu-central-1", nil)
+
+	desc := deploy(t, f, "consumer", types.ChangeSetTypeCreate, consumerTemplate, nil)
+	if desc.Status != types.ChangeSetStatusFailed || !strings.Contains(aws.ToString(desc.StatusReason), "No export named BucketArn") {
+		t.Fatalf("Expected the change set to fail on the missing export, got %s: %s", desc.Status, aws.ToString(desc.StatusReason))
+	}
+
+	deploy(t, f, "producer", types.ChangeSetTypeCreate, producerTemplate, map[string]string{"BucketName": "my-bucket"})
+	if _, err := f.DeleteChangeSet(context.Background(), &cloudformation.DeleteChangeSetInput{
+		StackName: aws.String("consumer"), ChangeSetName: aws.String("consumer-CREATE-0"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	deploy(t, f, "consumer", types.ChangeSetTypeCreate, consumerTemplate, nil)
+	if st := status(t, f, "consumer"); st.StackStatus != types.StackStatusCreateComplete {
+		t.Fatalf("Expected CREATE_COMPLETE, got %s", st.StackStatus)
+	}
+
+	imports, err := f.ListImports(context.Background(), &cloudformation.ListImportsInput{ExportName: aws.String("BucketArn")})
+	if err != nil || len(imports.Imports) != 1 || imports.Imports[0] != "consumer" {
+		t.Fatalf("Expected the consumer to import the ARN, got %v, %v", imports, err)
+	}
+
+	if _, err := f.DeleteStack(context.Background(), &cloudformation.DeleteStackInput{StackName: aws.String("producer")}); err != nil {
+		t.Fatal(err)
+	}
+	st := status(t, f, "producer")
+	if st.StackStatus != types.StackStatusDeleteFailed || !strings.Contains(aws.ToString(st.StackStatusReason), "in use by consumer") {
+		t.Errorf("Expected the deletion to fail while the export is in use, got %s: %s", st.StackStatus, aws.ToString(st.StackStatusReason))
+	}
+}
+
+func TestFake_Faults(t *testing.T) {
+	f := New("eu-central-1", nil)
+
+	f.FailResource("producer", "bucket", "my-bucket already exists")
+	deploy(t, f, "producer", types.ChangeSetTypeCreate, producerTemplate, map[string]string{"BucketName": "my-bucket"})
+	st := status(t, f, "producer")
+	if st.StackStatus != types.StackStatusRollbackComplete {
+		t.Fatalf("Expected ROLLBACK_COMPLETE, got %s", st.StackStatus)
+	}
+
+	events, err := f.DescribeStackEvents(context.Background(), &cloudformation.DescribeStackEventsInput{StackName: aws.String("producer")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := false
+	for _, ev := range events.StackEvents {
+		if aws.ToString(ev.LogicalResourceId) == "bucket" && ev.ResourceStatus == types.ResourceStatusCreateFailed {
+			failed = aws.ToString(ev.ClientRequestToken) == "producer-CREATE-0"
+		}
+	}
+	if !failed {
+		t.Error("Expected a CREATE_FAILED event of the bucket with the token of the change set")
+	}
+
+	f.FailCall("DescribeStacks", apiError("Throttling", "Rate exceeded"))
+	if _, err := f.DescribeStacks(context.Background(), &cloudformation.DescribeStacksInput{}); err == nil {
+		t.Error("Expected the injected error")
+	}
+	if _, err := f.DescribeStacks(context.Background(), &cloudformation.DescribeStacksInput{}); err != nil {
+		t.Errorf("Expected the fault to be injected once, got %v", err)
+	}
+}
+
+func TestFake_Capabilities(t *testing.T) {
+	f := New("eu-central-1", nil)
+	body := `{"Resources": {"user": {"Type": "AWS::IAM::User", "Properties": {"UserName": "deployer"}}}}`
+
+	_, err := f.CreateChangeSet(context.Background(), &cloudformation.CreateChangeSetInput{
+		StackName:     aws.String("user"),
+		ChangeSetName: aws.String("cs"),
+		ChangeSetType: types.ChangeSetTypeCreate,
+		TemplateBody:  &body,
+		Capabilities:  []types.Capability{types.CapabilityCapabilityIam},
+	})
+	if err == nil || !strings.Contains(err.Error(), "CAPABILITY_NAMED_IAM") {
+		t.Errorf("Expected a named IAM user to require CAPABILITY_NAMED_IAM, got %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package cfnfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Account is the account id of the fake
+const Account = "123456789012"
+
+// replacementProperties are the properties whose change replaces a resource, by resource type
+var replacementProperties = map[string][]string{
+	"AWS::S3::Bucket":                      {"BucketName"},
+	"AWS::IAM::User":                       {"UserName"},
+	"AWS::CertificateManager::Certificate": {"DomainName", "SubjectAlternativeNames", "DomainValidationOptions", "ValidationMethod"},
+	"AWS::Route53::RecordSet":              {"Name", "HostedZoneId", "Type"},
+}
+
+// subVariable matches the variables of a Fn::Sub string
+var subVariable = regexp.MustCompile(`\$\{([^!}][^}]*)\}`)
+
+// template is a parsed CloudFormation template
+type template map[string]interface{}
+
+// parseTemplate parses a JSON template
+// param: body - the template body
+// return: template - the template
+// return: error - the error if the body is not a JSON template
+func parseTemplate(body string) (template, error) {
+	tpl := make(template)
+	if err := json.Unmarshal([]byte(body), &tpl); err != nil {
+		return nil, fmt.Errorf("Template format error: %s", err)
+	}
+	if _, ok := tpl["Resources"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("Template format error: At least one Resources member must be defined.")
+	}
+	return tpl, nil
+}
+
+// section returns a section of the template (Parameters, Resources or Outputs)
+func (t template) section(name string) map[string]interface{} {
+	if s, ok := t[name].(map[string]interface{}); ok {
+		return s
+	}
+	return map[string]interface{}{}
+}
+
+// resource returns the definition of a resource
+func (t template) resource(name string) map[string]interface{} {
+	if r, ok := t.section("Resources")[name].(map[string]interface{}); ok {
+		return r
+	}
+	return map[string]interface{}{}
+}
+
+// resourceType returns the type of a resource
+func (t template) resourceType(name string) string {
+	s, _ := t.resource(name)["Type"].(string)
+	return s
+}
+
+// resourceNames returns the names of the resources, sorted
+func (t template) resourceNames() []string {
+	return sortedKeys(t.section("Resources"))
+}
+
+// imports returns the export names imported by the template (with their names resolved)
+// param: r - the resolver of the names of the exports
+// return: []string - the export names, sorted
+func (t template) imports(r *resolver) []string {
+	found := make(map[string]interface{})
+	walk(t["Resources"], func(key string, value interface{}) {
+		if key == "Fn::ImportValue" {
+			found[fmt.Sprint(r.resolve(value))] = true
+		}
+	})
+	walk(t["Outputs"], func(key string, value interface{}) {
+		if key == "Fn::ImportValue" {
+			found[fmt.Sprint(r.resolve(value))] = true
+		}
+	})
+	return sortedKeys(found)
+}
+
+// walk calls fn for every key of every object in a value
+func walk(value interface{}, fn func(key string, value interface{})) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			fn(key, item)
+			walk(item, fn)
+		}
+	case []interface{}:
+		for _, item := range v {
+			walk(item, fn)
+		}
+	}
+}
+
+// refersTo returns true if a value references a parameter or a resource with Ref or Fn::Sub
+func refersTo(value interface{}, name string) bool {
+	found := false
+	walk(value, func(key string, item interface{}) {
+		switch key {
+		case "Ref":
+			found = found || item == name
+		case "Fn::Sub":
+			found = found || strings.Contains(fmt.Sprint(item), "${"+name+"}")
+		}
+	})
+	return found
+}
+
+// resolver evaluates the intrinsic functions of a template
+type resolver struct {
+	stack      string
+	region     string
+	parameters map[string]string
+	physical   map[string]string
+	types      map[string]string
+	exports    map[string]string
+}
+
+// resolve evaluates the intrinsic functions of a value
+// param: value - the value, as parsed from the template
+// return: interface{} - the value with the functions evaluated
+func (r *resolver) resolve(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 1 {
+			for key, arg := range v {
+				if result, ok := r.function(key, arg); ok {
+					return result
+				}
+			}
+		}
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = r.resolve(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = r.resolve(item)
+		}
+		return out
+	}
+	return value
+}
+
+// function evaluates one intrinsic function
+// return: interface{} - the result
+// return: bool - false if the key is not a supported function
+func (r *resolver) function(name string, arg interface{}) (interface{}, bool) {
+	switch name {
+	case "Ref":
+		return r.ref(fmt.Sprint(arg)), true
+	case "Fn::GetAtt":
+		parts := make([]string, 0, 2)
+		if list, ok := arg.([]interface{}); ok {
+			for _, p := range list {
+				parts = append(parts, fmt.Sprint(r.resolve(p)))
+			}
+		} else {
+			parts = strings.SplitN(fmt.Sprint(arg), ".", 2)
+		}
+		if len(parts) != 2 {
+			return "", true
+		}
+		return r.attribute(parts[0], parts[1]), true
+	case "Fn::Sub":
+		str := fmt.Sprint(arg)
+		vars := map[string]interface{}{}
+		if list, ok := arg.([]interface{}); ok && len(list) > 0 {
+			str = fmt.Sprint(list[0])
+			if len(list) > 1 {
+				vars, _ = list[1].(map[string]interface{})
+			}
+		}
+		return subVariable.ReplaceAllStringFunc(str, func(match string) string {
+			variable := match[2 : len(match)-1]
+			if value, ok := vars[variable]; ok {
+				return fmt.Sprint(r.resolve(value))
+			}
+			if parts := strings.SplitN(variable, ".", 2); len(parts) == 2 {
+				return r.attribute(parts[0], parts[1])
+			}
+			return r.ref(variable)
+		}), true
+	case "Fn::Join":
+		list, ok := arg.([]interface{})
+		if !ok || len(list) != 2 {
+			return "", true
+		}
+		items, _ := r.resolve(list[1]).([]interface{})
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, fmt.Sprint(list[0])), true
+	case "Fn::ImportValue":
+		return r.exports[fmt.Sprint(r.resolve(arg))], true
+	}
+	return nil, false
+}
+
+// ref evaluates a Ref to a parameter, a resource or a pseudo parameter
+func (r *resolver) ref(name string) string {
+	if value, ok := r.parameters[name]; ok {
+		return value
+	}
+	if value, ok := r.physical[name]; ok {
+		return value
+	}
+	switch name {
+	case "AWS::Region":
+		return r.region
+	case "AWS::AccountId":
+		return Account
+	case "AWS::StackName":
+		return r.stack
+	case "AWS::Partition":
+		return "aws"
+	case "AWS::URLSuffix":
+		return "amazonaws.com"
+	}
+	return ""
+}
+
+// attribute evaluates a Fn::GetAtt
+func (r *resolver) attribute(resource string, attribute string) string {
+	id := r.physical[resource]
+	switch r.types[resource] + "." + attribute {
+	case "AWS::S3::Bucket.Arn":
+		return "arn:aws:s3:::" + id
+	case "AWS::S3::Bucket.DomainName":
+		return id + ".s3.amazonaws.com"
+	case "AWS::S3::Bucket.RegionalDomainName":
+		return fmt.Sprintf("%s.s3.%s.amazonaws.com", id, r.region)
+	case "AWS::CloudFront::Distribution.DomainName":
+		return strings.ToLower(id) + ".cloudfront.net"
+	case "AWS::CloudFront::Distribution.Arn":
+		return fmt.Sprintf("arn:aws:cloudfront::%s:distribution/%s", Account, id)
+	case "AWS::CloudFront::OriginAccessControl.Id":
+		return id
+	case "AWS::CloudFront::Function.FunctionARN":
+		return fmt.Sprintf("arn:aws:cloudfront::%s:function/%s", Account, id)
+	case "AWS::IAM::User.Arn":
+		return fmt.Sprintf("arn:aws:iam::%s:user/%s", Account, id)
+	case "AWS::IAM::AccessKey.SecretAccessKey":
+		return "secret-" + id
+	}
+	return id + "." + attribute
+}
+
+// changedProperties returns the properties of a resource that differ between two versions of a template
+// A property referencing a parameter whose value changed is changed as well
+// param: before, after - the resource definitions
+// param: changedParameters - the parameters whose value changed
+// return: []string - the changed properties, sorted
+func changedProperties(before map[string]interface{}, after map[string]interface{}, changedParameters []string) []string {
+	oldProps, _ := before["Properties"].(map[string]interface{})
+	newProps, _ := after["Properties"].(map[string]interface{})
+
+	names := make(map[string]interface{})
+	for name := range oldProps {
+		names[name] = true
+	}
+	for name := range newProps {
+		names[name] = true
+	}
+
+	changed := make([]string, 0)
+	for _, name := range sortedKeys(names) {
+		if !reflect.DeepEqual(oldProps[name], newProps[name]) {
+			changed = append(changed, name)
+			continue
+		}
+		for _, param := range changedParameters {
+			if refersTo(newProps[name], param) {
+				changed = append(changed, name)
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// sortedKeys returns the keys of a map, sorted
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -2,10 +2,12 @@ package stack
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 	"context"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	"github.com/dragosboca/haws/pkg/logger"
@@ -14,13 +16,6 @@ import (
 
 const EmptyChangeSet = "The submitted information didn't contain changes. Submit different information to create a change set."
 
-func (st *Stack) stackExist(ctx context.Context) bool {
-	_, err := st.cloudFormationClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
-		StackName: st.GetStackName(),
-	})
-	return err == nil
-}
-
 // templateJson returns the template as a JSON string
 // return: string - the template as a JSON string
 // return: error - the error if any
@@ -34,25 +29,46 @@ func (st *Stack) templateJson() (string, error) {
 	return string(templateBody), nil
 }
 
+// changeSetName returns a random name for a changeset
+// The generated names are few, so the seed is appended: the executed change sets keep their name in the stack
+// return: string - the name of the changeset
+func changeSetName() string {
+	seed := time.Now().UTC().UnixNano()
+	nameGenerator := namegenerator.NewNameGenerator(seed)
+
+	return fmt.Sprintf("%s-%s", nameGenerator.Generate(), strconv.FormatInt(seed, 36))
+}
+
 // initialChangeSet creates the initial changeset
 // param: templateBody - the template body
+// param: exists - true if the stack exists and must be updated
 // return: csName - the name of the changeset
 // return: csType - the type of the changeset (CREATE or UPDATE)
 // return: error - the error if any
-func (st *Stack) initialChangeSet(ctx context.Context, templateBody string) (string, string, error) {
-	seed := time.Now().UTC().UnixNano()
-	nameGenerator := namegenerator.NewNameGenerator(seed)
-
-	csName := nameGenerator.Generate()
+func (st *Stack) initialChangeSet(ctx context.Context, templateBody string, exists bool) (string, string, error) {
+	csName := changeSetName()
 
 	csType := "CREATE"
-	if st.stackExist(ctx) {
+	if exists {
 		csType = "UPDATE"
 		logger.Info("Updating stack: %s with changeset: %s", *st.GetStackName(), csName)
 	} else {
 		logger.Info("Creating stack: %s with changeset: %s", *st.GetStackName(), csName)
 	}
 
+	if err := st.submitChangeSet(ctx, csName, csType, templateBody, nil); err != nil {
+		return "", "", err
+	}
+	return csName, csType, nil
+}
+
+// submitChangeSet creates a changeset with the parameters, tags and capabilities of the stack
+// param: csName - the name of the changeset
+// param: csType - the type of the changeset (CREATE, UPDATE or IMPORT)
+// param: templateBody - the template body
+// param: imports - the resources to import (IMPORT only)
+// return: error - the error if any
+func (st *Stack) submitChangeSet(ctx context.Context, csName string, csType string, templateBody string, imports []types.ResourceToImport) error {
 	// Convert CloudFormation v1 Parameters to v2 Parameters
 	v2Params := make([]types.Parameter, 0, len(st.GetParameters()))
 	for _, param := range st.GetParameters() {
@@ -61,19 +77,26 @@ func (st *Stack) initialChangeSet(ctx context.Context, templateBody string) (str
 			ParameterValue: param.ParameterValue,
 		})
 	}
-	
-	_, err := st.cloudFormationClient.CreateChangeSet(ctx, &cloudformation.CreateChangeSetInput{
-		ClientToken:   &csName,
-		ChangeSetName: &csName,
-		ChangeSetType: types.ChangeSetType(csType),
-		Parameters:    v2Params,
-		StackName:     st.GetStackName(),
-		TemplateBody:  &templateBody,
-	})
+
+	body, url, err := st.templateSource(ctx, templateBody)
 	if err != nil {
-		return "", "", err
+		return err
 	}
-	return csName, csType, nil
+
+	_, err = st.cloudFormationClient.CreateChangeSet(ctx, &cloudformation.CreateChangeSetInput{
+		ClientToken:       &csName,
+		ChangeSetName:     &csName,
+		ChangeSetType:     types.ChangeSetType(csType),
+		Parameters:        v2Params,
+		StackName:         st.GetStackName(),
+		TemplateBody:      body,
+		TemplateURL:       url,
+		Tags:              st.stackTags(),
+		Capabilities:      st.GetCapabilities(),
+		RoleARN:           st.role(),
+		ResourcesToImport: imports,
+	})
+	return err
 }
 
 // waitForChangeSet returns true if the changeset is empty and should be deleted
@@ -82,28 +105,24 @@ func (st *Stack) initialChangeSet(ctx context.Context, templateBody string) (str
 // return: error - the error if any
 func (st *Stack) waitForChangeSet(ctx context.Context, csName string) (bool, error) {
 	logger.Info("Waiting for the changeset %s creation to complete", csName)
-	
-	// AWS SDK v2 doesn't have built-in waiters, so we'll implement a simple polling mechanism
-	maxAttempts := 30
-	delay := time.Second * 2
-	
-	for i := 0; i < maxAttempts; i++ {
+
+	empty := false
+	err := st.newWaiter(changeSetTimeout).Wait(ctx, "change set creation", func(ctx context.Context) (bool, error) {
 		desc, err := st.cloudFormationClient.DescribeChangeSet(ctx, &cloudformation.DescribeChangeSetInput{
 			ChangeSetName: &csName,
 			StackName:     st.GetStackName(),
 		})
-		
 		if err != nil {
 			return false, err
 		}
-		
+
 		// Check if the change set is ready
 		if desc.Status == types.ChangeSetStatusCreateComplete {
-			return false, nil
+			return true, nil
 		}
-		
+
 		// Check if the change set failed because it's empty
-		if desc.Status == types.ChangeSetStatusFailed && *desc.StatusReason == EmptyChangeSet {
+		if desc.Status == types.ChangeSetStatusFailed && aws.ToString(desc.StatusReason) == EmptyChangeSet {
 			logger.Info("Deleting empty changeset %s", csName)
 			_, err := st.cloudFormationClient.DeleteChangeSet(ctx, &cloudformation.DeleteChangeSetInput{
 				ChangeSetName: &csName,
@@ -112,25 +131,28 @@ func (st *Stack) waitForChangeSet(ctx context.Context, csName string) (bool, err
 			if err != nil {
 				return false, err
 			}
+			empty = true
 			return true, nil
 		} else if desc.Status == types.ChangeSetStatusFailed {
 			// Failed for some other reason
-			return false, fmt.Errorf("change set creation failed: %s", *desc.StatusReason)
+			return false, &ChangeSetFailedError{
+				Stack:     *st.GetStackName(),
+				ChangeSet: csName,
+				Reason:    aws.ToString(desc.StatusReason),
+			}
 		}
-		
-		// Wait before checking again
-		time.Sleep(delay)
-	}
-	
-	return false, fmt.Errorf("timed out waiting for change set creation to complete")
+		return false, nil
+	})
+	return empty, err
 }
 
 // executeChangeSet executes the changeset
 // param: csName - the name of the changeset
-// param: csType - the type of the changeset (CREATE or UPDATE)
+// param: csType - the type of the changeset (CREATE, UPDATE or IMPORT)
 // return: error - the error if any
 func (st *Stack) executeChangeSet(ctx context.Context, csName string, csType string) error {
 	logger.Info("Executing change set: %s on stack %s", csName, *st.GetStackName())
+	events := st.newEventStream(csName, time.Now().Add(-time.Minute))
 	_, err := st.cloudFormationClient.ExecuteChangeSet(ctx, &cloudformation.ExecuteChangeSetInput{
 		ChangeSetName:      &csName,
 		ClientRequestToken: &csName,
@@ -141,45 +163,45 @@ func (st *Stack) executeChangeSet(ctx context.Context, csName string, csType str
 	}
 
 	logger.Info("Waiting for the changeset %s execution to complete", csName)
-	
-	// Implementation of waiter using polling since SDK v2 doesn't have built-in waiters
-	maxAttempts := 120 // Cloud formation stacks can take a while
-	delay := time.Second * 5
-	
+
 	targetStatus := ""
-	if csType == "CREATE" {
+	switch csType {
+	case "CREATE":
 		targetStatus = string(types.StackStatusCreateComplete)
-	} else {
+	case "IMPORT":
+		targetStatus = string(types.StackStatusImportComplete)
+	default:
 		targetStatus = string(types.StackStatusUpdateComplete)
 	}
-	
-	for i := 0; i < maxAttempts; i++ {
+
+	return st.newWaiter(st.timeout()).Wait(ctx, "stack operation", func(ctx context.Context) (bool, error) {
 		resp, err := st.cloudFormationClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
 			StackName: st.GetStackName(),
 		})
-		
 		if err != nil {
-			return err
+			return false, err
 		}
-		
+
 		if len(resp.Stacks) == 0 {
-			return fmt.Errorf("stack not found")
+			return false, fmt.Errorf("stack not found")
 		}
-		
+
 		stackStatus := string(resp.Stacks[0].StackStatus)
-		
+
+		if err := events.poll(ctx); err != nil {
+			logger.Debug("Unable to read events for stack %s: %s", *st.GetStackName(), err)
+		}
+
 		if stackStatus == targetStatus {
-			return nil
+			return true, nil
 		}
-		
+
 		// Check for failure states
-		if strings.HasSuffix(stackStatus, "FAILED") || 
-		   strings.HasSuffix(stackStatus, "ROLLBACK_COMPLETE") {
-			return fmt.Errorf("stack operation failed: %s", stackStatus)
+		if strings.HasSuffix(stackStatus, "FAILED") ||
+			strings.HasSuffix(stackStatus, "ROLLBACK_COMPLETE") {
+			return false, newStackFailedError(*st.GetStackName(), stackStatus,
+				aws.ToString(resp.Stacks[0].StackStatusReason), events.rootCauses())
 		}
-		
-		time.Sleep(delay)
-	}
-	
-	return fmt.Errorf("timed out waiting for stack operation to complete")
+		return false, nil
+	})
 }
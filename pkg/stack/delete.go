@@ -0,0 +1,179 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// notExist returns true if the error reports a stack or an import that does not exist
+func notExist(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "does not exist")
+}
+
+// notImported returns true if the error reports an export that is not imported by any stack
+func notImported(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "is not imported by any stack")
+}
+
+// Exists checks if the stack was created
+// return: bool - true if the stack exists
+// return: error - the error if any
+func (st *Stack) Exists(ctx context.Context) (bool, error) {
+	if err := st.ensureClient(ctx); err != nil {
+		return false, err
+	}
+	desc, err := st.describe(ctx)
+	if err != nil {
+		return false, err
+	}
+	return desc != nil, nil
+}
+
+// Importers returns the names of the stacks that import the exports of this stack
+// return: []string - the names of the importing stacks, sorted
+// return: error - the error if any
+func (st *Stack) Importers(ctx context.Context) ([]string, error) {
+	exportNames := make([]string, 0)
+	for _, output := range st.Build().Outputs {
+		if output.Export == nil || output.Export.Name == "" {
+			continue
+		}
+		exportNames = append(exportNames, output.Export.Name)
+	}
+	return st.ImportersOf(ctx, exportNames...)
+}
+
+// ImportersOf returns the names of the stacks that import some exports
+// param: exportNames - the names of the exports
+// return: []string - the names of the importing stacks, sorted
+// return: error - the error if any
+func (st *Stack) ImportersOf(ctx context.Context, exportNames ...string) ([]string, error) {
+	if err := st.ensureClient(ctx); err != nil {
+		return nil, err
+	}
+
+	importers := make(map[string]bool)
+	for _, exportName := range exportNames {
+		exportName := exportName
+		var nextToken *string
+		for {
+			resp, err := st.cloudFormationClient.ListImports(ctx, &cloudformation.ListImportsInput{
+				ExportName: &exportName,
+				NextToken:  nextToken,
+			})
+			if notImported(err) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range resp.Imports {
+				importers[name] = true
+			}
+			if resp.NextToken == nil {
+				break
+			}
+			nextToken = resp.NextToken
+		}
+	}
+
+	names := make([]string, 0, len(importers))
+	for name := range importers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// PhysicalResourceId returns the physical id of a resource of the stack
+// param: logicalId - the name of the resource in the template
+// return: string - the physical id of the resource
+// return: error - the error if any
+func (st *Stack) PhysicalResourceId(ctx context.Context, logicalId string) (string, error) {
+	if err := st.ensureClient(ctx); err != nil {
+		return "", err
+	}
+	resp, err := st.cloudFormationClient.DescribeStackResource(ctx, &cloudformation.DescribeStackResourceInput{
+		StackName:         st.GetStackName(),
+		LogicalResourceId: &logicalId,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.StackResourceDetail == nil || resp.StackResourceDetail.PhysicalResourceId == nil {
+		return "", fmt.Errorf("resource %s of stack %s has no physical id", logicalId, *st.GetStackName())
+	}
+	return *resp.StackResourceDetail.PhysicalResourceId, nil
+}
+
+// ResourcesOfType returns the names of the resources of the template with the given type
+// param: resourceType - the CloudFormation type of the resources (e.g. AWS::S3::Bucket)
+// return: []string - the names of the resources, sorted
+func (st *Stack) ResourcesOfType(resourceType string) []string {
+	names := make([]string, 0)
+	for name, resource := range st.Build().Resources {
+		if resource.AWSCloudFormationType() == resourceType {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Delete deletes the stack and waits for the deletion to complete
+// return: error - the error if any
+func (st *Stack) Delete(ctx context.Context) error {
+	if err := st.ensureClient(ctx); err != nil {
+		return err
+	}
+
+	// the stacks created by haws are protected from deletion
+	if err := st.setTerminationProtection(ctx, false); err != nil {
+		return err
+	}
+
+	token := fmt.Sprintf("haws-delete-%d", time.Now().UTC().UnixNano())
+	logger.Info("Deleting stack: %s", *st.GetStackName())
+	events := st.newEventStream(token, time.Now().Add(-time.Minute))
+	_, err := st.cloudFormationClient.DeleteStack(ctx, &cloudformation.DeleteStackInput{
+		StackName:          st.GetStackName(),
+		ClientRequestToken: aws.String(token),
+		RoleARN:            st.role(),
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Waiting for the deletion of stack %s to complete", *st.GetStackName())
+
+	return st.newWaiter(st.timeout()).Wait(ctx, "stack deletion", func(ctx context.Context) (bool, error) {
+		desc, err := st.describe(ctx)
+		if err != nil {
+			return false, err
+		}
+		if desc == nil {
+			return true, nil
+		}
+
+		if err := events.poll(ctx); err != nil {
+			logger.Debug("Unable to read events for stack %s: %s", *st.GetStackName(), err)
+		}
+
+		switch desc.StackStatus {
+		case types.StackStatusDeleteComplete:
+			return true, nil
+		case types.StackStatusDeleteFailed:
+			return false, newStackFailedError(*st.GetStackName(), string(desc.StackStatus),
+				aws.ToString(desc.StackStatusReason), events.rootCauses())
+		}
+		return false, nil
+	})
+}
@@ -0,0 +1,102 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	cfn "github.com/awslabs/goformation/v4/cloudformation"
+	s3 "github.com/awslabs/goformation/v4/cloudformation/s3"
+)
+
+// mockCFNDelete simulates a stack that disappears after being deleted
+type mockCFNDelete struct {
+	mockCFNRun
+	deleted bool
+	imports map[string][]string
+}
+
+func (m *mockCFNDelete) DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	m.deleted = true
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+
+func (m *mockCFNDelete) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	if m.deleted {
+		return nil, fmt.Errorf("Stack with id %s does not exist", *params.StackName)
+	}
+	return &cloudformation.DescribeStacksOutput{
+		Stacks: []types.Stack{{StackStatus: types.StackStatusCreateComplete}},
+	}, nil
+}
+
+func (m *mockCFNDelete) ListImports(ctx context.Context, params *cloudformation.ListImportsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error) {
+	if imports, ok := m.imports[*params.ExportName]; ok {
+		return &cloudformation.ListImportsOutput{Imports: imports}, nil
+	}
+	return nil, fmt.Errorf("Export '%s' is not imported by any stack.", *params.ExportName)
+}
+
+func newBucketTemplate() *TemplateComponent {
+	tmpl := NewTemplate("us-east-1")
+	tmpl.AddResource("bucket", &s3.Bucket{})
+	tmpl.AddOutput("Name", cfn.Output{Value: "name", Export: &cfn.Export{Name: "BucketName"}}, "mock")
+	tmpl.AddOutput("Arn", cfn.Output{Value: "arn", Export: &cfn.Export{Name: "BucketArn"}}, "mock")
+	return &tmpl
+}
+
+type bucketTemplate struct {
+	*TemplateComponent
+}
+
+func (b bucketTemplate) GetStackName() *string {
+	return aws.String("mock-bucket")
+}
+
+func (b bucketTemplate) GetExportName(name string) string {
+	return "Bucket" + name
+}
+
+func TestStack_Delete(t *testing.T) {
+	mock := &mockCFNDelete{}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	exists, err := stk.Exists(context.Background())
+	if err != nil || !exists {
+		t.Fatalf("Expected the stack to exist, got %v, %v", exists, err)
+	}
+	if err := stk.Delete(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	exists, err = stk.Exists(context.Background())
+	if err != nil || exists {
+		t.Errorf("Expected the stack to be gone, got %v, %v", exists, err)
+	}
+}
+
+func TestStack_Importers(t *testing.T) {
+	mock := &mockCFNDelete{imports: map[string][]string{
+		"BucketName": {"site-b-cloudfront", "site-a-user"},
+		"BucketArn":  {"site-a-user"},
+	}}
+	stk := NewStack(bucketTemplate{newBucketTemplate()})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	importers, err := stk.Importers(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(importers) != 2 || importers[0] != "site-a-user" || importers[1] != "site-b-cloudfront" {
+		t.Errorf("Expected [site-a-user site-b-cloudfront], got %v", importers)
+	}
+
+	if buckets := stk.ResourcesOfType("AWS::S3::Bucket"); len(buckets) != 1 || buckets[0] != "bucket" {
+		t.Errorf("Expected [bucket], got %v", buckets)
+	}
+}
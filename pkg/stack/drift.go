@@ -0,0 +1,104 @@
+package stack
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// DetectDrift runs the drift detection on the stack and waits for it to finish
+// return: []types.StackResourceDrift - the resources that were modified or deleted outside CloudFormation
+// return: error - the error if any
+func (st *Stack) DetectDrift(ctx context.Context) ([]types.StackResourceDrift, error) {
+	if err := st.ensureClient(ctx); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Detecting drift on stack %s", *st.GetStackName())
+	resp, err := st.cloudFormationClient.DetectStackDrift(ctx, &cloudformation.DetectStackDriftInput{
+		StackName: st.GetStackName(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := st.waitForDriftDetection(ctx, aws.ToString(resp.StackDriftDetectionId)); err != nil {
+		return nil, err
+	}
+
+	drifts := make([]types.StackResourceDrift, 0)
+	var nextToken *string
+	for {
+		out, err := st.cloudFormationClient.DescribeStackResourceDrifts(ctx, &cloudformation.DescribeStackResourceDriftsInput{
+			StackName: st.GetStackName(),
+			NextToken: nextToken,
+			StackResourceDriftStatusFilters: []types.StackResourceDriftStatus{
+				types.StackResourceDriftStatusModified,
+				types.StackResourceDriftStatusDeleted,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, out.StackResourceDrifts...)
+		if out.NextToken == nil {
+			return drifts, nil
+		}
+		nextToken = out.NextToken
+	}
+}
+
+// waitForDriftDetection waits for a drift detection to finish
+// param: detectionId - the id of the drift detection
+// return: error - the error if any
+func (st *Stack) waitForDriftDetection(ctx context.Context, detectionId string) error {
+	return st.newWaiter(driftTimeout).Wait(ctx, "drift detection", func(ctx context.Context) (bool, error) {
+		status, err := st.cloudFormationClient.DescribeStackDriftDetectionStatus(ctx, &cloudformation.DescribeStackDriftDetectionStatusInput{
+			StackDriftDetectionId: &detectionId,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		switch status.DetectionStatus {
+		case types.StackDriftDetectionStatusDetectionComplete:
+			return true, nil
+		case types.StackDriftDetectionStatusDetectionFailed:
+			// the detection fails for resources that do not support it, but the others are still checked
+			logger.Warn("Drift detection on stack %s was incomplete: %s",
+				*st.GetStackName(), aws.ToString(status.DetectionStatusReason))
+			return true, nil
+		}
+		return false, nil
+	})
+}
+
+// LogDrift prints the drifted resources and their drifted properties
+// param: drifts - the drifted resources
+func (st *Stack) LogDrift(drifts []types.StackResourceDrift) {
+	if len(drifts) == 0 {
+		logger.Info("Stack %s: no drift", *st.GetStackName())
+		return
+	}
+
+	logger.Warn("Stack %s: %d drifted resource(s)", *st.GetStackName(), len(drifts))
+	for _, drift := range drifts {
+		logger.Warn("  %s (%s, %s): %s",
+			aws.ToString(drift.LogicalResourceId),
+			aws.ToString(drift.ResourceType),
+			aws.ToString(drift.PhysicalResourceId),
+			drift.StackResourceDriftStatus,
+		)
+		for _, diff := range drift.PropertyDifferences {
+			logger.Warn("    %s %s: expected %s, actual %s",
+				diff.DifferenceType,
+				aws.ToString(diff.PropertyPath),
+				aws.ToString(diff.ExpectedValue),
+				aws.ToString(diff.ActualValue),
+			)
+		}
+	}
+}
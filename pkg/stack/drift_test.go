@@ -0,0 +1,72 @@
+package stack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// mockCFNDrift reports one modified distribution after the detection completes
+type mockCFNDrift struct {
+	mockCFNRun
+	statusCalls int
+	filters     []types.StackResourceDriftStatus
+}
+
+func (m *mockCFNDrift) DetectStackDrift(ctx context.Context, params *cloudformation.DetectStackDriftInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DetectStackDriftOutput, error) {
+	return &cloudformation.DetectStackDriftOutput{StackDriftDetectionId: aws.String("detection")}, nil
+}
+
+func (m *mockCFNDrift) DescribeStackDriftDetectionStatus(ctx context.Context, params *cloudformation.DescribeStackDriftDetectionStatusInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+	m.statusCalls++
+	if m.statusCalls < 2 {
+		return &cloudformation.DescribeStackDriftDetectionStatusOutput{
+			DetectionStatus: types.StackDriftDetectionStatusDetectionInProgress,
+		}, nil
+	}
+	return &cloudformation.DescribeStackDriftDetectionStatusOutput{
+		DetectionStatus: types.StackDriftDetectionStatusDetectionComplete,
+	}, nil
+}
+
+func (m *mockCFNDrift) DescribeStackResourceDrifts(ctx context.Context, params *cloudformation.DescribeStackResourceDriftsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceDriftsOutput, error) {
+	m.filters = params.StackResourceDriftStatusFilters
+	return &cloudformation.DescribeStackResourceDriftsOutput{
+		StackResourceDrifts: []types.StackResourceDrift{{
+			LogicalResourceId:        aws.String("distribution"),
+			ResourceType:             aws.String("AWS::CloudFront::Distribution"),
+			StackResourceDriftStatus: types.StackResourceDriftStatusModified,
+			PropertyDifferences: []types.PropertyDifference{{
+				PropertyPath:   aws.String("/DistributionConfig/DefaultCacheBehavior/DefaultTTL"),
+				ExpectedValue:  aws.String("3600"),
+				ActualValue:    aws.String("60"),
+				DifferenceType: types.DifferenceTypeNotEqual,
+			}},
+		}},
+	}, nil
+}
+
+func TestStack_DetectDrift(t *testing.T) {
+	mock := &mockCFNDrift{}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	drifts, err := stk.DetectDrift(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mock.statusCalls != 2 {
+		t.Errorf("Expected to wait for the detection to complete, got %d status calls", mock.statusCalls)
+	}
+	if len(mock.filters) != 2 {
+		t.Errorf("Expected only modified and deleted resources to be requested, got %v", mock.filters)
+	}
+	if len(drifts) != 1 || *drifts[0].LogicalResourceId != "distribution" {
+		t.Fatalf("Expected the distribution to drift, got %v", drifts)
+	}
+	stk.LogDrift(drifts)
+}
@@ -0,0 +1,125 @@
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+)
+
+// ErrNoChanges is returned when the template and the parameters match the deployed stack
+var ErrNoChanges = errors.New("no changes")
+
+// ResourceFailure is a resource that caused a stack operation to fail
+type ResourceFailure struct {
+	LogicalId    string
+	ResourceType string
+	Status       string
+	Reason       string
+}
+
+// StackFailedError is returned when a stack operation ends in a failed or rolled back state
+type StackFailedError struct {
+	Stack     string
+	Status    string
+	Reason    string
+	Resources []ResourceFailure
+}
+
+func (e *StackFailedError) Error() string {
+	msg := fmt.Sprintf("stack operation failed on %s: %s", e.Stack, e.Status)
+	if e.Reason != "" {
+		msg += " (" + e.Reason + ")"
+	}
+	for _, resource := range e.Resources {
+		msg += fmt.Sprintf("; %s: %s", resource.LogicalId, resource.Reason)
+	}
+	return msg
+}
+
+// ChangeSetFailedError is returned when CloudFormation cannot create a change set (invalid template or parameters)
+type ChangeSetFailedError struct {
+	Stack     string
+	ChangeSet string
+	Reason    string
+}
+
+func (e *ChangeSetFailedError) Error() string {
+	return fmt.Sprintf("change set creation failed on %s: %s", e.Stack, e.Reason)
+}
+
+// TimeoutError is returned when an operation does not complete in time
+// The operation itself keeps running in CloudFormation
+type TimeoutError struct {
+	Operation string
+	After     time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for %s", e.After, e.Operation)
+}
+
+// throttleCodes are the error codes of the AWS APIs rejecting a request because of the request rate
+var throttleCodes = []string{"Throttling", "ThrottlingException", "TooManyRequestsException", "RequestLimitExceeded", "SlowDown"}
+
+// accessDeniedCodes are the error codes of the AWS APIs rejecting a request because of the permissions
+var accessDeniedCodes = []string{"AccessDenied", "AccessDeniedException", "UnauthorizedOperation", "InvalidClientTokenId", "ExpiredToken"}
+
+// IsThrottle returns true if the error is an AWS API rejecting the request rate
+// param: err - the error
+// return: bool - true if the request was throttled
+func IsThrottle(err error) bool {
+	return hasCode(err, throttleCodes)
+}
+
+// IsAccessDenied returns true if the error is an AWS API rejecting the credentials or their permissions
+// param: err - the error
+// return: bool - true if the access was denied
+func IsAccessDenied(err error) bool {
+	return hasCode(err, accessDeniedCodes)
+}
+
+// hasCode returns true if the error is an AWS API error with one of the codes
+// param: err - the error
+// param: codes - the error codes
+// return: bool - true if the code of the error is one of the codes
+func hasCode(err error, codes []string) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, code := range codes {
+		if apiErr.ErrorCode() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// newStackFailedError builds the error of a failed stack operation from the failed events
+// param: stack - the name of the stack
+// param: status - the final status of the stack
+// param: reason - the status reason of the stack
+// param: causes - the failed events
+// return: *StackFailedError - the error
+func newStackFailedError(stack string, status string, reason string, causes []types.StackEvent) *StackFailedError {
+	resources := make([]ResourceFailure, 0, len(causes))
+	for _, ev := range causes {
+		resources = append(resources, ResourceFailure{
+			LogicalId:    aws.ToString(ev.LogicalResourceId),
+			ResourceType: aws.ToString(ev.ResourceType),
+			Status:       string(ev.ResourceStatus),
+			Reason:       strings.TrimSpace(aws.ToString(ev.ResourceStatusReason)),
+		})
+	}
+	return &StackFailedError{
+		Stack:     stack,
+		Status:    status,
+		Reason:    reason,
+		Resources: resources,
+	}
+}
@@ -0,0 +1,97 @@
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
+)
+
+// mockCFNFailure is an existing stack whose update rolls back because of the distribution
+type mockCFNFailure struct {
+	mockCFNPlan
+	changeSetStatus types.ChangeSetStatus
+	changeSetReason string
+}
+
+func (m *mockCFNFailure) DescribeChangeSet(ctx context.Context, params *cloudformation.DescribeChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error) {
+	if m.changeSetStatus == "" {
+		return m.mockCFNPlan.DescribeChangeSet(ctx, params, optFns...)
+	}
+	return &cloudformation.DescribeChangeSetOutput{Status: m.changeSetStatus, StatusReason: aws.String(m.changeSetReason)}, nil
+}
+
+func (m *mockCFNFailure) ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
+	m.status = types.StackStatusUpdateRollbackComplete
+	return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+func (m *mockCFNFailure) DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+	event := newEvent("2", "distribution", types.ResourceStatusUpdateFailed, "CNAMEAlreadyExists", time.Now())
+	// the failure is reported without a client request token
+	event.ClientRequestToken = nil
+	return &cloudformation.DescribeStackEventsOutput{StackEvents: []types.StackEvent{event}}, nil
+}
+
+func newFailureStack(mock *mockCFNFailure) *Stack {
+	mock.status = types.StackStatusUpdateComplete
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+	return stk
+}
+
+func TestStack_Run_StackFailedError(t *testing.T) {
+	stk := newFailureStack(&mockCFNFailure{})
+
+	err := stk.Run(context.Background())
+	var failed *StackFailedError
+	if !errors.As(err, &failed) {
+		t.Fatalf("Expected a StackFailedError, got %v", err)
+	}
+	if failed.Stack != "mock-stack" || failed.Status != "UPDATE_ROLLBACK_COMPLETE" {
+		t.Errorf("Unexpected failure %+v", failed)
+	}
+	if len(failed.Resources) != 1 || failed.Resources[0].LogicalId != "distribution" || failed.Resources[0].Reason != "CNAMEAlreadyExists" {
+		t.Errorf("Expected the distribution as failed resource, got %+v", failed.Resources)
+	}
+}
+
+func TestStack_Run_ChangeSetFailedError(t *testing.T) {
+	stk := newFailureStack(&mockCFNFailure{changeSetStatus: types.ChangeSetStatusFailed, changeSetReason: "Template format error"})
+
+	err := stk.Run(context.Background())
+	var failed *ChangeSetFailedError
+	if !errors.As(err, &failed) || failed.Reason != "Template format error" {
+		t.Errorf("Expected a ChangeSetFailedError, got %v", err)
+	}
+}
+
+func TestStack_Run_NoChanges(t *testing.T) {
+	stk := newFailureStack(&mockCFNFailure{changeSetStatus: types.ChangeSetStatusFailed, changeSetReason: EmptyChangeSet})
+
+	if err := stk.Run(context.Background()); !errors.Is(err, ErrNoChanges) {
+		t.Errorf("Expected ErrNoChanges, got %v", err)
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	throttle := fmt.Errorf("stack bucket: %w", &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded"})
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized"}
+
+	if !IsThrottle(throttle) || IsAccessDenied(throttle) {
+		t.Error("Expected a wrapped throttle to be recognized")
+	}
+	if !IsAccessDenied(denied) || IsThrottle(denied) {
+		t.Error("Expected an access denied to be recognized")
+	}
+	if IsThrottle(errors.New("Throttling")) {
+		t.Error("Plain errors are not API errors")
+	}
+}
@@ -0,0 +1,121 @@
+package stack
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// eventStream follows the events of a stack operation and logs every new event once
+type eventStream struct {
+	stack  *Stack
+	token  string
+	since  time.Time
+	seen   map[string]bool
+	events []types.StackEvent
+}
+
+// newEventStream creates an event stream for the operation started at since
+// param: token - the client request token of the operation (events with other tokens are ignored)
+// param: since - the moment the operation was started
+func (st *Stack) newEventStream(token string, since time.Time) *eventStream {
+	return &eventStream{
+		stack: st,
+		token: token,
+		since: since,
+		seen:  make(map[string]bool),
+	}
+}
+
+// poll fetches the stack events and logs the ones that were not seen before in chronological order
+// return: error - the error if any
+func (es *eventStream) poll(ctx context.Context) error {
+	fresh := make([]types.StackEvent, 0)
+	var nextToken *string
+
+pages:
+	for {
+		resp, err := es.stack.cloudFormationClient.DescribeStackEvents(ctx, &cloudformation.DescribeStackEventsInput{
+			StackName: es.stack.GetStackName(),
+			NextToken: nextToken,
+		})
+		if err != nil {
+			return err
+		}
+
+		// events are returned newest first, so stop at the first one that is already known or too old
+		for _, ev := range resp.StackEvents {
+			id := aws.ToString(ev.EventId)
+			if es.seen[id] || (ev.Timestamp != nil && ev.Timestamp.Before(es.since)) {
+				break pages
+			}
+			es.seen[id] = true
+			if es.token != "" && ev.ClientRequestToken != nil && *ev.ClientRequestToken != es.token {
+				continue
+			}
+			fresh = append(fresh, ev)
+		}
+
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+
+	for i := len(fresh) - 1; i >= 0; i-- {
+		es.events = append(es.events, fresh[i])
+		logEvent(fresh[i])
+	}
+	return nil
+}
+
+// rootCauses returns the failed events that were not caused by another failure, in chronological order
+// return: []types.StackEvent - the failed events
+func (es *eventStream) rootCauses() []types.StackEvent {
+	return rootCauses(es.events)
+}
+
+// rootCauses filters the failed events of a resource that are not just the consequence of another failure
+// param: events - the events in chronological order
+// return: []types.StackEvent - the failed events
+func rootCauses(events []types.StackEvent) []types.StackEvent {
+	causes := make([]types.StackEvent, 0)
+	for _, ev := range events {
+		if !strings.HasSuffix(string(ev.ResourceStatus), "FAILED") {
+			continue
+		}
+		// the stack itself only reports that some resources failed
+		if aws.ToString(ev.LogicalResourceId) == aws.ToString(ev.StackName) {
+			continue
+		}
+		reason := aws.ToString(ev.ResourceStatusReason)
+		if strings.Contains(reason, "Resource creation cancelled") ||
+			strings.Contains(reason, "Resource update cancelled") {
+			continue
+		}
+		causes = append(causes, ev)
+	}
+	return causes
+}
+
+// logEvent prints one stack event
+func logEvent(ev types.StackEvent) {
+	status := string(ev.ResourceStatus)
+	format := "%-30s %-40s %-20s %s"
+	args := []interface{}{
+		aws.ToString(ev.LogicalResourceId),
+		aws.ToString(ev.ResourceType),
+		status,
+		aws.ToString(ev.ResourceStatusReason),
+	}
+	if strings.HasSuffix(status, "FAILED") {
+		logger.Warn(format, args...)
+	} else {
+		logger.Info(format, args...)
+	}
+}
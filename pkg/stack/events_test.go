@@ -0,0 +1,91 @@
+package stack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// mockCFNEvents returns a growing list of events, newest first, like DescribeStackEvents does
+type mockCFNEvents struct {
+	mockCFNRun
+	events []types.StackEvent
+}
+
+func (m *mockCFNEvents) DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+	out := make([]types.StackEvent, 0, len(m.events))
+	for i := len(m.events) - 1; i >= 0; i-- {
+		out = append(out, m.events[i])
+	}
+	return &cloudformation.DescribeStackEventsOutput{StackEvents: out}, nil
+}
+
+func newEvent(id string, logicalId string, status types.ResourceStatus, reason string, at time.Time) types.StackEvent {
+	return types.StackEvent{
+		EventId:              aws.String(id),
+		StackName:            aws.String("mock-stack"),
+		LogicalResourceId:    aws.String(logicalId),
+		ResourceType:         aws.String("AWS::S3::Bucket"),
+		ResourceStatus:       status,
+		ResourceStatusReason: aws.String(reason),
+		ClientRequestToken:   aws.String("cs"),
+		Timestamp:            aws.Time(at),
+	}
+}
+
+func TestEventStream_Deduplicates(t *testing.T) {
+	now := time.Now()
+	mock := &mockCFNEvents{
+		events: []types.StackEvent{
+			newEvent("old", "bucket", types.ResourceStatusCreateComplete, "", now.Add(-time.Hour)),
+			newEvent("1", "bucket", types.ResourceStatusCreateInProgress, "", now),
+		},
+	}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	es := stk.newEventStream("cs", now.Add(-time.Minute))
+	if err := es.poll(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(es.events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(es.events))
+	}
+
+	mock.events = append(mock.events,
+		newEvent("2", "bucket", types.ResourceStatusCreateComplete, "", now.Add(time.Second)),
+		types.StackEvent{EventId: aws.String("other"), ClientRequestToken: aws.String("other"), Timestamp: aws.Time(now.Add(time.Second))},
+	)
+	if err := es.poll(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(es.events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(es.events))
+	}
+	if *es.events[1].EventId != "2" {
+		t.Errorf("Expected events in chronological order, got %s last", *es.events[1].EventId)
+	}
+}
+
+func TestRootCauses(t *testing.T) {
+	now := time.Now()
+	events := []types.StackEvent{
+		newEvent("1", "bucket", types.ResourceStatusCreateFailed, "Bucket already exists", now),
+		newEvent("2", "policy", types.ResourceStatusCreateFailed, "Resource creation cancelled", now),
+		newEvent("3", "mock-stack", types.ResourceStatusCreateFailed, "The following resource(s) failed to create: [bucket]", now),
+		newEvent("4", "oai", types.ResourceStatusCreateComplete, "", now),
+	}
+
+	causes := rootCauses(events)
+	if len(causes) != 1 {
+		t.Fatalf("Expected 1 root cause, got %d", len(causes))
+	}
+	if *causes[0].LogicalResourceId != "bucket" {
+		t.Errorf("Expected root cause 'bucket', got '%s'", *causes[0].LogicalResourceId)
+	}
+}
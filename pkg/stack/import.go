@@ -0,0 +1,260 @@
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// importIdentifiers maps the resource types that can be imported to the property identifying them
+var importIdentifiers = map[string]string{
+	"AWS::S3::Bucket":               "BucketName",
+	"AWS::CloudFront::Distribution": "Id",
+}
+
+// subVariable matches the variables of a Fn::Sub string (${name} or ${name.attribute}, not ${!literal})
+var subVariable = regexp.MustCompile(`\$\{([^!}.][^}.]*)`)
+
+// Adopt prepares the template for existing resources:
+// they get the Retain deletion policy and the parameters naming them are set to their physical ids
+// It must be applied on every deployment after the import, so the template keeps matching the resources
+// param: resources - the physical ids indexed by the logical id of the resources
+// return: error - the error if a resource cannot be imported
+func (st *Stack) Adopt(resources map[string]string) error {
+	importable := st.GetImportable()
+	for name, id := range resources {
+		parameter, ok := importable[name]
+		if !ok {
+			return fmt.Errorf("resource %s of stack %s cannot be imported", name, *st.GetStackName())
+		}
+		if err := st.Retain(name); err != nil {
+			return err
+		}
+		if parameter != "" {
+			if err := st.SetParameterValue(parameter, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Import brings existing resources under the management of the stack with an IMPORT change set
+// The resources needed by the imported ones (e.g. the log bucket of a distribution) are created first,
+// the other resources and the outputs are left for the next deployment
+// Resources can only be imported before the first deployment of the stack
+// param: resources - the physical ids indexed by the logical id of the resources
+// return: error - the error if any
+func (st *Stack) Import(ctx context.Context, resources map[string]string) error {
+	if err := st.ensureClient(ctx); err != nil {
+		return err
+	}
+
+	exists, err := st.prepare(ctx, true)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("stack %s already exists: resources can only be imported before its first deployment", *st.GetStackName())
+	}
+
+	templateBody, err := st.templateJson()
+	if err != nil {
+		return err
+	}
+	template := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(templateBody), &template); err != nil {
+		return err
+	}
+	templateResources, _ := template["Resources"].(map[string]interface{})
+
+	imported := make([]string, 0, len(resources))
+	for name := range resources {
+		if _, ok := templateResources[name]; !ok {
+			return fmt.Errorf("resource %s not found in stack %s", name, *st.GetStackName())
+		}
+		imported = append(imported, name)
+	}
+	sort.Strings(imported)
+
+	// the resources referenced by the imported ones must exist before the import
+	base := make(map[string]bool)
+	for _, name := range imported {
+		dependencies(templateResources, name, base)
+	}
+	for _, name := range imported {
+		delete(base, name)
+	}
+
+	if len(base) > 0 {
+		body, err := partialTemplate(template, base)
+		if err != nil {
+			return err
+		}
+		csName := changeSetName()
+		logger.Info("Creating stack: %s with the resources needed by the import %v, changeset: %s", *st.GetStackName(), sortedNames(base), csName)
+		if err := st.submit(ctx, csName, "CREATE", body, nil); err != nil {
+			return err
+		}
+	}
+
+	toImport := make([]types.ResourceToImport, 0, len(imported))
+	for _, name := range imported {
+		resource, _ := templateResources[name].(map[string]interface{})
+		resourceType, _ := resource["Type"].(string)
+		identifier, ok := importIdentifiers[resourceType]
+		if !ok {
+			return fmt.Errorf("resource %s of type %s cannot be imported", name, resourceType)
+		}
+		toImport = append(toImport, types.ResourceToImport{
+			LogicalResourceId:  aws.String(name),
+			ResourceType:       aws.String(resourceType),
+			ResourceIdentifier: map[string]string{identifier: resources[name]},
+		})
+		base[name] = true
+	}
+
+	body, err := partialTemplate(template, base)
+	if err != nil {
+		return err
+	}
+	csName := changeSetName()
+	logger.Info("Importing %v into stack: %s with changeset: %s", imported, *st.GetStackName(), csName)
+	return st.submit(ctx, csName, "IMPORT", body, toImport)
+}
+
+// submit creates a change set, waits for it and executes it once approved
+// param: csName - the name of the change set
+// param: csType - the type of the change set (CREATE or IMPORT)
+// param: templateBody - the template body
+// param: imports - the resources to import (IMPORT only)
+// return: error - the error if any
+func (st *Stack) submit(ctx context.Context, csName string, csType string, templateBody string, imports []types.ResourceToImport) error {
+	if err := st.submitChangeSet(ctx, csName, csType, templateBody, imports); err != nil {
+		return err
+	}
+	empty, err := st.waitForChangeSet(ctx, csName)
+	if err != nil || empty {
+		return err
+	}
+	return st.reviewAndExecute(ctx, csName, csType)
+}
+
+// partialTemplate returns the template restricted to some resources, without outputs
+// param: template - the parsed template
+// param: names - the names of the resources to keep
+// return: string - the template body
+// return: error - the error if any
+func partialTemplate(template map[string]interface{}, names map[string]bool) (string, error) {
+	templateResources, _ := template["Resources"].(map[string]interface{})
+
+	partial := make(map[string]interface{})
+	for key, value := range template {
+		if key != "Resources" && key != "Outputs" {
+			partial[key] = value
+		}
+	}
+	kept := make(map[string]interface{})
+	for name := range names {
+		kept[name] = templateResources[name]
+	}
+	partial["Resources"] = kept
+
+	data, err := json.Marshal(partial)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// dependencies adds the resources a resource needs, directly or through other resources
+// param: resources - the resources of the template
+// param: name - the name of the resource
+// param: found - the resources found so far
+func dependencies(resources map[string]interface{}, name string, found map[string]bool) {
+	refs := make(map[string]bool)
+	resourceRefs(resources[name], resources, refs)
+	for ref := range refs {
+		if !found[ref] {
+			found[ref] = true
+			dependencies(resources, ref, found)
+		}
+	}
+}
+
+// resourceRefs collects the resources referenced by a value (Ref, Fn::GetAtt, Fn::Sub and DependsOn)
+// param: value - the value, as parsed from the template JSON
+// param: resources - the resources of the template
+// param: refs - the referenced resources
+func resourceRefs(value interface{}, resources map[string]interface{}, refs map[string]bool) {
+	add := func(name string) {
+		if _, ok := resources[name]; ok {
+			refs[name] = true
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			switch key {
+			case "Ref":
+				if name, ok := item.(string); ok {
+					add(name)
+				}
+			case "Fn::GetAtt":
+				if args, ok := item.([]interface{}); ok && len(args) > 0 {
+					if name, ok := args[0].(string); ok {
+						add(name)
+					}
+				} else if attribute, ok := item.(string); ok {
+					add(strings.SplitN(attribute, ".", 2)[0])
+				}
+			case "Fn::Sub":
+				str, ok := item.(string)
+				if args, isList := item.([]interface{}); isList && len(args) > 0 {
+					str, ok = args[0].(string)
+				}
+				if ok {
+					for _, match := range subVariable.FindAllStringSubmatch(str, -1) {
+						add(match[1])
+					}
+				}
+			case "DependsOn":
+				if name, ok := item.(string); ok {
+					add(name)
+				}
+				if names, ok := item.([]interface{}); ok {
+					for _, n := range names {
+						if name, ok := n.(string); ok {
+							add(name)
+						}
+					}
+				}
+			}
+			resourceRefs(item, resources, refs)
+		}
+	case []interface{}:
+		for _, item := range v {
+			resourceRefs(item, resources, refs)
+		}
+	}
+}
+
+// sortedNames returns the names of a set, sorted
+// param: names - the set
+// return: []string - the sorted names
+func sortedNames(names map[string]bool) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,135 @@
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	cfn "github.com/awslabs/goformation/v4/cloudformation"
+	s3 "github.com/awslabs/goformation/v4/cloudformation/s3"
+)
+
+// mockCFNImport starts without the stack and records the change sets
+type mockCFNImport struct {
+	mockCFNRun
+	inputs []*cloudformation.CreateChangeSetInput
+	status types.StackStatus
+}
+
+func (m *mockCFNImport) CreateChangeSet(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error) {
+	m.inputs = append(m.inputs, params)
+	return &cloudformation.CreateChangeSetOutput{}, nil
+}
+
+func (m *mockCFNImport) ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
+	if m.inputs[len(m.inputs)-1].ChangeSetType == types.ChangeSetTypeImport {
+		m.status = types.StackStatusImportComplete
+	} else {
+		m.status = types.StackStatusCreateComplete
+	}
+	return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+func (m *mockCFNImport) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	if m.status == "" {
+		return nil, &types.StackNotFoundException{Message: aws.String("Stack with id mock-bucket does not exist")}
+	}
+	return &cloudformation.DescribeStacksOutput{Stacks: []types.Stack{{StackStatus: m.status}}}, nil
+}
+
+// newSiteTemplate returns a template whose site bucket logs to a log bucket and has a policy
+func newSiteTemplate() *TemplateComponent {
+	tmpl := NewTemplate("us-east-1")
+	tmpl.AddParameter("BucketName", cfn.Parameter{Type: "String"}, "new-bucket")
+	tmpl.AddResource("logs", &s3.Bucket{})
+	tmpl.AddResource("site", &s3.Bucket{
+		BucketName: cfn.Ref("BucketName"),
+		LoggingConfiguration: &s3.Bucket_LoggingConfiguration{
+			DestinationBucketName: cfn.Ref("logs"),
+		},
+	})
+	tmpl.AddResource("policy", &s3.BucketPolicy{Bucket: cfn.Ref("site")})
+	tmpl.AddImportable("site", "BucketName")
+	tmpl.AddOutput("Name", cfn.Output{Value: cfn.Ref("site")}, "mock")
+	return &tmpl
+}
+
+func TestStack_Import(t *testing.T) {
+	tmpl := newSiteTemplate()
+	mock := &mockCFNImport{}
+	stk := NewStack(bucketTemplate{tmpl})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	resources := map[string]string{"site": "old-bucket"}
+	if err := stk.Adopt(resources); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := stk.Import(context.Background(), resources); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(mock.inputs) != 2 {
+		t.Fatalf("Expected a CREATE and an IMPORT change set, got %d change sets", len(mock.inputs))
+	}
+
+	create := parseTemplate(t, mock.inputs[0])
+	if mock.inputs[0].ChangeSetType != types.ChangeSetTypeCreate || len(create.Resources) != 1 || create.Resources["logs"] == nil {
+		t.Errorf("Expected the log bucket to be created first, got %s with %v", mock.inputs[0].ChangeSetType, create.Resources)
+	}
+
+	imp := mock.inputs[1]
+	body := parseTemplate(t, imp)
+	if imp.ChangeSetType != types.ChangeSetTypeImport || len(body.Resources) != 2 || body.Outputs != nil {
+		t.Errorf("Expected an IMPORT of the site bucket next to the log bucket, got %s with %v", imp.ChangeSetType, body.Resources)
+	}
+	if body.Resources["site"]["DeletionPolicy"] != "Retain" {
+		t.Errorf("Expected the imported bucket to be retained, got %v", body.Resources["site"])
+	}
+	if len(imp.ResourcesToImport) != 1 || imp.ResourcesToImport[0].ResourceIdentifier["BucketName"] != "old-bucket" {
+		t.Errorf("Unexpected resources to import %+v", imp.ResourcesToImport)
+	}
+	if stk.ParameterValues()["BucketName"] != "old-bucket" {
+		t.Errorf("Expected the bucket name to be the imported one, got %s", stk.ParameterValues()["BucketName"])
+	}
+}
+
+func TestStack_Import_Existing(t *testing.T) {
+	mock := &mockCFNImport{status: types.StackStatusCreateComplete}
+	stk := NewStack(bucketTemplate{newSiteTemplate()})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	err := stk.Import(context.Background(), map[string]string{"site": "old-bucket"})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected 'already exists' error, got %v", err)
+	}
+	if len(mock.inputs) != 0 {
+		t.Error("No change set should be created")
+	}
+}
+
+func TestStack_Adopt_NotImportable(t *testing.T) {
+	stk := NewStack(bucketTemplate{newSiteTemplate()})
+	if err := stk.Adopt(map[string]string{"logs": "old-logs"}); err == nil {
+		t.Error("Expected an error for a resource that cannot be imported")
+	}
+}
+
+type parsedTemplate struct {
+	Resources map[string]map[string]interface{} `json:"Resources"`
+	Outputs   map[string]interface{}            `json:"Outputs"`
+}
+
+func parseTemplate(t *testing.T, input *cloudformation.CreateChangeSetInput) parsedTemplate {
+	t.Helper()
+	var tp parsedTemplate
+	if err := json.Unmarshal([]byte(aws.ToString(input.TemplateBody)), &tp); err != nil {
+		t.Fatalf("Invalid template: %v", err)
+	}
+	return tp
+}
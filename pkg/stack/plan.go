@@ -0,0 +1,180 @@
+package stack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// StackPlan records the change set created for a stack, so it can be executed later
+type StackPlan struct {
+	Stack         string            `json:"stack"`
+	Region        string            `json:"region"`
+	ChangeSet     string            `json:"changeSet,omitempty"`
+	ChangeSetType string            `json:"changeSetType,omitempty"`
+	TemplateHash  string            `json:"templateHash"`
+	Parameters    map[string]string `json:"parameters"`
+	StackStatus   string            `json:"stackStatus,omitempty"`
+	LastUpdated   string            `json:"lastUpdated,omitempty"`
+	Changes       []string          `json:"changes"`
+	// Replaced are the resources the change set adds or replaces, so their physical ids are not known yet
+	Replaced []string `json:"replaced,omitempty"`
+	// Deferred is why the stack was not planned: it depends on a stack that must be applied first
+	Deferred string `json:"deferred,omitempty"`
+}
+
+// Plan creates the change set of the stack without executing it
+// The stack is left unchanged: a stack that needs a deletion or a rollback to accept a change set is an error
+// return: *StackPlan - the plan of the stack (without change set if there is nothing to change)
+// return: error - the error if any
+func (st *Stack) Plan(ctx context.Context) (*StackPlan, error) {
+	if err := st.ensureClient(ctx); err != nil {
+		return nil, err
+	}
+
+	hash, err := st.templateHash()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &StackPlan{
+		Stack:        *st.GetStackName(),
+		Region:       st.GetRegion(),
+		TemplateHash: hash,
+		Parameters:   st.ParameterValues(),
+		Changes:      make([]string, 0),
+	}
+
+	csName, csType, err := st.createChangeSet(ctx, false)
+	if errors.Is(err, ErrNoChanges) {
+		return plan, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plan.ChangeSet = csName
+	plan.ChangeSetType = csType
+
+	changes, err := st.describeChanges(ctx, csName)
+	if err != nil {
+		return nil, err
+	}
+	reviewLock.Lock()
+	st.logChanges(changes)
+	reviewLock.Unlock()
+	for _, change := range changes {
+		rc := change.ResourceChange
+		if rc == nil {
+			continue
+		}
+		plan.Changes = append(plan.Changes, strings.Join(strings.Fields(changeLine(rc)), " "))
+		if rc.Action == types.ChangeActionAdd || (rc.Action == types.ChangeActionModify && rc.Replacement != types.ReplacementFalse) {
+			plan.Replaced = append(plan.Replaced, aws.ToString(rc.LogicalResourceId))
+		}
+	}
+
+	plan.StackStatus, plan.LastUpdated, err = st.state(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Apply executes the change set recorded in the plan
+// It refuses to run if the stack or its template changed since the plan was made
+// param: plan - the plan of the stack
+// return: error - the error if any
+func (st *Stack) Apply(ctx context.Context, plan *StackPlan) error {
+	if plan.Stack != *st.GetStackName() {
+		return fmt.Errorf("the plan is for stack %s, not %s", plan.Stack, *st.GetStackName())
+	}
+	if plan.Deferred != "" {
+		return fmt.Errorf("stack %s was not planned (%s): plan again after this apply", plan.Stack, plan.Deferred)
+	}
+	if plan.Region != st.GetRegion() {
+		return fmt.Errorf("the plan of stack %s is for region %s, not %s: plan again", plan.Stack, plan.Region, st.GetRegion())
+	}
+	hash, err := st.templateHash()
+	if err != nil {
+		return err
+	}
+	if hash != plan.TemplateHash {
+		return fmt.Errorf("the template of stack %s changed since the plan was made: plan again", plan.Stack)
+	}
+	if plan.ChangeSet == "" {
+		logger.Info("Stack %s: no changes", plan.Stack)
+		return nil
+	}
+
+	if err := st.ensureClient(ctx); err != nil {
+		return err
+	}
+
+	status, lastUpdated, err := st.state(ctx)
+	if err != nil {
+		return err
+	}
+	if status != plan.StackStatus || lastUpdated != plan.LastUpdated {
+		return fmt.Errorf("stack %s changed since the plan was made (%s at %s, planned %s at %s): plan again",
+			plan.Stack, status, lastUpdated, plan.StackStatus, plan.LastUpdated)
+	}
+
+	desc, err := st.cloudFormationClient.DescribeChangeSet(ctx, &cloudformation.DescribeChangeSetInput{
+		ChangeSetName: &plan.ChangeSet,
+		StackName:     st.GetStackName(),
+	})
+	if notExist(err) {
+		return fmt.Errorf("change set %s of stack %s does not exist anymore: plan again", plan.ChangeSet, plan.Stack)
+	}
+	if err != nil {
+		return err
+	}
+	if desc.ExecutionStatus != types.ExecutionStatusAvailable {
+		return fmt.Errorf("change set %s of stack %s cannot be executed (%s): plan again",
+			plan.ChangeSet, plan.Stack, desc.ExecutionStatus)
+	}
+
+	logger.Info("Applying change set %s on stack %s", plan.ChangeSet, plan.Stack)
+	return st.execute(ctx, plan.ChangeSet, plan.ChangeSetType)
+}
+
+// templateHash returns the sha256 of the template of the stack
+// return: string - the hex encoded hash
+// return: error - the error if the template cannot be built
+func (st *Stack) templateHash() (string, error) {
+	templateBody, err := st.templateJson()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(templateBody))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// state returns the status of the stack and the time of its last change
+// return: string - the status of the stack (empty if it does not exist)
+// return: string - the time of the last change of the stack
+// return: error - the error if any
+func (st *Stack) state(ctx context.Context) (string, string, error) {
+	desc, err := st.describe(ctx)
+	if err != nil || desc == nil {
+		return "", "", err
+	}
+
+	changed := desc.CreationTime
+	if desc.LastUpdatedTime != nil {
+		changed = desc.LastUpdatedTime
+	}
+	if changed == nil {
+		return string(desc.StackStatus), "", nil
+	}
+	return string(desc.StackStatus), changed.UTC().Format(time.RFC3339Nano), nil
+}
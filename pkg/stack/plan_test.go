@@ -0,0 +1,128 @@
+package stack
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// mockCFNPlan is an existing stack with one change set that modifies the distribution
+type mockCFNPlan struct {
+	mockCFNRun
+	status      types.StackStatus
+	lastUpdated time.Time
+	executed    bool
+}
+
+func (m *mockCFNPlan) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	return &cloudformation.DescribeStacksOutput{
+		Stacks: []types.Stack{{StackStatus: m.status, LastUpdatedTime: aws.Time(m.lastUpdated)}},
+	}, nil
+}
+
+func (m *mockCFNPlan) DescribeChangeSet(ctx context.Context, params *cloudformation.DescribeChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error) {
+	return &cloudformation.DescribeChangeSetOutput{
+		Status:          types.ChangeSetStatusCreateComplete,
+		ExecutionStatus: types.ExecutionStatusAvailable,
+		Changes: []types.Change{{
+			ResourceChange: &types.ResourceChange{
+				Action:            types.ChangeActionModify,
+				LogicalResourceId: aws.String("distribution"),
+				ResourceType:      aws.String("AWS::CloudFront::Distribution"),
+				Replacement:       types.ReplacementFalse,
+			},
+		}},
+	}, nil
+}
+
+func (m *mockCFNPlan) ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
+	m.executed = true
+	m.status = types.StackStatusUpdateComplete
+	return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+func TestStack_PlanApply(t *testing.T) {
+	mock := &mockCFNPlan{status: types.StackStatusUpdateComplete, lastUpdated: time.Unix(1700000000, 0)}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	plan, err := stk.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if plan.ChangeSet == "" || plan.ChangeSetType != "UPDATE" {
+		t.Errorf("Expected an update change set, got %q (%s)", plan.ChangeSet, plan.ChangeSetType)
+	}
+	if len(plan.TemplateHash) != 64 {
+		t.Errorf("Expected a sha256 template hash, got %q", plan.TemplateHash)
+	}
+	if len(plan.Changes) != 1 || !strings.HasPrefix(plan.Changes[0], "Modify distribution AWS::CloudFront::Distribution") {
+		t.Errorf("Unexpected changes %v", plan.Changes)
+	}
+	if mock.executed {
+		t.Fatal("The plan should not execute the change set")
+	}
+
+	if err := stk.Apply(context.Background(), plan); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !mock.executed {
+		t.Error("Expected the change set to be executed")
+	}
+}
+
+func TestStack_Apply_StackChanged(t *testing.T) {
+	mock := &mockCFNPlan{status: types.StackStatusUpdateComplete, lastUpdated: time.Unix(1700000000, 0)}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	plan, err := stk.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// somebody else updated the stack in the meantime
+	mock.lastUpdated = mock.lastUpdated.Add(time.Hour)
+
+	err = stk.Apply(context.Background(), plan)
+	if err == nil || !strings.Contains(err.Error(), "changed since the plan was made") {
+		t.Errorf("Expected 'changed since the plan was made' error, got %v", err)
+	}
+	if mock.executed {
+		t.Error("The change set should not be executed")
+	}
+}
+
+func TestStack_Apply_PlanMismatch(t *testing.T) {
+	mock := &mockCFNPlan{status: types.StackStatusUpdateComplete, lastUpdated: time.Unix(1700000000, 0)}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	plan, err := stk.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	other := *plan
+	other.Region = "eu-west-1"
+	if err := stk.Apply(context.Background(), &other); err == nil || !strings.Contains(err.Error(), "region eu-west-1") {
+		t.Errorf("Expected a region mismatch error, got %v", err)
+	}
+
+	other = *plan
+	other.TemplateHash = strings.Repeat("0", 64)
+	if err := stk.Apply(context.Background(), &other); err == nil || !strings.Contains(err.Error(), "template of stack mock-stack changed") {
+		t.Errorf("Expected a template mismatch error, got %v", err)
+	}
+	if mock.executed {
+		t.Error("The change set should not be executed")
+	}
+}
@@ -0,0 +1,189 @@
+package stack
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// ConfirmFunc asks the user a yes/no question
+// param: question - the question to ask
+// return: bool - true if the user agreed
+// return: error - the error if any
+type ConfirmFunc func(ctx context.Context, question string) (bool, error)
+
+// reviewLock serializes the reviews so the changes of different stacks are not mixed with the prompts
+var reviewLock sync.Mutex
+
+// AutoApprove is a ConfirmFunc that agrees with everything
+func AutoApprove(ctx context.Context, question string) (bool, error) {
+	return true, nil
+}
+
+// Prompt returns a ConfirmFunc that asks the question on out and reads the answer from in
+// param: in - where the answers are read from
+// param: out - where the questions are written
+// return: ConfirmFunc - the prompt
+func Prompt(in io.Reader, out io.Writer) ConfirmFunc {
+	reader := bufio.NewReader(in)
+	return func(ctx context.Context, question string) (bool, error) {
+		if _, err := fmt.Fprintf(out, "%s [y/N]: ", question); err != nil {
+			return false, err
+		}
+		answer, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+}
+
+// describeChanges returns all the changes of a change set
+// param: csName - the name of the changeset
+// return: []types.Change - the changes
+// return: error - the error if any
+func (st *Stack) describeChanges(ctx context.Context, csName string) ([]types.Change, error) {
+	changes := make([]types.Change, 0)
+	var nextToken *string
+	for {
+		desc, err := st.cloudFormationClient.DescribeChangeSet(ctx, &cloudformation.DescribeChangeSetInput{
+			ChangeSetName: &csName,
+			StackName:     st.GetStackName(),
+			NextToken:     nextToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, desc.Changes...)
+		if desc.NextToken == nil {
+			return changes, nil
+		}
+		nextToken = desc.NextToken
+	}
+}
+
+// review prints the changes of the change set and asks for the approval to execute it
+// param: csName - the name of the changeset
+// return: bool - true if the change set can be executed
+// return: error - the error if any
+func (st *Stack) review(ctx context.Context, csName string) (bool, error) {
+	changes, err := st.describeChanges(ctx, csName)
+	if err != nil {
+		return false, err
+	}
+
+	reviewLock.Lock()
+	defer reviewLock.Unlock()
+
+	critical := st.logChanges(changes)
+	if st.confirm == nil {
+		return true, nil
+	}
+
+	question := fmt.Sprintf("Execute change set %s on stack %s?", csName, *st.GetStackName())
+	if len(critical) > 0 {
+		question = fmt.Sprintf("Change set %s REPLACES %s on stack %s. Execute it anyway?",
+			csName, strings.Join(critical, ", "), *st.GetStackName())
+	}
+	return st.confirm(ctx, question)
+}
+
+// logChanges prints the changes of a change set
+// param: changes - the changes to print
+// return: []string - the critical resources that would be replaced
+func (st *Stack) logChanges(changes []types.Change) []string {
+	critical := make([]string, 0)
+
+	logger.Info("Changes for stack %s:", *st.GetStackName())
+	if len(changes) == 0 {
+		logger.Info("  no resource changes")
+	}
+	for _, change := range changes {
+		rc := change.ResourceChange
+		if rc == nil {
+			continue
+		}
+
+		logicalId := aws.ToString(rc.LogicalResourceId)
+		line := "  " + changeLine(rc)
+
+		switch {
+		case st.IsCritical(logicalId) && replaces(rc):
+			critical = append(critical, logicalId)
+			logger.Error("%s", line)
+			verb := "replaced"
+			if rc.Action == types.ChangeActionRemove {
+				verb = "removed"
+			}
+			logger.Error("  !!! %s (%s) will be %s: the site will be broken until the deployment completes",
+				logicalId, aws.ToString(rc.ResourceType), verb)
+		case replaces(rc):
+			logger.Warn("%s", line)
+		default:
+			logger.Info("%s", line)
+		}
+	}
+	return critical
+}
+
+// changeLine describes a resource change on one line
+// param: rc - the resource change
+// return: string - the action, logical id, type and replacement of the resource
+func changeLine(rc *types.ResourceChange) string {
+	line := fmt.Sprintf("%-8s %-30s %-40s replacement: %s",
+		rc.Action, aws.ToString(rc.LogicalResourceId), aws.ToString(rc.ResourceType), replacement(rc))
+	if triggers := replacementTriggers(rc); len(triggers) > 0 {
+		line = fmt.Sprintf("%s (caused by %s)", line, strings.Join(triggers, ", "))
+	}
+	return line
+}
+
+// replaces returns true if the resource change deletes the resource or may recreate it
+func replaces(rc *types.ResourceChange) bool {
+	if rc.Action == types.ChangeActionRemove {
+		return true
+	}
+	return rc.Action == types.ChangeActionModify && rc.Replacement != types.ReplacementFalse
+}
+
+// replacement returns the replacement flag of a resource change
+func replacement(rc *types.ResourceChange) string {
+	if rc.Replacement == "" {
+		return "-"
+	}
+	return string(rc.Replacement)
+}
+
+// replacementTriggers returns the properties whose change requires the resource to be recreated
+func replacementTriggers(rc *types.ResourceChange) []string {
+	seen := make(map[string]bool)
+	for _, detail := range rc.Details {
+		target := detail.Target
+		if target == nil || target.Name == nil || target.RequiresRecreation == "" ||
+			target.RequiresRecreation == types.RequiresRecreationNever {
+			continue
+		}
+		seen[*target.Name] = true
+	}
+
+	triggers := make([]string, 0, len(seen))
+	for name := range seen {
+		triggers = append(triggers, name)
+	}
+	sort.Strings(triggers)
+	return triggers
+}
@@ -0,0 +1,119 @@
+package stack
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// mockCFNReview reports a change set that replaces the bucket and records the deleted change sets
+type mockCFNReview struct {
+	mockCFNRun
+	deleted  []string
+	executed bool
+}
+
+func (m *mockCFNReview) DescribeChangeSet(ctx context.Context, params *cloudformation.DescribeChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeChangeSetOutput, error) {
+	return &cloudformation.DescribeChangeSetOutput{
+		Status: types.ChangeSetStatusCreateComplete,
+		Changes: []types.Change{{
+			ResourceChange: &types.ResourceChange{
+				Action:            types.ChangeActionModify,
+				LogicalResourceId: aws.String("bucket"),
+				ResourceType:      aws.String("AWS::S3::Bucket"),
+				Replacement:       types.ReplacementTrue,
+				Details: []types.ResourceChangeDetail{{
+					Target: &types.ResourceTargetDefinition{
+						Attribute:          types.ResourceAttributeProperties,
+						Name:               aws.String("BucketName"),
+						RequiresRecreation: types.RequiresRecreationAlways,
+					},
+				}},
+			},
+		}},
+	}, nil
+}
+
+func (m *mockCFNReview) DeleteChangeSet(ctx context.Context, params *cloudformation.DeleteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error) {
+	m.deleted = append(m.deleted, *params.ChangeSetName)
+	return &cloudformation.DeleteChangeSetOutput{}, nil
+}
+
+func (m *mockCFNReview) ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
+	m.executed = true
+	return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+func TestPrompt(t *testing.T) {
+	var out bytes.Buffer
+	confirm := Prompt(strings.NewReader("y\nno\n"), &out)
+
+	ok, err := confirm(context.Background(), "first?")
+	if err != nil || !ok {
+		t.Errorf("Expected approval, got %v, %v", ok, err)
+	}
+	ok, err = confirm(context.Background(), "second?")
+	if err != nil || ok {
+		t.Errorf("Expected rejection, got %v, %v", ok, err)
+	}
+	ok, err = confirm(context.Background(), "third?")
+	if err != nil || ok {
+		t.Errorf("Expected rejection on end of input, got %v, %v", ok, err)
+	}
+	if !strings.Contains(out.String(), "second? [y/N]") {
+		t.Errorf("Expected the question to be printed, got %q", out.String())
+	}
+}
+
+func TestStack_Run_Rejected(t *testing.T) {
+	mock := &mockCFNReview{}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	var question string
+	stk.SetConfirm(func(ctx context.Context, q string) (bool, error) {
+		question = q
+		return false, nil
+	})
+
+	err := stk.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not approved") {
+		t.Errorf("Expected 'not approved' error, got %v", err)
+	}
+	if mock.executed {
+		t.Error("Rejected change set should not be executed")
+	}
+	if len(mock.deleted) != 1 {
+		t.Errorf("Expected the rejected change set to be deleted, got %v", mock.deleted)
+	}
+	if !strings.Contains(question, "REPLACES bucket") {
+		t.Errorf("Expected the question to highlight the bucket replacement, got %q", question)
+	}
+}
+
+func TestReplacementTriggers(t *testing.T) {
+	rc := &types.ResourceChange{
+		Action:      types.ChangeActionModify,
+		Replacement: types.ReplacementConditional,
+		Details: []types.ResourceChangeDetail{
+			{Target: &types.ResourceTargetDefinition{Name: aws.String("Tags"), RequiresRecreation: types.RequiresRecreationNever}},
+			{Target: &types.ResourceTargetDefinition{Name: aws.String("Origins"), RequiresRecreation: types.RequiresRecreationConditionally}},
+			{Target: &types.ResourceTargetDefinition{Name: aws.String("BucketName"), RequiresRecreation: types.RequiresRecreationAlways}},
+			{Target: &types.ResourceTargetDefinition{Name: aws.String("BucketName"), RequiresRecreation: types.RequiresRecreationAlways}},
+		},
+	}
+
+	triggers := replacementTriggers(rc)
+	if strings.Join(triggers, ",") != "BucketName,Origins" {
+		t.Errorf("Expected 'BucketName,Origins', got %v", triggers)
+	}
+	if !replaces(rc) {
+		t.Error("Conditional replacement should be reported as a replacement")
+	}
+}
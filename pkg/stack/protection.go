@@ -0,0 +1,178 @@
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// policyRestoreTimeout is the maximum duration of the restoration of the default stack policy
+const policyRestoreTimeout = time.Minute
+
+// policyStatement is a statement of a CloudFormation stack policy
+type policyStatement struct {
+	Effect    string   `json:"Effect"`
+	Action    []string `json:"Action"`
+	Principal string   `json:"Principal"`
+	Resource  []string `json:"Resource"`
+}
+
+// stackPolicy is a CloudFormation stack policy
+type stackPolicy struct {
+	Statement []policyStatement `json:"Statement"`
+}
+
+// SetTags sets the tags of the stack, propagated by CloudFormation to the resources
+// param: tags - the tags indexed by key
+func (st *Stack) SetTags(tags map[string]string) {
+	st.tags = tags
+}
+
+// SetAllowReplace allows the next change sets to replace or delete the critical resources
+func (st *Stack) SetAllowReplace(allow bool) {
+	st.allowReplace = allow
+}
+
+// stackTags returns the tags of the stack sorted by key
+// return: []types.Tag - the tags
+func (st *Stack) stackTags() []types.Tag {
+	keys := make([]string, 0, len(st.tags))
+	for key := range st.tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tags := make([]types.Tag, 0, len(keys))
+	for _, key := range keys {
+		tags = append(tags, types.Tag{Key: aws.String(key), Value: aws.String(st.tags[key])})
+	}
+	return tags
+}
+
+// criticalResources returns the resources of the template marked as critical
+// return: []string - the logical ids, sorted
+func (st *Stack) criticalResources() []string {
+	names := make([]string, 0)
+	for name := range st.Build().Resources {
+		if st.IsCritical(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// policy returns the stack policy denying the replacement and deletion of the critical resources
+// param: allowReplace - true to allow every update for the duration of one change set
+// return: string - the policy document
+// return: error - the error if any
+func (st *Stack) policy(allowReplace bool) (string, error) {
+	policy := stackPolicy{
+		Statement: []policyStatement{{
+			Effect:    "Allow",
+			Action:    []string{"Update:*"},
+			Principal: "*",
+			Resource:  []string{"*"},
+		}},
+	}
+
+	critical := st.criticalResources()
+	if !allowReplace && len(critical) > 0 {
+		resources := make([]string, 0, len(critical))
+		for _, name := range critical {
+			resources = append(resources, "LogicalResourceId/"+name)
+		}
+		policy.Statement = append(policy.Statement, policyStatement{
+			Effect:    "Deny",
+			Action:    []string{"Update:Replace", "Update:Delete"},
+			Principal: "*",
+			Resource:  resources,
+		})
+	}
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// setPolicy sets the stack policy of the stack
+// param: allowReplace - true to allow the replacement of the critical resources
+// return: error - the error if any
+func (st *Stack) setPolicy(ctx context.Context, allowReplace bool) error {
+	policy, err := st.policy(allowReplace)
+	if err != nil {
+		return err
+	}
+	_, err = st.cloudFormationClient.SetStackPolicy(ctx, &cloudformation.SetStackPolicyInput{
+		StackName:       st.GetStackName(),
+		StackPolicyBody: &policy,
+	})
+	return err
+}
+
+// setTerminationProtection enables or disables the termination protection of the stack
+// param: enabled - true to protect the stack from deletion
+// return: error - the error if any
+func (st *Stack) setTerminationProtection(ctx context.Context, enabled bool) error {
+	_, err := st.cloudFormationClient.UpdateTerminationProtection(ctx, &cloudformation.UpdateTerminationProtectionInput{
+		StackName:                   st.GetStackName(),
+		EnableTerminationProtection: &enabled,
+	})
+	return err
+}
+
+// execute executes a change set under the protection of the stack policy
+// The critical resources can only be replaced during this change set if replacement was allowed
+// A newly created (or imported) stack gets termination protection and the default stack policy
+// param: csName - the name of the changeset
+// param: csType - the type of the changeset (CREATE, UPDATE or IMPORT)
+// return: error - the error if any
+func (st *Stack) execute(ctx context.Context, csName string, csType string) (err error) {
+	created := csType == "CREATE" || csType == "IMPORT"
+	if !created {
+		if st.allowReplace {
+			logger.Warn("Allowing the replacement of %v on stack %s", st.criticalResources(), *st.GetStackName())
+		}
+		if err := st.setPolicy(ctx, st.allowReplace); err != nil {
+			return err
+		}
+		if st.allowReplace {
+			// restore the default policy even if the execution fails or is interrupted (ctx cancelled)
+			defer func() {
+				restoreCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), policyRestoreTimeout)
+				defer cancel()
+				if perr := st.setPolicy(restoreCtx, false); perr != nil {
+					logger.Error("Unable to restore the stack policy of stack %s, critical resources can be replaced: %v", *st.GetStackName(), perr)
+					if err == nil {
+						err = perr
+					}
+				}
+			}()
+		}
+	}
+
+	if err := st.executeChangeSet(ctx, csName, csType); err != nil {
+		return err
+	}
+	// the outputs may have changed with the stack, they are read again when needed
+	st.outputsLock.Lock()
+	clear(st.Outputs)
+	st.outputsLock.Unlock()
+
+	if created {
+		logger.Info("Enabling termination protection on stack %s", *st.GetStackName())
+		if err := st.setTerminationProtection(ctx, true); err != nil {
+			return err
+		}
+		return st.setPolicy(ctx, false)
+	}
+	return nil
+}
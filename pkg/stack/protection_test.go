@@ -0,0 +1,130 @@
+package stack
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// mockCFNProtection records the stack policies and the termination protection
+type mockCFNProtection struct {
+	mockCFNPlan
+	policies   []string
+	protection *bool
+	// interrupt is called when the change set is executed, e.g. to cancel the context
+	interrupt func()
+}
+
+func (m *mockCFNProtection) SetStackPolicy(ctx context.Context, params *cloudformation.SetStackPolicyInput, optFns ...func(*cloudformation.Options)) (*cloudformation.SetStackPolicyOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.policies = append(m.policies, *params.StackPolicyBody)
+	return &cloudformation.SetStackPolicyOutput{}, nil
+}
+
+func (m *mockCFNProtection) UpdateTerminationProtection(ctx context.Context, params *cloudformation.UpdateTerminationProtectionInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateTerminationProtectionOutput, error) {
+	m.protection = params.EnableTerminationProtection
+	return &cloudformation.UpdateTerminationProtectionOutput{}, nil
+}
+
+func (m *mockCFNProtection) ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
+	if m.interrupt != nil {
+		m.interrupt()
+	}
+	m.executed = true
+	return &cloudformation.ExecuteChangeSetOutput{}, nil
+}
+
+func TestStack_Policy(t *testing.T) {
+	stk := NewStack(bucketTemplate{newBucketTemplate()})
+	stk.Template.(bucketTemplate).MarkCritical("bucket")
+
+	policy, err := stk.policy(false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(policy, `"Effect":"Deny","Action":["Update:Replace","Update:Delete"],"Principal":"*","Resource":["LogicalResourceId/bucket"]`) {
+		t.Errorf("Expected the bucket to be protected, got %s", policy)
+	}
+
+	policy, err = stk.policy(true)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Contains(policy, "Deny") {
+		t.Errorf("Expected no deny statement when replacement is allowed, got %s", policy)
+	}
+}
+
+func TestStack_Execute_Create(t *testing.T) {
+	mock := &mockCFNProtection{mockCFNPlan: mockCFNPlan{status: "CREATE_COMPLETE"}}
+	stk := NewStack(bucketTemplate{newBucketTemplate()})
+	stk.Template.(bucketTemplate).MarkCritical("bucket")
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+
+	if err := stk.execute(context.Background(), "cs", "CREATE"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !aws.ToBool(mock.protection) {
+		t.Error("Expected termination protection to be enabled after the creation")
+	}
+	if len(mock.policies) != 1 || !strings.Contains(mock.policies[0], "Deny") {
+		t.Errorf("Expected the default policy to be set, got %v", mock.policies)
+	}
+}
+
+func TestStack_Execute_AllowReplace(t *testing.T) {
+	mock := &mockCFNProtection{mockCFNPlan: mockCFNPlan{status: "UPDATE_COMPLETE"}}
+	stk := NewStack(bucketTemplate{newBucketTemplate()})
+	stk.Template.(bucketTemplate).MarkCritical("bucket")
+	stk.SetClock(newFakeClock())
+	stk.SetAllowReplace(true)
+	stk.cloudFormationClient = mock
+
+	if err := stk.execute(context.Background(), "cs", "UPDATE"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(mock.policies) != 2 || strings.Contains(mock.policies[0], "Deny") || !strings.Contains(mock.policies[1], "Deny") {
+		t.Errorf("Expected a temporary policy followed by the default one, got %v", mock.policies)
+	}
+	if mock.protection != nil {
+		t.Error("Termination protection should only be changed on creation")
+	}
+}
+
+func TestStack_Execute_AllowReplaceInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mock := &mockCFNProtection{mockCFNPlan: mockCFNPlan{status: "UPDATE_IN_PROGRESS"}, interrupt: cancel}
+	stk := NewStack(bucketTemplate{newBucketTemplate()})
+	stk.Template.(bucketTemplate).MarkCritical("bucket")
+	stk.SetClock(newFakeClock())
+	stk.SetAllowReplace(true)
+	stk.cloudFormationClient = mock
+
+	if err := stk.execute(ctx, "cs", "UPDATE"); err == nil {
+		t.Fatal("Expected the interrupted execution to fail")
+	}
+	if len(mock.policies) != 2 || !strings.Contains(mock.policies[1], "Deny") {
+		t.Errorf("Expected the default policy to be restored after the interruption, got %v", mock.policies)
+	}
+}
+
+func TestStack_Tags(t *testing.T) {
+	mock := &mockCFNTemplate{}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.cloudFormationClient = mock
+	stk.SetTags(map[string]string{"team": "web", "environment": "prod"})
+
+	if _, _, err := stk.initialChangeSet(context.Background(), "{}", false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	tags := mock.input.Tags
+	if len(tags) != 2 || *tags[0].Key != "environment" || *tags[1].Key != "team" || *tags[1].Value != "web" {
+		t.Errorf("Expected the tags sorted by key, got %v", tags)
+	}
+}
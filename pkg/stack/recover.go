@@ -0,0 +1,164 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+// describe returns the current description of the stack
+// return: *types.Stack - the stack or nil if the stack does not exist
+// return: error - the error if any
+func (st *Stack) describe(ctx context.Context) (*types.Stack, error) {
+	resp, err := st.cloudFormationClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: st.GetStackName(),
+	})
+	if notExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Stacks) == 0 {
+		return nil, nil
+	}
+	return &resp.Stacks[0], nil
+}
+
+// prepare brings the stack in a state that accepts a change set
+// Stacks that never got created are deleted, failed rollbacks are continued and
+// operations left in progress by an interrupted run are waited out
+// param: repair - false to only report the stacks that need a deletion or a rollback, leaving them unchanged (plan)
+// return: bool - true if the stack exists and must be updated, false if it must be created
+// return: error - the error if any
+func (st *Stack) prepare(ctx context.Context, repair bool) (bool, error) {
+	for {
+		desc, err := st.describe(ctx)
+		if err != nil {
+			return false, err
+		}
+		if desc == nil {
+			return false, nil
+		}
+
+		status := desc.StackStatus
+		switch {
+		case status == types.StackStatusReviewInProgress:
+			// created by a change set that was never executed
+			return false, nil
+
+		case strings.HasSuffix(string(status), "_IN_PROGRESS"):
+			logger.Info("Stack %s is in %s, waiting for the operation to finish", *st.GetStackName(), status)
+			if err := st.waitForStable(ctx); err != nil {
+				return false, err
+			}
+
+		case !repair && (status == types.StackStatusRollbackComplete ||
+			status == types.StackStatusRollbackFailed ||
+			status == types.StackStatusCreateFailed ||
+			status == types.StackStatusUpdateRollbackFailed):
+			return false, fmt.Errorf("stack %s is in %s (%s): recover it with haws deploy, then plan again",
+				*st.GetStackName(), status, aws.ToString(desc.StackStatusReason))
+
+		case status == types.StackStatusRollbackComplete ||
+			status == types.StackStatusRollbackFailed ||
+			status == types.StackStatusCreateFailed:
+			if err := st.recreate(ctx, desc); err != nil {
+				return false, err
+			}
+			return false, nil
+
+		case status == types.StackStatusUpdateRollbackFailed:
+			if err := st.continueRollback(ctx, desc); err != nil {
+				return false, err
+			}
+
+		case status == types.StackStatusDeleteFailed:
+			return false, fmt.Errorf("stack %s is in %s (%s): delete it before deploying again",
+				*st.GetStackName(), status, aws.ToString(desc.StackStatusReason))
+
+		default:
+			return true, nil
+		}
+	}
+}
+
+// recreate deletes a stack that was never created successfully, so it can be created again
+// param: desc - the description of the stack
+// return: error - the error if any
+func (st *Stack) recreate(ctx context.Context, desc *types.Stack) error {
+	logger.Warn("Stack %s was never created successfully: %s (%s)",
+		*st.GetStackName(), desc.StackStatus, aws.ToString(desc.StackStatusReason))
+
+	if st.confirm != nil {
+		reviewLock.Lock()
+		ok, err := st.confirm(ctx, fmt.Sprintf("Stack %s is in %s and cannot be updated. Delete and recreate it?",
+			*st.GetStackName(), desc.StackStatus))
+		reviewLock.Unlock()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("stack %s is in %s and cannot be updated", *st.GetStackName(), desc.StackStatus)
+		}
+	}
+	return st.Delete(ctx)
+}
+
+// continueRollback resumes a rollback that failed and waits for it to finish
+// param: desc - the description of the stack
+// return: error - the error if any
+func (st *Stack) continueRollback(ctx context.Context, desc *types.Stack) error {
+	logger.Warn("Stack %s is in %s (%s), continuing the rollback",
+		*st.GetStackName(), desc.StackStatus, aws.ToString(desc.StackStatusReason))
+
+	_, err := st.cloudFormationClient.ContinueUpdateRollback(ctx, &cloudformation.ContinueUpdateRollbackInput{
+		StackName: st.GetStackName(),
+		RoleARN:   st.role(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := st.waitForStable(ctx); err != nil {
+		return err
+	}
+
+	desc, err = st.describe(ctx)
+	if err != nil {
+		return err
+	}
+	if desc != nil && desc.StackStatus == types.StackStatusUpdateRollbackFailed {
+		return fmt.Errorf("the rollback of stack %s failed again (%s): fix or skip the failing resources manually",
+			*st.GetStackName(), aws.ToString(desc.StackStatusReason))
+	}
+	return nil
+}
+
+// waitForStable waits until the stack is not in an _IN_PROGRESS state anymore
+// return: error - the error if any
+func (st *Stack) waitForStable(ctx context.Context) error {
+	events := st.newEventStream("", time.Now())
+
+	return st.newWaiter(st.timeout()).Wait(ctx, "stack operation", func(ctx context.Context) (bool, error) {
+		desc, err := st.describe(ctx)
+		if err != nil {
+			return false, err
+		}
+		if desc == nil {
+			return true, nil
+		}
+
+		if err := events.poll(ctx); err != nil {
+			logger.Debug("Unable to read events for stack %s: %s", *st.GetStackName(), err)
+		}
+
+		return !strings.HasSuffix(string(desc.StackStatus), "_IN_PROGRESS") ||
+			desc.StackStatus == types.StackStatusReviewInProgress, nil
+	})
+}
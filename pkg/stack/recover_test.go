@@ -0,0 +1,125 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// mockCFNStates returns the given stack states one after another (the last one repeats)
+// An empty state means the stack does not exist
+type mockCFNStates struct {
+	mockCFNRun
+	states    []types.StackStatus
+	calls     int
+	deleted   bool
+	continued bool
+}
+
+func (m *mockCFNStates) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	state := m.states[len(m.states)-1]
+	if m.calls < len(m.states) {
+		state = m.states[m.calls]
+	}
+	m.calls++
+	if state == "" {
+		return nil, fmt.Errorf("Stack with id %s does not exist", *params.StackName)
+	}
+	return &cloudformation.DescribeStacksOutput{
+		Stacks: []types.Stack{{StackStatus: state}},
+	}, nil
+}
+
+func (m *mockCFNStates) DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	m.deleted = true
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+
+func (m *mockCFNStates) ContinueUpdateRollback(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error) {
+	m.continued = true
+	return &cloudformation.ContinueUpdateRollbackOutput{}, nil
+}
+
+func TestStack_Prepare(t *testing.T) {
+	testCases := []struct {
+		name      string
+		states    []types.StackStatus
+		exists    bool
+		deleted   bool
+		continued bool
+	}{
+		{"missing stack is created", []types.StackStatus{""}, false, false, false},
+		{"complete stack is updated", []types.StackStatus{types.StackStatusUpdateComplete}, true, false, false},
+		{"review stack is created", []types.StackStatus{types.StackStatusReviewInProgress}, false, false, false},
+		{"rolled back creation is recreated", []types.StackStatus{types.StackStatusRollbackComplete, types.StackStatusDeleteInProgress, ""}, false, true, false},
+		{"failed rollback is continued", []types.StackStatus{types.StackStatusUpdateRollbackFailed, types.StackStatusUpdateRollbackInProgress, types.StackStatusUpdateRollbackComplete}, true, false, true},
+		{"in progress update is waited out", []types.StackStatus{types.StackStatusUpdateInProgress, types.StackStatusUpdateComplete}, true, false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockCFNStates{states: tc.states}
+			stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+			stk.SetClock(newFakeClock())
+			stk.cloudFormationClient = mock
+
+			exists, err := stk.prepare(context.Background(), true)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if exists != tc.exists {
+				t.Errorf("Expected exists to be %v, got %v", tc.exists, exists)
+			}
+			if mock.deleted != tc.deleted {
+				t.Errorf("Expected deleted to be %v, got %v", tc.deleted, mock.deleted)
+			}
+			if mock.continued != tc.continued {
+				t.Errorf("Expected continued to be %v, got %v", tc.continued, mock.continued)
+			}
+		})
+	}
+}
+
+func TestStack_Prepare_RecreateRejected(t *testing.T) {
+	mock := &mockCFNStates{states: []types.StackStatus{types.StackStatusRollbackComplete}}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.SetClock(newFakeClock())
+	stk.cloudFormationClient = mock
+	stk.SetConfirm(func(ctx context.Context, question string) (bool, error) {
+		return false, nil
+	})
+
+	_, err := stk.prepare(context.Background(), true)
+	if err == nil || !strings.Contains(err.Error(), "cannot be updated") {
+		t.Errorf("Expected 'cannot be updated' error, got %v", err)
+	}
+	if mock.deleted {
+		t.Error("Stack should not be deleted without approval")
+	}
+}
+
+func TestStack_Prepare_ReadOnly(t *testing.T) {
+	for _, state := range []types.StackStatus{
+		types.StackStatusRollbackComplete,
+		types.StackStatusRollbackFailed,
+		types.StackStatusCreateFailed,
+		types.StackStatusUpdateRollbackFailed,
+	} {
+		mock := &mockCFNStates{states: []types.StackStatus{state}}
+		stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+		stk.SetClock(newFakeClock())
+		stk.cloudFormationClient = mock
+
+		_, err := stk.prepare(context.Background(), false)
+		if err == nil || !strings.Contains(err.Error(), "recover it with haws deploy") {
+			t.Errorf("Expected %s to be reported, got %v", state, err)
+		}
+		if mock.deleted || mock.continued {
+			t.Errorf("Expected the stack in %s to be left unchanged", state)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// Reference is a parameter whose value is an output of another stack
+// It links stacks that cannot use Fn::ImportValue, because they are deployed in different regions
+// (e.g. the us-east-1 certificate used by the distribution)
+type Reference struct {
+	Parameter string
+	Stack     string
+	Output    string
+}
+
+// AddReference declares a parameter whose value is an output of another stack
+// The other stack becomes a dependency of this one
+// param: parameter - the name of the parameter of this template
+// param: stack - the name of the stack producing the value (certificate, bucket, cloudfront, user)
+// param: output - the name of the output of that stack
+func (t *TemplateComponent) AddReference(parameter string, stack string, output string) {
+	t.References = append(t.References, Reference{
+		Parameter: parameter,
+		Stack:     stack,
+		Output:    output,
+	})
+	for _, dep := range t.Dependencies {
+		if dep == stack {
+			return
+		}
+	}
+	t.AddDependency(stack)
+}
+
+// GetReferences returns the parameters whose values are outputs of other stacks
+// return: []Reference - the references
+func (t *TemplateComponent) GetReferences() []Reference {
+	return t.References
+}
+
+// Output returns an output of the deployed stack, reading the outputs of the stack the first time
+// param: name - the name of the output
+// return: string - the value of the output
+// return: error - the error if the stack or the output does not exist
+func (st *Stack) Output(ctx context.Context, name string) (string, error) {
+	st.outputsLock.Lock()
+	defer st.outputsLock.Unlock()
+
+	if value, ok := st.Outputs[name]; ok {
+		return value, nil
+	}
+	if err := st.readOutputs(ctx); err != nil {
+		return "", err
+	}
+	if value, ok := st.Outputs[name]; ok {
+		return value, nil
+	}
+	return "", fmt.Errorf("stack %s has no output %s", *st.GetStackName(), name)
+}
+
+// OutputOf returns an output of another stack of the region of this stack
+// param: stackName - the name of the other stack
+// param: name - the name of the output
+// return: string - the value of the output, empty if the stack or the output does not exist
+// return: error - the error if any
+func (st *Stack) OutputOf(ctx context.Context, stackName string, name string) (string, error) {
+	if err := st.ensureClient(ctx); err != nil {
+		return "", err
+	}
+	resp, err := st.cloudFormationClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: &stackName,
+	})
+	if notExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, desc := range resp.Stacks {
+		for _, output := range desc.Outputs {
+			if aws.ToString(output.OutputKey) == name {
+				return aws.ToString(output.OutputValue), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// OutputResources returns the resources of the template the value of an output depends on
+// param: name - the name of the output
+// return: []string - the logical ids of the resources, sorted
+// return: error - the error if the template cannot be built or has no such output
+func (st *Stack) OutputResources(name string) ([]string, error) {
+	body, err := st.templateJson()
+	if err != nil {
+		return nil, err
+	}
+	var template struct {
+		Resources map[string]interface{}
+		Outputs   map[string]struct {
+			Value interface{}
+		}
+	}
+	if err := json.Unmarshal([]byte(body), &template); err != nil {
+		return nil, err
+	}
+	output, ok := template.Outputs[name]
+	if !ok {
+		return nil, fmt.Errorf("stack %s has no output %s", *st.GetStackName(), name)
+	}
+
+	refs := make(map[string]bool)
+	resourceRefs(output.Value, template.Resources, refs)
+	return sortedNames(refs), nil
+}
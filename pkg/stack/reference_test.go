@@ -0,0 +1,68 @@
+package stack
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	cfn "github.com/awslabs/goformation/v4/cloudformation"
+	"github.com/awslabs/goformation/v4/cloudformation/s3"
+)
+
+func TestTemplate_AddReference(t *testing.T) {
+	tmpl := NewTemplate("eu-central-1")
+	tmpl.AddDependency("bucket")
+	tmpl.AddReference("CertificateArn", "certificate", "Arn")
+	tmpl.AddReference("BucketName", "bucket", "Name")
+
+	refs := tmpl.GetReferences()
+	if len(refs) != 2 || refs[0] != (Reference{Parameter: "CertificateArn", Stack: "certificate", Output: "Arn"}) {
+		t.Errorf("Unexpected references %v", refs)
+	}
+	deps := tmpl.GetDependencies()
+	if len(deps) != 2 || deps[0] != "bucket" || deps[1] != "certificate" {
+		t.Errorf("Expected each referenced stack to be a dependency once, got %v", deps)
+	}
+}
+
+func TestStack_Output(t *testing.T) {
+	mock := &mockCFNSuccess{}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.cloudFormationClient = mock
+
+	value, err := stk.Output(context.Background(), "mock-key")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if value != "mock-value" {
+		t.Errorf("Expected 'mock-value', got %q", value)
+	}
+
+	_, err = stk.Output(context.Background(), "missing")
+	if err == nil || !strings.Contains(err.Error(), "has no output missing") {
+		t.Errorf("Expected 'has no output' error, got %v", err)
+	}
+}
+
+func TestStack_OutputResources(t *testing.T) {
+	tmpl := newBucketTemplate()
+	tmpl.AddParameter("Suffix", cfn.Parameter{Type: "String"}, "site")
+	tmpl.AddResource("policy", &s3.BucketPolicy{Bucket: cfn.Ref("bucket")})
+	tmpl.AddOutput("Domain", cfn.Output{Value: cfn.GetAtt("bucket", "DomainName")}, "mock")
+	tmpl.AddOutput("Label", cfn.Output{Value: cfn.Sub("${bucket}-${policy}-${Suffix}-${AWS::Region}")}, "mock")
+	stk := NewStack(bucketTemplate{tmpl})
+
+	for output, expected := range map[string][]string{"Domain": {"bucket"}, "Label": {"bucket", "policy"}, "Name": {}} {
+		resources, err := stk.OutputResources(output)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !reflect.DeepEqual(resources, expected) {
+			t.Errorf("Expected resources %v behind output %s, got %v", expected, output, resources)
+		}
+	}
+	if _, err := stk.OutputResources("missing"); err == nil {
+		t.Error("Expected an error for a missing output")
+	}
+}
@@ -3,6 +3,8 @@ package stack
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -20,63 +22,209 @@ type CloudFormationAPI interface {
 	DeleteChangeSet(ctx context.Context, params *cloudformation.DeleteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteChangeSetOutput, error)
 	ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error)
 	DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+	DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error)
+	DescribeStackResource(ctx context.Context, params *cloudformation.DescribeStackResourceInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceOutput, error)
+	DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error)
+	ListImports(ctx context.Context, params *cloudformation.ListImportsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error)
+	ContinueUpdateRollback(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error)
+	DetectStackDrift(ctx context.Context, params *cloudformation.DetectStackDriftInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DetectStackDriftOutput, error)
+	DescribeStackDriftDetectionStatus(ctx context.Context, params *cloudformation.DescribeStackDriftDetectionStatusInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error)
+	DescribeStackResourceDrifts(ctx context.Context, params *cloudformation.DescribeStackResourceDriftsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceDriftsOutput, error)
+	UpdateTerminationProtection(ctx context.Context, params *cloudformation.UpdateTerminationProtectionInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateTerminationProtectionOutput, error)
+	SetStackPolicy(ctx context.Context, params *cloudformation.SetStackPolicyInput, optFns ...func(*cloudformation.Options)) (*cloudformation.SetStackPolicyOutput, error)
 }
 
 type Stack struct {
 	Template
 	cloudFormationClient CloudFormationAPI
+	confirm              ConfirmFunc
+	clock                Clock
+	maxWait              time.Duration
+	staging              *Staging
+	outputsLock          sync.Mutex
+	tags                 map[string]string
+	allowReplace         bool
+	roleArn              string
 	Outputs             map[string]string
 }
 
 func NewStack(template Template) *Stack {
 	return &Stack{
 		Template: template,
+		clock:    realClock{},
 		Outputs:  make(map[string]string),
 	}
 }
 
-// Run creates or updates the stack
-func (st *Stack) Run(ctx context.Context) error {
+// SetClock sets the clock used while waiting for stack operations
+func (st *Stack) SetClock(clock Clock) {
+	st.clock = clock
+}
+
+// SetTimeout overrides the maximum duration of the stack operations declared by the template
+func (st *Stack) SetTimeout(timeout time.Duration) {
+	st.maxWait = timeout
+}
+
+// timeout returns the maximum duration of a stack operation
+func (st *Stack) timeout() time.Duration {
+	if st.maxWait > 0 {
+		return st.maxWait
+	}
+	if t := st.GetTimeout(); t > 0 {
+		return t
+	}
+	return DefaultTimeout
+}
+
+// newWaiter creates a waiter using the clock of the stack
+func (st *Stack) newWaiter(maxDuration time.Duration) *Waiter {
+	return NewWaiter(maxDuration, st.clock)
+}
+
+// awsConfig loads the SDK config for the region of the stack
+// return: aws.Config - the config
+// return: error - the error if any
+func (st *Stack) awsConfig(ctx context.Context) (aws.Config, error) {
 	var cfg aws.Config
 	var err error
+	if st.GetRegion() != "" {
+		cfg, err = config.LoadDefaultConfig(ctx, config.WithRegion(st.GetRegion()))
+	} else {
+		cfg, err = config.LoadDefaultConfig(ctx)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("unable to load SDK config: %w", err)
+	}
+	return cfg, nil
+}
 
-	if st.cloudFormationClient == nil {
-		if st.GetRegion() != "" {
-			cfg, err = config.LoadDefaultConfig(ctx, config.WithRegion(st.GetRegion()))
-		} else {
-			cfg, err = config.LoadDefaultConfig(ctx)
-		}
-		if err != nil {
-			return fmt.Errorf("unable to load SDK config: %w", err)
-		}
-		st.cloudFormationClient = cloudformation.NewFromConfig(cfg)
+// ensureClient creates the CloudFormation client for the region of the stack if it was not set already
+func (st *Stack) ensureClient(ctx context.Context) error {
+	if st.cloudFormationClient != nil {
+		return nil
 	}
 
-	templateBody, err := st.templateJson()
+	cfg, err := st.awsConfig(ctx)
 	if err != nil {
 		return err
 	}
+	st.cloudFormationClient = cloudformation.NewFromConfig(cfg)
+	return nil
+}
+
+// SetCloudFormationClient sets the client operating on the stack instead of the one created from the SDK config
+func (st *Stack) SetCloudFormationClient(client CloudFormationAPI) {
+	st.cloudFormationClient = client
+}
+
+// SetRoleArn sets the service role CloudFormation assumes to operate on the stack
+// An empty ARN uses the credentials of the caller
+func (st *Stack) SetRoleArn(roleArn string) {
+	st.roleArn = roleArn
+}
+
+// role returns the service role of the stack, or nil to use the credentials of the caller
+func (st *Stack) role() *string {
+	if st.roleArn == "" {
+		return nil
+	}
+	return &st.roleArn
+}
+
+// SetConfirm sets the function used to approve the change sets before they are executed
+// A nil function approves every change set without asking
+func (st *Stack) SetConfirm(confirm ConfirmFunc) {
+	st.confirm = confirm
+}
+
+// Sibling returns the stack of another template of the region, operating with the client, the clock,
+// the role and the confirmation of this stack
+// param: template - the template of the other stack
+// return: *Stack - the other stack
+func (st *Stack) Sibling(template Template) *Stack {
+	sibling := NewStack(template)
+	sibling.cloudFormationClient = st.cloudFormationClient
+	sibling.confirm = st.confirm
+	sibling.clock = st.clock
+	sibling.maxWait = st.maxWait
+	sibling.roleArn = st.roleArn
+	return sibling
+}
+
+// Run creates or updates the stack
+// It returns ErrNoChanges if the deployed stack already matches the template
+func (st *Stack) Run(ctx context.Context) error {
+	if err := st.ensureClient(ctx); err != nil {
+		return err
+	}
 
-	csName, csType, err := st.initialChangeSet(ctx, templateBody)
+	csName, csType, err := st.createChangeSet(ctx, true)
 	if err != nil {
 		return err
 	}
 
-	ok, err := st.waitForChangeSet(ctx, csName)
+	return st.reviewAndExecute(ctx, csName, csType)
+}
+
+// reviewAndExecute asks for the approval of a change set and executes it, or deletes it if rejected
+// param: csName - the name of the change set
+// param: csType - the type of the change set (CREATE, UPDATE or IMPORT)
+// return: error - the error if any
+func (st *Stack) reviewAndExecute(ctx context.Context, csName string, csType string) error {
+	approved, err := st.review(ctx, csName)
 	if err != nil {
 		return err
 	}
-	if ok {
-		return nil
+	if !approved {
+		logger.Info("Deleting rejected changeset %s", csName)
+		if _, err := st.cloudFormationClient.DeleteChangeSet(ctx, &cloudformation.DeleteChangeSetInput{
+			ChangeSetName: &csName,
+			StackName:     st.GetStackName(),
+		}); err != nil {
+			return err
+		}
+		return fmt.Errorf("change set %s on stack %s was not approved", csName, *st.GetStackName())
 	}
 
-	if err := st.executeChangeSet(ctx, csName, csType); err != nil {
+	if err := st.execute(ctx, csName, csType); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// createChangeSet brings the stack in a state that accepts a change set and creates the change set of the template
+// param: repair - false to fail on the stacks that need a deletion or a rollback instead of recovering them
+// return: string - the name of the change set
+// return: string - the type of the change set (CREATE or UPDATE)
+// return: error - ErrNoChanges if there are no changes, the error if any
+func (st *Stack) createChangeSet(ctx context.Context, repair bool) (string, string, error) {
+	templateBody, err := st.templateJson()
+	if err != nil {
+		return "", "", err
+	}
+
+	exists, err := st.prepare(ctx, repair)
+	if err != nil {
+		return "", "", err
+	}
+
+	csName, csType, err := st.initialChangeSet(ctx, templateBody, exists)
+	if err != nil {
+		return "", "", err
+	}
+
+	empty, err := st.waitForChangeSet(ctx, csName)
+	if err != nil {
+		return "", "", err
+	}
+	if empty {
+		return "", "", ErrNoChanges
+	}
+	return csName, csType, nil
+}
+
 // DryRun prints the template to stdout
 func (st *Stack) DryRun(ctx context.Context) error {
 	templateBody, err := st.templateJson()
@@ -84,9 +232,11 @@ func (st *Stack) DryRun(ctx context.Context) error {
 		return err
 	}
 
+	st.outputsLock.Lock()
 	for k, v := range st.GetDryRunOutputs() {
 		st.Outputs[k] = v
 	}
+	st.outputsLock.Unlock()
 
 	// Use formatted output with colors from pretty package
 	coloredOutput := string(pretty.Color([]byte(templateBody), nil))
@@ -97,15 +247,20 @@ func (st *Stack) DryRun(ctx context.Context) error {
 
 // GetOutputs gets the outputs of the stack
 func (st *Stack) GetOutputs(ctx context.Context) error {
+	st.outputsLock.Lock()
+	defer st.outputsLock.Unlock()
+
+	return st.readOutputs(ctx)
+}
+
+// readOutputs reads the outputs of the deployed stack into Outputs
+// return: error - the error if any
+func (st *Stack) readOutputs(ctx context.Context) error {
 	// Initialize the client if it hasn't been initialized already (e.g., in DryRun)
-	if st.cloudFormationClient == nil {
-		cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(st.GetRegion()))
-		if err != nil {
-			return fmt.Errorf("unable to load SDK config: %w", err)
-		}
-		st.cloudFormationClient = cloudformation.NewFromConfig(cfg)
+	if err := st.ensureClient(ctx); err != nil {
+		return err
 	}
-	
+
 	response, err := st.cloudFormationClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
 		StackName: st.GetStackName(),
 	})
@@ -124,5 +279,5 @@ func (st *Stack) GetOutputs(ctx context.Context) error {
 	return nil
 }
 
-// Remove duplicate helpers: stackExist, templateJson, initialChangeSet, waitForChangeSet, executeChangeSet
+// Remove duplicate helpers: templateJson, initialChangeSet, waitForChangeSet, executeChangeSet
 // These are already defined in changeset.go and should not be redefined here.
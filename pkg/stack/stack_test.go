@@ -3,7 +3,9 @@ package stack
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
@@ -48,6 +50,38 @@ func (m *MockTemplate) SetParameterValue(name, value string) error {
 	return nil
 }
 
+func (m *MockTemplate) IsCritical(name string) bool {
+	return name == "bucket"
+}
+
+func (m *MockTemplate) IsSecret(name string) bool {
+	return false
+}
+
+func (m *MockTemplate) GetTimeout() time.Duration {
+	return 0
+}
+
+func (m *MockTemplate) GetDependencies() []string {
+	return nil
+}
+
+func (m *MockTemplate) GetReferences() []Reference {
+	return nil
+}
+
+func (m *MockTemplate) GetCapabilities() []types.Capability {
+	return nil
+}
+
+func (m *MockTemplate) GetImportable() map[string]string {
+	return nil
+}
+
+func (m *MockTemplate) Retain(name string) error {
+	return nil
+}
+
 func TestNewStack(t *testing.T) {
 	// Create a mock template
 	mockTemplate := &MockTemplate{
@@ -57,6 +91,7 @@ func TestNewStack(t *testing.T) {
 	
 	// Create a new stack using the mock template
 	stack := NewStack(mockTemplate)
+	stack.SetClock(newFakeClock())
 	
 	// Test that the stack is properly initialized
 	if stack == nil {
@@ -67,10 +102,6 @@ func TestNewStack(t *testing.T) {
 		t.Fatal("Stack.Template should not be nil")
 	}
 	
-	if stack.ctx == nil {
-		t.Fatal("Stack.ctx should not be nil")
-	}
-	
 	if stack.Outputs == nil {
 		t.Fatal("Stack.Outputs should not be nil")
 	}
@@ -95,6 +126,7 @@ func TestGetExportName(t *testing.T) {
 	
 	// Create a new stack using the mock template
 	stack := NewStack(mockTemplate)
+	stack.SetClock(newFakeClock())
 	
 	// Test GetExportName
 	exportName := stack.GetExportName("Arn")
@@ -204,6 +236,36 @@ func (m *mockCFNSuccess) DeleteChangeSet(ctx context.Context, params *cloudforma
 func (m *mockCFNSuccess) ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
 	return nil, nil
 }
+func (m *mockCFNSuccess) DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNSuccess) DescribeStackResource(ctx context.Context, params *cloudformation.DescribeStackResourceInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNSuccess) DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNSuccess) ListImports(ctx context.Context, params *cloudformation.ListImportsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNSuccess) ContinueUpdateRollback(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNSuccess) DetectStackDrift(ctx context.Context, params *cloudformation.DetectStackDriftInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DetectStackDriftOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNSuccess) DescribeStackDriftDetectionStatus(ctx context.Context, params *cloudformation.DescribeStackDriftDetectionStatusInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNSuccess) DescribeStackResourceDrifts(ctx context.Context, params *cloudformation.DescribeStackResourceDriftsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceDriftsOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNSuccess) UpdateTerminationProtection(ctx context.Context, params *cloudformation.UpdateTerminationProtectionInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateTerminationProtectionOutput, error) {
+	return &cloudformation.UpdateTerminationProtectionOutput{}, nil
+}
+func (m *mockCFNSuccess) SetStackPolicy(ctx context.Context, params *cloudformation.SetStackPolicyInput, optFns ...func(*cloudformation.Options)) (*cloudformation.SetStackPolicyOutput, error) {
+	return &cloudformation.SetStackPolicyOutput{}, nil
+}
 
 // Mock for error DescribeStacks
 
@@ -225,10 +287,41 @@ func (m *mockCFNError) DeleteChangeSet(ctx context.Context, params *cloudformati
 func (m *mockCFNError) ExecuteChangeSet(ctx context.Context, params *cloudformation.ExecuteChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ExecuteChangeSetOutput, error) {
 	return nil, nil
 }
+func (m *mockCFNError) DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNError) DescribeStackResource(ctx context.Context, params *cloudformation.DescribeStackResourceInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNError) DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNError) ListImports(ctx context.Context, params *cloudformation.ListImportsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNError) ContinueUpdateRollback(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNError) DetectStackDrift(ctx context.Context, params *cloudformation.DetectStackDriftInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DetectStackDriftOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNError) DescribeStackDriftDetectionStatus(ctx context.Context, params *cloudformation.DescribeStackDriftDetectionStatusInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNError) DescribeStackResourceDrifts(ctx context.Context, params *cloudformation.DescribeStackResourceDriftsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceDriftsOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNError) UpdateTerminationProtection(ctx context.Context, params *cloudformation.UpdateTerminationProtectionInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateTerminationProtectionOutput, error) {
+	return nil, nil
+}
+func (m *mockCFNError) SetStackPolicy(ctx context.Context, params *cloudformation.SetStackPolicyInput, optFns ...func(*cloudformation.Options)) (*cloudformation.SetStackPolicyOutput, error) {
+	return nil, nil
+}
 
 func TestStack_GetOutputs_Mock(t *testing.T) {
 	mockTemplate := &MockTemplate{stackName: "mock-stack", region: "us-east-1"}
 	stk := NewStack(mockTemplate)
+	stk.SetClock(newFakeClock())
 	stk.cloudFormationClient = &mockCFNSuccess{}
 
 	err := stk.GetOutputs(context.Background())
@@ -243,6 +336,7 @@ func TestStack_GetOutputs_Mock(t *testing.T) {
 func TestStack_GetOutputs_MockError(t *testing.T) {
 	mockTemplate := &MockTemplate{stackName: "mock-stack", region: "us-east-1"}
 	stk := NewStack(mockTemplate)
+	stk.SetClock(newFakeClock())
 	stk.cloudFormationClient = &mockCFNError{}
 
 	err := stk.GetOutputs(context.Background())
@@ -285,6 +379,10 @@ func (m *mockCFNRun) ExecuteChangeSet(ctx context.Context, params *cloudformatio
 func (m *mockCFNRun) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
 	m.describeStacksCnt++
 	if m.describeStacksCnt < 2 {
+		// the first call checks for existence and the stack is not created yet
+		return nil, fmt.Errorf("Stack with id %s does not exist", *params.StackName)
+	}
+	if m.describeStacksCnt < 3 {
 		return &cloudformation.DescribeStacksOutput{
 			Stacks: []types.Stack{{StackStatus: types.StackStatusCreateInProgress}},
 		}, m.describeStacksErr
@@ -293,11 +391,42 @@ func (m *mockCFNRun) DescribeStacks(ctx context.Context, params *cloudformation.
 		Stacks: []types.Stack{{StackStatus: types.StackStatusCreateComplete}},
 	}, m.describeStacksErr
 }
+func (m *mockCFNRun) DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error) {
+	return &cloudformation.DescribeStackEventsOutput{}, nil
+}
+func (m *mockCFNRun) DescribeStackResource(ctx context.Context, params *cloudformation.DescribeStackResourceInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceOutput, error) {
+	return &cloudformation.DescribeStackResourceOutput{}, nil
+}
+func (m *mockCFNRun) DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error) {
+	return &cloudformation.DeleteStackOutput{}, nil
+}
+func (m *mockCFNRun) ListImports(ctx context.Context, params *cloudformation.ListImportsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListImportsOutput, error) {
+	return &cloudformation.ListImportsOutput{}, nil
+}
+func (m *mockCFNRun) ContinueUpdateRollback(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error) {
+	return &cloudformation.ContinueUpdateRollbackOutput{}, nil
+}
+func (m *mockCFNRun) DetectStackDrift(ctx context.Context, params *cloudformation.DetectStackDriftInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DetectStackDriftOutput, error) {
+	return &cloudformation.DetectStackDriftOutput{}, nil
+}
+func (m *mockCFNRun) DescribeStackDriftDetectionStatus(ctx context.Context, params *cloudformation.DescribeStackDriftDetectionStatusInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackDriftDetectionStatusOutput, error) {
+	return &cloudformation.DescribeStackDriftDetectionStatusOutput{}, nil
+}
+func (m *mockCFNRun) DescribeStackResourceDrifts(ctx context.Context, params *cloudformation.DescribeStackResourceDriftsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceDriftsOutput, error) {
+	return &cloudformation.DescribeStackResourceDriftsOutput{}, nil
+}
+func (m *mockCFNRun) UpdateTerminationProtection(ctx context.Context, params *cloudformation.UpdateTerminationProtectionInput, optFns ...func(*cloudformation.Options)) (*cloudformation.UpdateTerminationProtectionOutput, error) {
+	return &cloudformation.UpdateTerminationProtectionOutput{}, nil
+}
+func (m *mockCFNRun) SetStackPolicy(ctx context.Context, params *cloudformation.SetStackPolicyInput, optFns ...func(*cloudformation.Options)) (*cloudformation.SetStackPolicyOutput, error) {
+	return &cloudformation.SetStackPolicyOutput{}, nil
+}
 
 // Test for Stack.Run with all mocks succeeding
 func TestStack_Run_Mock(t *testing.T) {
 	mockTemplate := &MockTemplate{stackName: "mock-stack", region: "us-east-1"}
 	stk := NewStack(mockTemplate)
+	stk.SetClock(newFakeClock())
 	stk.cloudFormationClient = &mockCFNRun{}
 	err := stk.Run(context.Background())
 	if err != nil {
@@ -309,6 +438,7 @@ func TestStack_Run_Mock(t *testing.T) {
 func TestStack_Run_Mock_CreateChangeSetError(t *testing.T) {
 	mockTemplate := &MockTemplate{stackName: "mock-stack", region: "us-east-1"}
 	stk := NewStack(mockTemplate)
+	stk.SetClock(newFakeClock())
 	stk.cloudFormationClient = &mockCFNRun{createChangeSetErr: fmt.Errorf("create changeset error")}
 	err := stk.Run(context.Background())
 	if err == nil || err.Error() != "create changeset error" {
@@ -320,9 +450,45 @@ func TestStack_Run_Mock_CreateChangeSetError(t *testing.T) {
 func TestStack_Run_Mock_ExecuteChangeSetError(t *testing.T) {
 	mockTemplate := &MockTemplate{stackName: "mock-stack", region: "us-east-1"}
 	stk := NewStack(mockTemplate)
+	stk.SetClock(newFakeClock())
 	stk.cloudFormationClient = &mockCFNRun{executeChangeSetErr: fmt.Errorf("execute changeset error")}
 	err := stk.Run(context.Background())
 	if err == nil || err.Error() != "execute changeset error" {
 		t.Errorf("Expected 'execute changeset error', got %v", err)
 	}
 }
+
+func TestStack_CapabilitiesAndRole(t *testing.T) {
+	tmpl := newBucketTemplate()
+	tmpl.AddCapability(types.CapabilityCapabilityNamedIam)
+	mock := &mockCFNTemplate{}
+	stk := NewStack(bucketTemplate{tmpl})
+	stk.cloudFormationClient = mock
+	stk.SetRoleArn("arn:aws:iam::123456789012:role/cfn")
+
+	if _, _, err := stk.initialChangeSet(context.Background(), "{}", false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(mock.input.Capabilities) != 1 || mock.input.Capabilities[0] != types.CapabilityCapabilityNamedIam {
+		t.Errorf("Expected CAPABILITY_NAMED_IAM, got %v", mock.input.Capabilities)
+	}
+	if aws.ToString(mock.input.RoleARN) != "arn:aws:iam::123456789012:role/cfn" {
+		t.Errorf("Expected the service role, got %v", aws.ToString(mock.input.RoleARN))
+	}
+}
+
+func TestChangeSetName(t *testing.T) {
+	// the names of the change sets must start with a letter and contain only letters, digits and dashes
+	valid := regexp.MustCompile(`^[a-zA-Z][-a-zA-Z0-9]*$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		name := changeSetName()
+		if !valid.MatchString(name) {
+			t.Errorf("Invalid change set name %s", name)
+		}
+		if seen[name] {
+			t.Errorf("Change set name %s generated twice", name)
+		}
+		seen[name] = true
+	}
+}
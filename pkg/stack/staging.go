@@ -0,0 +1,236 @@
+package stack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/dragosboca/haws/pkg/logger"
+)
+
+const (
+	// maxTemplateBody is the largest template (in bytes) CloudFormation accepts inline
+	maxTemplateBody = 51200
+
+	// stagingPrefix is the key prefix of the templates in the staging bucket
+	stagingPrefix = "templates/"
+
+	// stagingExpiration is the number of days after which the staged templates are deleted
+	stagingExpiration = 1
+)
+
+// S3API defines the subset of methods used from the AWS S3 client
+type S3API interface {
+	HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error)
+	CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	PutPublicAccessBlock(ctx context.Context, params *s3.PutPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error)
+	PutBucketLifecycleConfiguration(ctx context.Context, params *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// STSAPI defines the subset of methods used from the AWS STS client
+type STSAPI interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// Staging stores the templates that are too large to be sent inline
+// in a bucket managed by haws (haws-templates-<account>-<region>)
+// The templates are stored by content hash and expire after one day
+type Staging struct {
+	s3Client  S3API
+	stsClient STSAPI
+	region    string
+	bucket    string
+	mu        sync.Mutex
+}
+
+// NewStaging creates the staging for a region
+// param: s3Client - the S3 client of the region
+// param: stsClient - the STS client used to find the account id
+// param: region - the region of the staging bucket
+// return: *Staging - the staging
+func NewStaging(s3Client S3API, stsClient STSAPI, region string) *Staging {
+	return &Staging{
+		s3Client:  s3Client,
+		stsClient: stsClient,
+		region:    region,
+	}
+}
+
+// Upload stores a template in the staging bucket, unless a template with the same content is already there
+// param: body - the template body
+// return: string - the URL of the template
+// return: error - the error if any
+func (s *Staging) Upload(ctx context.Context, body string) (string, error) {
+	bucket, err := s.ensureBucket(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(body))
+	key := stagingPrefix + hex.EncodeToString(sum[:]) + ".json"
+
+	_, err = s.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	var notFound *s3types.NotFound
+	if errors.As(err, &notFound) {
+		logger.Debug("Uploading template to s3://%s/%s", bucket, key)
+		_, err = s.s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      &bucket,
+			Key:         &key,
+			Body:        strings.NewReader(body),
+			ContentType: aws.String("application/json"),
+		})
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to upload template to bucket %s: %w", bucket, err)
+	}
+
+	return s.objectURL(ctx, bucket, key)
+}
+
+// objectURL returns the URL of an object of the staging bucket, resolved like the requests of the S3 client
+// (so a custom endpoint, e.g. an AWS emulator, serves the templates too)
+// param: bucket - the name of the bucket
+// param: key - the key of the object
+// return: string - the URL
+// return: error - the error if the endpoint cannot be resolved
+func (s *Staging) objectURL(ctx context.Context, bucket string, key string) (string, error) {
+	resolver := s3.NewDefaultEndpointResolverV2()
+	params := s3.EndpointParameters{
+		Bucket: &bucket,
+		Region: &s.region,
+	}
+	if client, ok := s.s3Client.(*s3.Client); ok {
+		options := client.Options()
+		if options.EndpointResolverV2 != nil {
+			resolver = options.EndpointResolverV2
+		}
+		params.Endpoint = options.BaseEndpoint
+		params.ForcePathStyle = aws.Bool(options.UsePathStyle)
+	}
+
+	endpoint, err := resolver.ResolveEndpoint(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve the URL of bucket %s: %w", bucket, err)
+	}
+	return endpoint.URI.JoinPath(key).String(), nil
+}
+
+// ensureBucket creates the staging bucket if it does not exist
+// return: string - the name of the bucket
+// return: error - the error if any
+func (s *Staging) ensureBucket(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.bucket != "" {
+		return s.bucket, nil
+	}
+
+	identity, err := s.stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("unable to get the account id: %w", err)
+	}
+	bucket := fmt.Sprintf("haws-templates-%s-%s", aws.ToString(identity.Account), s.region)
+
+	_, err = s.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: &bucket})
+	var notFound *s3types.NotFound
+	if errors.As(err, &notFound) {
+		err = s.createBucket(ctx, bucket)
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to prepare staging bucket %s: %w", bucket, err)
+	}
+
+	s.bucket = bucket
+	return bucket, nil
+}
+
+// createBucket creates a private bucket whose templates expire
+// param: bucket - the name of the bucket
+// return: error - the error if any
+func (s *Staging) createBucket(ctx context.Context, bucket string) error {
+	logger.Info("Creating staging bucket %s for the large templates", bucket)
+
+	input := &s3.CreateBucketInput{Bucket: &bucket}
+	// us-east-1 is the default location and cannot be requested explicitly
+	if s.region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3types.CreateBucketConfiguration{
+			LocationConstraint: s3types.BucketLocationConstraint(s.region),
+		}
+	}
+	_, err := s.s3Client.CreateBucket(ctx, input)
+	var owned *s3types.BucketAlreadyOwnedByYou
+	if err != nil && !errors.As(err, &owned) {
+		return err
+	}
+
+	_, err = s.s3Client.PutPublicAccessBlock(ctx, &s3.PutPublicAccessBlockInput{
+		Bucket: &bucket,
+		PublicAccessBlockConfiguration: &s3types.PublicAccessBlockConfiguration{
+			BlockPublicAcls:       aws.Bool(true),
+			BlockPublicPolicy:     aws.Bool(true),
+			IgnorePublicAcls:      aws.Bool(true),
+			RestrictPublicBuckets: aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.s3Client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: &bucket,
+		LifecycleConfiguration: &s3types.BucketLifecycleConfiguration{
+			Rules: []s3types.LifecycleRule{{
+				ID:         aws.String("haws-expire-templates"),
+				Status:     s3types.ExpirationStatusEnabled,
+				Filter:     &s3types.LifecycleRuleFilterMemberPrefix{Value: stagingPrefix},
+				Expiration: &s3types.LifecycleExpiration{Days: aws.Int32(stagingExpiration)},
+			}},
+		},
+	})
+	return err
+}
+
+// SetStaging sets the staging used for the templates that are too large to be sent inline
+func (st *Stack) SetStaging(staging *Staging) {
+	st.staging = staging
+}
+
+// templateSource returns the template body, or the URL of the staged template when it is too large
+// param: templateBody - the template body
+// return: *string - the template body to send inline (nil if staged)
+// return: *string - the URL of the staged template (nil if inline)
+// return: error - the error if any
+func (st *Stack) templateSource(ctx context.Context, templateBody string) (*string, *string, error) {
+	if len(templateBody) <= maxTemplateBody {
+		return &templateBody, nil, nil
+	}
+
+	if st.staging == nil {
+		cfg, err := st.awsConfig(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		st.staging = NewStaging(s3.NewFromConfig(cfg), sts.NewFromConfig(cfg), cfg.Region)
+	}
+
+	logger.Info("Template of stack %s has %d bytes, staging it in S3", *st.GetStackName(), len(templateBody))
+	url, err := st.staging.Upload(ctx, templateBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, &url, nil
+}
@@ -0,0 +1,144 @@
+package stack
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// mockS3Staging keeps the uploaded objects in memory and starts without a bucket
+type mockS3Staging struct {
+	bucket    string
+	lifecycle bool
+	objects   map[string]string
+	puts      int
+}
+
+func (m *mockS3Staging) HeadBucket(ctx context.Context, params *s3.HeadBucketInput, optFns ...func(*s3.Options)) (*s3.HeadBucketOutput, error) {
+	if m.bucket != *params.Bucket {
+		return nil, &s3types.NotFound{}
+	}
+	return &s3.HeadBucketOutput{}, nil
+}
+
+func (m *mockS3Staging) CreateBucket(ctx context.Context, params *s3.CreateBucketInput, optFns ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	m.bucket = *params.Bucket
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func (m *mockS3Staging) PutPublicAccessBlock(ctx context.Context, params *s3.PutPublicAccessBlockInput, optFns ...func(*s3.Options)) (*s3.PutPublicAccessBlockOutput, error) {
+	return &s3.PutPublicAccessBlockOutput{}, nil
+}
+
+func (m *mockS3Staging) PutBucketLifecycleConfiguration(ctx context.Context, params *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	m.lifecycle = len(params.LifecycleConfiguration.Rules) == 1
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+func (m *mockS3Staging) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if _, ok := m.objects[*params.Key]; !ok {
+		return nil, &s3types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func (m *mockS3Staging) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[*params.Key] = string(body)
+	m.puts++
+	return &s3.PutObjectOutput{}, nil
+}
+
+type mockSTS struct{}
+
+func (m *mockSTS) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: aws.String("123456789012")}, nil
+}
+
+// mockCFNTemplate records the template source of the change set
+type mockCFNTemplate struct {
+	mockCFNRun
+	input *cloudformation.CreateChangeSetInput
+}
+
+func (m *mockCFNTemplate) CreateChangeSet(ctx context.Context, params *cloudformation.CreateChangeSetInput, optFns ...func(*cloudformation.Options)) (*cloudformation.CreateChangeSetOutput, error) {
+	m.input = params
+	return &cloudformation.CreateChangeSetOutput{}, nil
+}
+
+func TestStaging_Upload(t *testing.T) {
+	mock := &mockS3Staging{objects: make(map[string]string)}
+	staging := NewStaging(mock, &mockSTS{}, "eu-central-1")
+
+	url, err := staging.Upload(context.Background(), "{}")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mock.bucket != "haws-templates-123456789012-eu-central-1" {
+		t.Errorf("Unexpected bucket %s", mock.bucket)
+	}
+	if !mock.lifecycle {
+		t.Error("Expected the staged templates to expire")
+	}
+	if !strings.HasPrefix(url, "https://haws-templates-123456789012-eu-central-1.s3.eu-central-1.amazonaws.com/templates/") {
+		t.Errorf("Unexpected URL %s", url)
+	}
+
+	// the same content is stored only once
+	again, err := staging.Upload(context.Background(), "{}")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if again != url || mock.puts != 1 {
+		t.Errorf("Expected the template to be uploaded once, got %d uploads (%s, %s)", mock.puts, url, again)
+	}
+}
+
+func TestStaging_ObjectURL(t *testing.T) {
+	client := s3.New(s3.Options{
+		Region:       "eu-central-1",
+		BaseEndpoint: aws.String("http://localhost:4566"),
+		UsePathStyle: true,
+	})
+	staging := NewStaging(client, &mockSTS{}, "eu-central-1")
+
+	url, err := staging.objectURL(context.Background(), "haws-templates-123456789012-eu-central-1", "templates/abc.json")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if url != "http://localhost:4566/haws-templates-123456789012-eu-central-1/templates/abc.json" {
+		t.Errorf("Expected the URL of the custom endpoint, got %s", url)
+	}
+}
+
+func TestStack_TemplateSource(t *testing.T) {
+	mock := &mockCFNTemplate{}
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	stk.cloudFormationClient = mock
+	stk.SetStaging(NewStaging(&mockS3Staging{objects: make(map[string]string)}, &mockSTS{}, "us-east-1"))
+
+	if _, _, err := stk.initialChangeSet(context.Background(), "{}", false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mock.input.TemplateBody == nil || mock.input.TemplateURL != nil {
+		t.Error("Expected a small template to be sent inline")
+	}
+
+	large := `{"Description":"` + strings.Repeat("x", maxTemplateBody) + `"}`
+	if _, _, err := stk.initialChangeSet(context.Background(), large, true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mock.input.TemplateBody != nil || mock.input.TemplateURL == nil {
+		t.Error("Expected a large template to be staged")
+	}
+}
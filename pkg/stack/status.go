@@ -0,0 +1,76 @@
+package stack
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+const (
+	// NotCreated is the status of a stack that does not exist
+	NotCreated = "NOT_CREATED"
+
+	// maskedValue replaces the value of the secret outputs
+	maskedValue = "********"
+)
+
+// StackStatus is the deployed state of a stack
+type StackStatus struct {
+	Name                  string
+	Region                string
+	Status                string
+	LastUpdated           time.Time
+	DriftStatus           string
+	TerminationProtection bool
+	Outputs               map[string]string
+}
+
+// Stable returns true if no operation is running on the stack
+// return: bool - true if the stack is stable
+func (s *StackStatus) Stable() bool {
+	return !strings.HasSuffix(s.Status, "_IN_PROGRESS")
+}
+
+// Status reads the deployed state of the stack, with the secret outputs masked
+// return: *StackStatus - the state of the stack (NOT_CREATED if it does not exist)
+// return: error - the error if any
+func (st *Stack) Status(ctx context.Context) (*StackStatus, error) {
+	if err := st.ensureClient(ctx); err != nil {
+		return nil, err
+	}
+
+	status := &StackStatus{
+		Name:    *st.GetStackName(),
+		Region:  st.GetRegion(),
+		Status:  NotCreated,
+		Outputs: make(map[string]string),
+	}
+
+	desc, err := st.describe(ctx)
+	if err != nil || desc == nil {
+		return status, err
+	}
+
+	status.Status = string(desc.StackStatus)
+	status.LastUpdated = aws.ToTime(desc.CreationTime)
+	if desc.LastUpdatedTime != nil {
+		status.LastUpdated = *desc.LastUpdatedTime
+	}
+	status.DriftStatus = string(types.StackDriftStatusNotChecked)
+	if desc.DriftInformation != nil {
+		status.DriftStatus = string(desc.DriftInformation.StackDriftStatus)
+	}
+	status.TerminationProtection = aws.ToBool(desc.EnableTerminationProtection)
+
+	for _, output := range desc.Outputs {
+		name := aws.ToString(output.OutputKey)
+		status.Outputs[name] = aws.ToString(output.OutputValue)
+		if st.IsSecret(name) {
+			status.Outputs[name] = maskedValue
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,73 @@
+package stack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// mockCFNStatus is a deployed stack with a secret output
+type mockCFNStatus struct {
+	mockCFNRun
+	missing bool
+}
+
+func (m *mockCFNStatus) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	if m.missing {
+		return nil, &types.StackNotFoundException{Message: aws.String("Stack with id mock-bucket does not exist")}
+	}
+	return &cloudformation.DescribeStacksOutput{
+		Stacks: []types.Stack{{
+			StackStatus:                 types.StackStatusUpdateInProgress,
+			CreationTime:                aws.Time(time.Unix(1600000000, 0)),
+			LastUpdatedTime:             aws.Time(time.Unix(1700000000, 0)),
+			DriftInformation:            &types.StackDriftInformation{StackDriftStatus: types.StackDriftStatusInSync},
+			EnableTerminationProtection: aws.Bool(true),
+			Outputs: []types.Output{
+				{OutputKey: aws.String("Name"), OutputValue: aws.String("my-bucket")},
+				{OutputKey: aws.String("Arn"), OutputValue: aws.String("s3cr3t")},
+			},
+		}},
+	}, nil
+}
+
+func TestStack_Status(t *testing.T) {
+	tmpl := newBucketTemplate()
+	tmpl.MarkSecret("Arn")
+	stk := NewStack(bucketTemplate{tmpl})
+	stk.cloudFormationClient = &mockCFNStatus{}
+
+	status, err := stk.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status.Name != "mock-bucket" || status.Status != "UPDATE_IN_PROGRESS" || status.DriftStatus != "IN_SYNC" || !status.TerminationProtection {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if !status.LastUpdated.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected the last update time, got %s", status.LastUpdated)
+	}
+	if status.Outputs["Name"] != "my-bucket" || status.Outputs["Arn"] != maskedValue {
+		t.Errorf("Expected the secret output to be masked, got %v", status.Outputs)
+	}
+	if status.Stable() {
+		t.Error("A stack being updated is not stable")
+	}
+}
+
+func TestStack_Status_NotCreated(t *testing.T) {
+	stk := NewStack(bucketTemplate{newBucketTemplate()})
+	stk.cloudFormationClient = &mockCFNStatus{missing: true}
+
+	status, err := stk.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status.Status != NotCreated || !status.Stable() {
+		t.Errorf("Unexpected status %+v", status)
+	}
+}
@@ -0,0 +1,67 @@
+package stack
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/sanathkr/yaml"
+)
+
+const (
+	// FormatJSON renders the templates as JSON
+	FormatJSON = "json"
+
+	// FormatYAML renders the templates as YAML
+	FormatYAML = "yaml"
+)
+
+// Synth renders the template of the stack
+// The keys are sorted, so the output only changes when the template changes
+// param: format - the format of the output (json or yaml)
+// return: []byte - the rendered template
+// return: error - the error if any
+func (st *Stack) Synth(format string) ([]byte, error) {
+	templateBody, err := st.templateJson()
+	if err != nil {
+		return nil, err
+	}
+
+	var template interface{}
+	if err := json.Unmarshal([]byte(templateBody), &template); err != nil {
+		return nil, err
+	}
+	return Render(template, format)
+}
+
+// ParameterValues returns the current values of the parameters of the stack
+// return: map[string]string - the values indexed by parameter name
+func (st *Stack) ParameterValues() map[string]string {
+	values := make(map[string]string)
+	for _, param := range st.GetParameters() {
+		values[aws.ToString(param.ParameterKey)] = aws.ToString(param.ParameterValue)
+	}
+	return values
+}
+
+// Render marshals a value with sorted keys
+// param: value - the value to render (maps are rendered with sorted keys)
+// param: format - the format of the output (json or yaml)
+// return: []byte - the rendered value, ending with a newline
+// return: error - the error if any
+func Render(value interface{}, format string) ([]byte, error) {
+	// encoding/json sorts the keys of the maps
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatJSON:
+		return append(data, '\n'), nil
+	case FormatYAML:
+		return yaml.JSONToYAML(data)
+	default:
+		return nil, fmt.Errorf("unknown format %s (expected %s or %s)", format, FormatJSON, FormatYAML)
+	}
+}
@@ -0,0 +1,41 @@
+package stack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStack_Synth(t *testing.T) {
+	stk := NewStack(bucketTemplate{newBucketTemplate()})
+
+	first, err := stk.Synth(FormatJSON)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, err := stk.Synth(FormatJSON)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("Expected the output to be deterministic")
+	}
+	// keys are sorted: AWSTemplateFormatVersion < Outputs < Resources
+	out := string(first)
+	if !(strings.Index(out, "AWSTemplateFormatVersion") < strings.Index(out, "\"Outputs\"") &&
+		strings.Index(out, "\"Outputs\"") < strings.Index(out, "\"Resources\"")) {
+		t.Errorf("Expected sorted keys, got %s", out)
+	}
+
+	yaml, err := stk.Synth(FormatYAML)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(string(yaml), "Type: AWS::S3::Bucket") {
+		t.Errorf("Expected a YAML template, got %s", yaml)
+	}
+
+	if _, err := stk.Synth("xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
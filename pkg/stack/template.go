@@ -2,6 +2,8 @@ package stack
 
 import (
 	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	cfn "github.com/awslabs/goformation/v4/cloudformation"
@@ -16,6 +18,14 @@ type Template interface {
 	GetParameters() []types.Parameter
 	GetDryRunOutputs() map[string]string
 	SetParameterValue(string, string) error
+	IsCritical(string) bool
+	IsSecret(string) bool
+	GetTimeout() time.Duration
+	GetDependencies() []string
+	GetReferences() []Reference
+	GetCapabilities() []types.Capability
+	GetImportable() map[string]string
+	Retain(string) error
 }
 
 // TemplateComponent is a struct that implements the Template interface
@@ -27,6 +37,13 @@ type TemplateComponent struct {
 	Resources     map[string]*cfn.Resource
 	Outputs       map[string]cfn.Output
 	DryRunOutputs map[string]string
+	Critical      map[string]bool
+	Secret        map[string]bool
+	Timeout       time.Duration
+	Dependencies  []string
+	References    []Reference
+	Capabilities  []types.Capability
+	Importable    map[string]string
 }
 
 func NewTemplate(region string) TemplateComponent {
@@ -36,6 +53,12 @@ func NewTemplate(region string) TemplateComponent {
 		Resources:     make(map[string]*cfn.Resource),
 		Outputs:       make(map[string]cfn.Output),
 		DryRunOutputs: make(map[string]string),
+		Critical:      make(map[string]bool),
+		Secret:        make(map[string]bool),
+		Dependencies:  make([]string, 0),
+		References:    make([]Reference, 0),
+		Capabilities:  make([]types.Capability, 0),
+		Importable:    make(map[string]string),
 		Region:        region,
 	}
 
@@ -61,6 +84,91 @@ func (t *TemplateComponent) AddResource(name string, resource cfn.Resource) {
 	t.Resources[name] = &resource
 }
 
+// MarkCritical marks a resource whose replacement or removal breaks the site
+// param: name - the name of the resource
+func (t *TemplateComponent) MarkCritical(name string) {
+	t.Critical[name] = true
+}
+
+// IsCritical returns true if the resource was marked as critical
+// param: name - the name of the resource
+// return: bool - true if the resource is critical
+func (t *TemplateComponent) IsCritical(name string) bool {
+	return t.Critical[name]
+}
+
+// MarkSecret marks an output whose value must not be displayed
+// param: name - the name of the output
+func (t *TemplateComponent) MarkSecret(name string) {
+	t.Secret[name] = true
+}
+
+// IsSecret returns true if the output was marked as secret
+// param: name - the name of the output
+// return: bool - true if the output is secret
+func (t *TemplateComponent) IsSecret(name string) bool {
+	return t.Secret[name]
+}
+
+// AddDependency declares a stack that must be deployed before this one
+// param: name - the name of the stack (certificate, bucket, cloudfront, user)
+func (t *TemplateComponent) AddDependency(name string) {
+	t.Dependencies = append(t.Dependencies, name)
+}
+
+// GetDependencies returns the stacks that must be deployed before this one
+// return: []string - the names of the stacks
+func (t *TemplateComponent) GetDependencies() []string {
+	return t.Dependencies
+}
+
+// AddCapability declares a capability CloudFormation needs to create the resources of the template
+// param: capability - the capability (e.g. CAPABILITY_NAMED_IAM for named IAM resources)
+func (t *TemplateComponent) AddCapability(capability types.Capability) {
+	t.Capabilities = append(t.Capabilities, capability)
+}
+
+// GetCapabilities returns the capabilities declared by the template
+// return: []types.Capability - the capabilities
+func (t *TemplateComponent) GetCapabilities() []types.Capability {
+	return t.Capabilities
+}
+
+// AddImportable declares a resource that can be adopted from an existing physical resource
+// param: name - the name of the resource
+// param: parameter - the parameter receiving the physical id, if the template names the resource ("" otherwise)
+func (t *TemplateComponent) AddImportable(name string, parameter string) {
+	t.Importable[name] = parameter
+}
+
+// GetImportable returns the resources that can be imported
+// return: map[string]string - the parameter receiving the physical id indexed by resource name
+func (t *TemplateComponent) GetImportable() map[string]string {
+	return t.Importable
+}
+
+// Retain sets the Retain deletion policy on a resource, so it is kept when removed from the stack
+// param: name - the name of the resource
+// return: error - the error if the resource does not exist
+func (t *TemplateComponent) Retain(name string) error {
+	resource, ok := t.Resources[name]
+	if !ok {
+		return fmt.Errorf("resource %s not found", name)
+	}
+
+	// goformation has no setter for the deletion policy in the Resource interface
+	value := reflect.ValueOf(*resource)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	field := value.FieldByName("AWSCloudFormationDeletionPolicy")
+	if !field.IsValid() || !field.CanSet() {
+		return fmt.Errorf("resource %s does not support a deletion policy", name)
+	}
+	field.SetString("Retain")
+	return nil
+}
+
 // AddOutput adds an output to the template
 // param: name - the name of the output
 // param: output - the output definition
@@ -100,6 +208,12 @@ func (t *TemplateComponent) GetDryRunOutputs() map[string]string {
 	return t.DryRunOutputs
 }
 
+// GetTimeout returns the maximum duration of the operations on the stack (0 for the default)
+// return: time.Duration - the maximum duration
+func (t *TemplateComponent) GetTimeout() time.Duration {
+	return t.Timeout
+}
+
 // GetRegion returns the region of the template
 // return: string - the region of the template
 func (t *TemplateComponent) GetRegion() string {
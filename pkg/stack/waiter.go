@@ -0,0 +1,115 @@
+package stack
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the maximum duration of a stack operation when the template does not set one
+	DefaultTimeout = 30 * time.Minute
+
+	// changeSetTimeout is the maximum duration of a change set creation
+	changeSetTimeout = 5 * time.Minute
+
+	// driftTimeout is the maximum duration of a drift detection
+	driftTimeout = 10 * time.Minute
+)
+
+// Clock abstracts the passing of time, so waiting can be simulated in tests
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Waiter polls a condition with exponential backoff and jitter until it is met
+type Waiter struct {
+	MinDelay    time.Duration
+	MaxDelay    time.Duration
+	MaxDuration time.Duration
+	Jitter      float64
+	Clock       Clock
+}
+
+// NewWaiter creates a waiter that gives up after maxDuration
+// param: maxDuration - the maximum time to wait
+// param: clock - the clock used to measure and wait (nil for the real clock)
+// return: *Waiter - the waiter
+func NewWaiter(maxDuration time.Duration, clock Clock) *Waiter {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &Waiter{
+		MinDelay:    2 * time.Second,
+		MaxDelay:    30 * time.Second,
+		MaxDuration: maxDuration,
+		Jitter:      0.2,
+		Clock:       clock,
+	}
+}
+
+// Wait calls check until it reports done or fails
+// It returns right away when the context is cancelled and fails once MaxDuration has passed
+// param: operation - the description of what is waited for, used in the timeout error
+// param: check - the condition, returning true when done
+// return: error - the error returned by check, the context error or a timeout error
+func (w *Waiter) Wait(ctx context.Context, operation string, check func(ctx context.Context) (bool, error)) error {
+	start := w.Clock.Now()
+	delay := w.MinDelay
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		done, err := check(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		remaining := w.MaxDuration - w.Clock.Now().Sub(start)
+		if remaining <= 0 {
+			return &TimeoutError{Operation: operation, After: w.MaxDuration}
+		}
+
+		sleep := w.jittered(delay)
+		if sleep > remaining {
+			sleep = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.Clock.After(sleep):
+		}
+
+		delay *= 2
+		if delay > w.MaxDelay {
+			delay = w.MaxDelay
+		}
+	}
+}
+
+// jittered randomizes the delay by up to Jitter in both directions
+func (w *Waiter) jittered(delay time.Duration) time.Duration {
+	if w.Jitter <= 0 {
+		return delay
+	}
+	factor := 1 + w.Jitter*(2*rand.Float64()-1)
+	return time.Duration(float64(delay) * factor)
+}
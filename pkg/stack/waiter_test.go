@@ -0,0 +1,111 @@
+package stack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock advances its time on each After call instead of sleeping
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestWaiter_Backoff(t *testing.T) {
+	clock := newFakeClock()
+	w := NewWaiter(time.Hour, clock)
+	w.Jitter = 0
+
+	calls := 0
+	err := w.Wait(context.Background(), "test", func(ctx context.Context) (bool, error) {
+		calls++
+		return calls == 6, nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 30 * time.Second}
+	if fmt.Sprint(clock.sleeps) != fmt.Sprint(expected) {
+		t.Errorf("Expected delays %v, got %v", expected, clock.sleeps)
+	}
+}
+
+func TestWaiter_Timeout(t *testing.T) {
+	clock := newFakeClock()
+	w := NewWaiter(time.Minute, clock)
+
+	err := w.Wait(context.Background(), "test", func(ctx context.Context) (bool, error) {
+		return false, nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out after 1m0s waiting for test") {
+		t.Fatalf("Expected timeout error, got %v", err)
+	}
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || timeout.After != time.Minute {
+		t.Errorf("Expected a TimeoutError, got %T", err)
+	}
+	if elapsed := clock.now.Sub(newFakeClock().now); elapsed != time.Minute {
+		t.Errorf("Expected to wait exactly the maximum duration, waited %s", elapsed)
+	}
+}
+
+func TestWaiter_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := NewWaiter(time.Hour, newFakeClock())
+
+	calls := 0
+	err := w.Wait(ctx, "test", func(ctx context.Context) (bool, error) {
+		calls++
+		cancel()
+		return false, nil
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single check, got %d", calls)
+	}
+}
+
+func TestWaiter_CheckError(t *testing.T) {
+	w := NewWaiter(time.Hour, newFakeClock())
+
+	err := w.Wait(context.Background(), "test", func(ctx context.Context) (bool, error) {
+		return false, fmt.Errorf("boom")
+	})
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("Expected the check error, got %v", err)
+	}
+}
+
+func TestStack_Timeout(t *testing.T) {
+	stk := NewStack(&MockTemplate{stackName: "mock-stack", region: "us-east-1"})
+	if stk.timeout() != DefaultTimeout {
+		t.Errorf("Expected the default timeout, got %s", stk.timeout())
+	}
+	stk.SetTimeout(time.Minute)
+	if stk.timeout() != time.Minute {
+		t.Errorf("Expected the overridden timeout, got %s", stk.timeout())
+	}
+}